@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+func TestChaincode(t *testing.T) {
+	stub := mockstub.NewMockStub("finished", new(SimpleChaincode))
+	if _, err := stub.MockInit("init"); err == nil {
+		t.Fatal("expected init without arguments to fail")
+	}
+	if _, err := stub.MockInit("init", "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		query    bool
+		function string
+		args     []string
+		want     string
+		fails    bool
+	}{
+		{"read init value", true, "read", []string{"hello_world"}, "hi", false},
+		{"write", false, "write", []string{"a", "1"}, "", false},
+		{"read written value", true, "read", []string{"a"}, "1", false},
+		{"write with one argument", false, "write", []string{"a"}, "", true},
+		{"init through invoke", false, "init", []string{"hello"}, "", false},
+		{"read reset value", true, "read", []string{"hello_world"}, "hello", false},
+		{"read without key", true, "read", nil, "", true},
+		{"read missing key", true, "read", []string{"missing"}, "", false},
+		{"unknown invoke", false, "delete", []string{"a"}, "", true},
+		{"unknown query", true, "write", []string{"a", "1"}, "", true},
+	}
+	for _, test := range tests {
+		var got []byte
+		var err error
+		if test.query {
+			got, err = stub.MockQuery(test.function, test.args...)
+		} else {
+			got, err = stub.MockInvoke(test.function, test.args...)
+		}
+		if (err != nil) != test.fails {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+		if string(got) != test.want {
+			t.Fatalf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	 Archive - Cancelled packages, and packages that reached a terminal status at least ARCHIVE_RETENTION_SECONDS
+//					ago, are moved from Package~PkgId to ArchivedPkg~PkgId. The archived record is the tombstone of
+//					the package: the package as last written plus when and by whom it was archived. Its secondary
+//					index entries are deleted so that it no longer shows up in list queries, while its temprature,
+//					condition and claim history stay under their own keys. The id of an archived package can not
+//					be reused.
+//==============================================================================================================================
+const archivedPkgObjectType = "ArchivedPkg"
+
+const ARCHIVE_RETENTION_SECONDS = 30 * 24 * 60 * 60
+
+//==============================================================================================================================
+//	 Archived list items - Package list queries only return archived packages when this flag is passed ahead of
+//					their paging arguments, e.g. querybypkgstatus Pkg_Cancelled includeArchived 10
+//==============================================================================================================================
+const LIST_INCLUDE_ARCHIVED = "includeArchived"
+
+//==============================================================================================================================
+//	archivedPkgKey - ledger key of an archived package
+//==============================================================================================================================
+func archivedPkgKey(pkgId string) (string, error) {
+	return createCompositeKey(archivedPkgObjectType, []string{pkgId})
+}
+
+//==============================================================================================================================
+//	getArchivedPackage - reads an archived package, nil if the package was never archived
+//==============================================================================================================================
+func getArchivedPackage(stub shim.ChaincodeStubInterface, pkgId string) (*PackageInfo, error) {
+	key, err := archivedPkgKey(pkgId)
+	if err != nil {
+		return nil, err
+	}
+
+	valAsbytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, newError(E_INTERNAL, "Failed to get state for archived package "+pkgId)
+	}
+	if valAsbytes == nil {
+		return nil, nil
+	}
+
+	var packageinfo PackageInfo
+	err = decodePackageInfo(pkgId, valAsbytes, &packageinfo)
+	if err != nil {
+		fmt.Println("Could not unmarshal archived package info object", err)
+		return nil, newError(E_DECODE, "Could not unmarshal archived package info object "+pkgId)
+	}
+	return &packageinfo, nil
+}
+
+//==============================================================================================================================
+//	getPackageRecord - reads a package whether it is active or archived, failing if no such package exists
+//==============================================================================================================================
+func getPackageRecord(stub shim.ChaincodeStubInterface, pkgId string) (PackageInfo, error) {
+	archived, err := getArchivedPackage(stub, pkgId)
+	if err != nil {
+		return PackageInfo{}, err
+	}
+	if archived != nil {
+		return *archived, nil
+	}
+	return getPackageInfo(stub, pkgId)
+}
+
+//==============================================================================================================================
+//	archivePackage - moves packageinfo, possibly changed since it was read, to its archived key. The index entries of
+//				the stored package are deleted and the package is taken out of its shipment, so that shipments
+//				only hold active packages. Fails with E_ILLEGAL_STATE while that shipment is sealed, as its
+//				packages only change with the shipment, until the Shipper unseals it.
+//==============================================================================================================================
+func archivePackage(stub shim.ChaincodeStubInterface, packageinfo *PackageInfo, archivedBy string, timestamp int64) error {
+	stored, err := getPackageInfo(stub, packageinfo.PkgId)
+	if err != nil {
+		return err
+	}
+
+	oldKeys, err := pkgIndexKeys(&stored)
+	if err != nil {
+		return err
+	}
+
+	err = updatePkgIndexes(stub, oldKeys, nil)
+	if err != nil {
+		return err
+	}
+
+	if packageinfo.ShipmentId != "" {
+		shipment, err := getShipment(stub, packageinfo.ShipmentId)
+		if err != nil {
+			return err
+		}
+		if shipment.Sealed {
+			return newError(E_ILLEGAL_STATE, "Package "+packageinfo.PkgId+" is in sealed shipment "+shipment.ShipmentId+", unseal the shipment first")
+		}
+
+		pkgIds := []string{}
+		for _, pkgId := range shipment.PkgIds {
+			if pkgId != packageinfo.PkgId {
+				pkgIds = append(pkgIds, pkgId)
+			}
+		}
+		shipment.PkgIds = pkgIds
+
+		err = putShipment(stub, &shipment)
+		if err != nil {
+			return err
+		}
+	}
+
+	if stored.PkgStatus != packageinfo.PkgStatus {
+		packageinfo.StatusChangedAt = timestamp
+	}
+	packageinfo.TempUnit = TEMP_UNIT_DECI_CELSIUS
+	packageinfo.SchemaVersion = PKG_SCHEMA_VERSION
+	packageinfo.ArchivedAt = timestamp
+	packageinfo.ArchivedBy = archivedBy
+
+	bytes, err := json.Marshal(packageinfo)
+	if err != nil {
+		fmt.Println("Could not marshal package info object", err)
+		return newError(E_INTERNAL, "Could not marshal package info object "+packageinfo.PkgId)
+	}
+
+	key, err := archivedPkgKey(packageinfo.PkgId)
+	if err != nil {
+		return err
+	}
+
+	err = stub.PutState(key, bytes)
+	if err != nil {
+		return newError(E_INTERNAL, "Failed writing to blockchain for archived Package "+packageinfo.PkgId)
+	}
+
+	key, err = pkgKey(packageinfo.PkgId)
+	if err != nil {
+		return err
+	}
+
+	err = stub.DelState(key)
+	if err != nil {
+		return newError(E_INTERNAL, "Failed to delete Package "+packageinfo.PkgId)
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	parsePkgListOptions - parseListOptions for the package list queries, which accept LIST_INCLUDE_ARCHIVED ahead of
+//				the paging arguments
+//==============================================================================================================================
+func parsePkgListOptions(args []string) (ListOptions, error) {
+	includeArchived := len(args) > 0 && args[0] == LIST_INCLUDE_ARCHIVED
+	if includeArchived {
+		args = args[1:]
+	}
+
+	opts, err := parseListOptions(args)
+	opts.IncludeArchived = includeArchived
+	return opts, err
+}
+
+//==============================================================================================================================
+//	withArchivedPackages - appends the range of the archived packages, scanned with collect, to the sources of a
+//				package list query if opts asks for archived packages
+//==============================================================================================================================
+func withArchivedPackages(opts ListOptions, sources []scanSource, collect func(key string, value []byte) (json.RawMessage, error)) ([]scanSource, error) {
+	if !opts.IncludeArchived {
+		return sources, nil
+	}
+
+	startKey, endKey, err := compositeKeyRange(archivedPkgObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	return append(sources, scanSource{startKey, endKey, collect}), nil
+}
+
+//=================================================================================================================================
+//	cancelpkg - the Shipper cancels a package no Provider has accepted yet, e.g. one created by mistake. The package
+//				moves to Pkg_Cancelled and is archived at once. Expects PkgId, Shipper (only checked in insecure
+//				mode) and an optional Reason.
+//=================================================================================================================================
+func (t *SimpleChaincode) cancelpkg(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running cancelpkg()")
+
+	if len(args) < 1 || len(args) > 3 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId, Shipper (in insecure mode) and optional Reason")
+	}
+
+	packageinfo, err := getPackageInfo(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkTransition(packageinfo.PkgStatus, STATUS_PKG_CANCELLED)
+	if err != nil {
+		return nil, err
+	}
+
+	err = authorizeParty(stub, args, 1, packageinfo.Shipper)
+	if err != nil {
+		return nil, wrapError(E_FORBIDDEN, "Wrong Shipper - Not authorized to cancel this package", err)
+	}
+
+	if packageinfo.ShipmentId != "" {
+		return nil, newError(E_ILLEGAL_STATE, "Package "+packageinfo.PkgId+" is in shipment "+packageinfo.ShipmentId+", remove it before cancelling")
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	actor := eventActor(stub, packageinfo.Shipper)
+	oldStatus := packageinfo.PkgStatus
+	packageinfo.PkgStatus = STATUS_PKG_CANCELLED
+	if len(args) == 3 {
+		packageinfo.CancelReason = args[2]
+	}
+
+	err = archivePackage(stub, &packageinfo, actor, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitPkgEvents(stub, []PkgEvent{{Event: EVENT_PKG_CANCELLED, PkgId: packageinfo.PkgId, OldStatus: oldStatus, NewStatus: packageinfo.PkgStatus, Actor: actor, Timestamp: timestamp}})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&packageinfo)
+}
+
+//=================================================================================================================================
+//	archivepkg - archives a package in a terminal status once ARCHIVE_RETENTION_SECONDS have passed since it reached
+//				it and all of its claims are settled. Packages written before StatusChangedAt was recorded are past
+//				the retention period. Expects PkgId.
+//=================================================================================================================================
+func (t *SimpleChaincode) archivepkg(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running archivepkg()")
+
+	if len(args) != 1 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId")
+	}
+
+	packageinfo, err := getPackageInfo(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if !isTerminalStatus(packageinfo.PkgStatus) {
+		return nil, newError(E_ILLEGAL_STATE, "Package "+packageinfo.PkgId+" is "+packageinfo.PkgStatus+", only packages in a terminal status can be archived")
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	if timestamp-packageinfo.StatusChangedAt < ARCHIVE_RETENTION_SECONDS {
+		return nil, newError(E_ILLEGAL_STATE, "Package "+packageinfo.PkgId+" can not be archived before "+fmt.Sprint(packageinfo.StatusChangedAt+ARCHIVE_RETENTION_SECONDS))
+	}
+
+	claims, err := getClaims(stub, packageinfo.PkgId)
+	if err != nil {
+		return nil, err
+	}
+	for _, claim := range claims {
+		if len(claimTransitions[claim.ClaimStatus]) > 0 {
+			return nil, newError(E_ILLEGAL_STATE, "Claim "+claim.ClaimId+" of package "+packageinfo.PkgId+" is "+claim.ClaimStatus+", settle it before archiving")
+		}
+	}
+
+	actor := eventActor(stub, ROLE_ADMIN)
+	err = archivePackage(stub, &packageinfo, actor, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitPkgEvents(stub, []PkgEvent{{Event: EVENT_PKG_ARCHIVED, PkgId: packageinfo.PkgId, OldStatus: packageinfo.PkgStatus, NewStatus: packageinfo.PkgStatus, Actor: actor, Timestamp: timestamp}})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&packageinfo)
+}
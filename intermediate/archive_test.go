@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+// pastRetention moves the clock of stub past the archive retention period
+func pastRetention(stub *mockstub.MockStub) {
+	stub.Time = stub.Time.Add(ARCHIVE_RETENTION_SECONDS * time.Second)
+}
+
+// listPage decodes a paged list query result
+func listPage(t *testing.T, result []byte) ([]string, ListPage) {
+	t.Helper()
+	var page ListPage
+	if err := json.Unmarshal(result, &page); err != nil {
+		t.Fatalf("%s: %v", result, err)
+	}
+	items, _ := json.Marshal(page.Items)
+	return pkgIds(t, items), page
+}
+
+func TestCancelpkg(t *testing.T) {
+	runInvokeTests(t, []invokeTest{
+		{name: "shipper cancels", setup: [][]string{createP1}, function: "cancelpkg", args: []string{"P1", "SHP", "created twice"}, status: STATUS_PKG_CANCELLED,
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				packageinfo := getPkg(t, stub, "P1")
+				if packageinfo.CancelReason != "created twice" || packageinfo.ArchivedBy != "SHP" || packageinfo.ArchivedAt == 0 || packageinfo.StatusChangedAt != packageinfo.ArchivedAt {
+					t.Fatalf("unexpected tombstone %+v", packageinfo)
+				}
+				if name, event := lastEvent(t, stub); name != EVENT_PKG_CANCELLED || event.OldStatus != STATUS_LABEL_GENERATED || event.Package == nil || event.Package.ArchivedAt == 0 {
+					t.Fatalf("unexpected event %s %+v", name, event)
+				}
+				if got := pkgIds(t, mustQuery(t, stub, "querypkgbyshipper", "SHP")); len(got) != 0 {
+					t.Fatalf("cancelled package still listed: %v", got)
+				}
+			}},
+		{name: "wrong shipper", setup: [][]string{createP1}, function: "cancelpkg", args: []string{"P1", "CON"}, code: E_FORBIDDEN, status: STATUS_LABEL_GENERATED},
+		{name: "accepted", setup: [][]string{createP1, {"acceptpkg", "P1", "PRV"}}, function: "cancelpkg", args: []string{"P1", "SHP"}, code: E_ILLEGAL_STATE, status: STATUS_IN_TRANSIT},
+		{name: "in shipment", setup: openS1, function: "cancelpkg", args: []string{"P1", "SHP"}, code: E_ILLEGAL_STATE, status: STATUS_LABEL_GENERATED},
+		{name: "cancelled twice", setup: [][]string{createP1, {"cancelpkg", "P1", "SHP"}}, function: "cancelpkg", args: []string{"P1", "SHP"}, code: E_ILLEGAL_STATE},
+		{name: "id not reused", setup: [][]string{createP1, {"cancelpkg", "P1", "SHP"}}, function: "create", args: createP1[1:], code: E_DUPLICATE, status: STATUS_PKG_CANCELLED},
+		{name: "unknown package", function: "cancelpkg", args: []string{"P9", "SHP"}, code: E_NOT_FOUND},
+		{name: "missing shipper", setup: [][]string{createP1}, function: "cancelpkg", args: []string{"P1"}, code: E_ARGS},
+	})
+}
+
+func TestArchivepkg(t *testing.T) {
+	delivered := then(deliveredP1, []string{"confirmdelivery", "P1", "CON"})
+	tests := []struct {
+		name    string
+		setup   [][]string
+		retired bool
+		code    string
+	}{
+		{"delivered", delivered, true, ""},
+		{"within retention", delivered, false, E_ILLEGAL_STATE},
+		{"not terminal", deliveredP1, true, E_ILLEGAL_STATE},
+		{"open claim", damagedP1, true, E_ILLEGAL_STATE},
+		{"settled claim", then(damagedP1, []string{"rejectclaim", "P1", "CLM000001", "INS"}), true, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newTestStub(t, test.setup...)
+			claims := getClaimList(t, stub, "P1")
+			history := mustQuery(t, stub, "querytemphistory", "P1")
+			if test.retired {
+				pastRetention(stub)
+			}
+			_, err := stub.MockInvoke("archivepkg", "P1")
+			checkCode(t, err, test.code)
+			if test.code != "" {
+				return
+			}
+
+			if name, event := lastEvent(t, stub); name != EVENT_PKG_ARCHIVED || event.Package == nil || event.Package.ArchivedAt == 0 {
+				t.Fatalf("unexpected event %s %+v", name, event)
+			}
+			// the history and claims of the package are kept
+			if got := mustQuery(t, stub, "querytemphistory", "P1"); string(got) != string(history) {
+				t.Fatalf("temprature history %s, want %s", got, history)
+			}
+			if got := getClaimList(t, stub, "P1"); len(got) != len(claims) {
+				t.Fatalf("claims %+v, want %+v", got, claims)
+			}
+			if packageinfo := getPkg(t, stub, "P1"); packageinfo.ArchivedBy != ROLE_ADMIN || !isTerminalStatus(packageinfo.PkgStatus) {
+				t.Fatalf("unexpected tombstone %+v", packageinfo)
+			}
+			_, err = stub.MockInvoke("archivepkg", "P1")
+			checkCode(t, err, E_ILLEGAL_STATE)
+			_, err = stub.MockInvoke("acceptpkg", "P1", "PRV")
+			checkCode(t, err, E_ILLEGAL_STATE)
+		})
+	}
+}
+
+func TestArchiveLeavesShipment(t *testing.T) {
+	stub := newTestStub(t, then(movingS1, []string{"updateshipmentstatus", "S1", STATUS_DELIVERY_PENDING, "PRV"}, []string{"confirmdelivery", "P1", "CON"})...)
+	pastRetention(stub)
+
+	// the packages of a sealed shipment only change with the shipment
+	_, err := stub.MockInvoke("archivepkg", "P1")
+	checkCode(t, err, E_ILLEGAL_STATE)
+	if result := getShipmentResult(t, stub, "S1"); !equalStrings(result.PkgIds, []string{"P1", "P2"}) || !result.Sealed {
+		t.Fatalf("sealed shipment changed %+v", result)
+	}
+
+	mustInvoke(t, stub, "unsealshipment", "S1", "SHP")
+	mustInvoke(t, stub, "archivepkg", "P1")
+
+	if result := getShipmentResult(t, stub, "S1"); !equalStrings(result.PkgIds, []string{"P2"}) {
+		t.Fatalf("unexpected shipment %+v", result)
+	}
+	if packageinfo := getPkg(t, stub, "P1"); packageinfo.ShipmentId != "S1" || packageinfo.PkgStatus != STATUS_PKG_DELIVERED {
+		t.Fatalf("unexpected tombstone %+v", packageinfo)
+	}
+}
+
+func TestListIncludeArchived(t *testing.T) {
+	createP3 := []string{"create", "P3", "SHP", "INS", "CON", "2", "8", "vaccine", "PRV"}
+	stub := newTestStub(t, createP1, createP2, createP3, []string{"cancelpkg", "P1", "SHP"}, []string{"cancelpkg", "P3", "SHP"})
+
+	tests := []struct {
+		function string
+		args     []string
+		active   []string
+		all      []string
+	}{
+		{"queryallpkg", nil, []string{"1Z20170426", "P2"}, []string{"1Z20170426", "P1", "P2", "P3"}},
+		{"querypkgbyshipper", []string{"SHP"}, []string{"P2"}, []string{"P1", "P2", "P3"}},
+		{"querybypkgstatus", []string{STATUS_PKG_CANCELLED}, []string{}, []string{"P1", "P3"}},
+		{"querybyrole_status", []string{ROLE_SHIPPER, "SHP", STATUS_LABEL_GENERATED}, []string{"P2"}, []string{"P2"}},
+		{"querypkgs", []string{`{"field": "shipper", "eq": "SHP"}`}, []string{"P2"}, []string{"P1", "P2", "P3"}},
+	}
+	for _, test := range tests {
+		t.Run(test.function, func(t *testing.T) {
+			if got := pkgIds(t, mustQuery(t, stub, test.function, test.args...)); !equalStrings(got, test.active) {
+				t.Fatalf("active packages %v, want %v", got, test.active)
+			}
+			args := append(append([]string{}, test.args...), LIST_INCLUDE_ARCHIVED)
+			if got := pkgIds(t, mustQuery(t, stub, test.function, args...)); !equalStrings(got, test.all) {
+				t.Fatalf("all packages %v, want %v", got, test.all)
+			}
+
+			// pages of one item walk the active packages first, then the archived ones
+			var paged []string
+			bookmark := ""
+			for {
+				ids, page := listPage(t, mustQuery(t, stub, test.function, append(args, "1", bookmark)...))
+				paged = append(paged, ids...)
+				if !page.HasMore {
+					break
+				}
+				bookmark = page.Bookmark
+			}
+			sort.Strings(paged)
+			if !equalStrings(paged, test.all) {
+				t.Fatalf("paged packages %v, want %v", paged, test.all)
+			}
+		})
+	}
+
+	var holder PKG_Holder
+	if err := json.Unmarshal(mustQuery(t, stub, "queryallpkgids", LIST_INCLUDE_ARCHIVED), &holder); err != nil || !equalStrings(holder.PkgIds, []string{"1Z20170426", "P2", "P1", "P3"}) {
+		t.Fatalf("package ids %v, %v", holder.PkgIds, err)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	 Chaincode version - Recorded in the ChaincodeConfig by every Init. Bump it with every release of the chaincode
+//					so the ledger tells which code last deployed or upgraded it.
+//==============================================================================================================================
+const CHAINCODE_VERSION = "1.0.0"
+
+//==============================================================================================================================
+//	 Init modes - The function passed to Init selects what it does:
+//						init      - deploys on an empty ledger, bootstrapping the sample package when its arguments
+//									are passed; on a ledger that already holds chaincode state it only upgrades
+//						bootstrap - deploys with the sample package, refused if the ledger already holds state
+//						upgrade   - records the new chaincode version and leaves all other state untouched,
+//									refused on an empty ledger
+//==============================================================================================================================
+const (
+	INIT_MODE_INIT      = "init"
+	INIT_MODE_BOOTSTRAP = "bootstrap"
+	INIT_MODE_UPGRADE   = "upgrade"
+)
+
+//==============================================================================================================================
+//	 Chaincode config - A single record describing the deployment: the chaincode and package schema versions
+//					that last ran Init, the auth mode chosen at deploy time and when the chaincode was deployed and
+//					last upgraded. Ledgers deployed before the record existed only kept the auth mode, under
+//					authModeKey; the first upgrade moves it into the config.
+//==============================================================================================================================
+const chaincodeConfigKey = "ChaincodeConfig"
+
+type ChaincodeConfig struct {
+	Version       string `json:"version"`
+	SchemaVersion int    `json:"schemaversion"`
+	AuthMode      string `json:"authmode"`
+	DeployedAt    int64  `json:"deployedat"`
+	UpgradedAt    int64  `json:"upgradedat,omitempty"`
+	Upgrades      int    `json:"upgrades"`
+}
+
+//==============================================================================================================================
+//	getChaincodeConfig - reads the config record, nil if the ledger has none
+//==============================================================================================================================
+func getChaincodeConfig(stub shim.ChaincodeStubInterface) (*ChaincodeConfig, error) {
+	valAsbytes, err := stub.GetState(chaincodeConfigKey)
+	if err != nil {
+		return nil, newError(E_INTERNAL, "Failed to get state for "+chaincodeConfigKey)
+	}
+	if valAsbytes == nil {
+		return nil, nil
+	}
+
+	var config ChaincodeConfig
+	err = json.Unmarshal(valAsbytes, &config)
+	if err != nil {
+		fmt.Println("Could not unmarshal chaincode config", err)
+		return nil, newError(E_DECODE, "Could not unmarshal "+chaincodeConfigKey)
+	}
+	return &config, nil
+}
+
+//==============================================================================================================================
+//	putChaincodeConfig - writes the config record
+//==============================================================================================================================
+func putChaincodeConfig(stub shim.ChaincodeStubInterface, config *ChaincodeConfig) error {
+	bytes, err := json.Marshal(config)
+	if err != nil {
+		fmt.Println("Could not marshal chaincode config", err)
+		return newError(E_INTERNAL, "Could not marshal "+chaincodeConfigKey)
+	}
+
+	err = stub.PutState(chaincodeConfigKey, bytes)
+	if err != nil {
+		return newError(E_INTERNAL, "Failed writing to blockchain for "+chaincodeConfigKey)
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	hasChaincodeState - true if an earlier deployment left state on the ledger: the config record, the auth mode or
+//				package index of a deployment from before the config existed, or any package
+//==============================================================================================================================
+func hasChaincodeState(stub shim.ChaincodeStubInterface) (bool, error) {
+	for _, key := range []string{chaincodeConfigKey, authModeKey, legacyPkgIdsKey} {
+		valAsbytes, err := stub.GetState(key)
+		if err != nil {
+			return false, newError(E_INTERNAL, "Failed to get state for "+key)
+		}
+		if valAsbytes != nil {
+			return true, nil
+		}
+	}
+
+	startKey, endKey, err := compositeKeyRange(packageObjectType, []string{})
+	if err != nil {
+		return false, err
+	}
+
+	iter, err := stub.RangeQueryState(startKey, endKey)
+	if err != nil {
+		return false, newError(E_INTERNAL, "Failed to range query packages")
+	}
+	defer iter.Close()
+
+	return iter.HasNext(), nil
+}
+
+//==============================================================================================================================
+//	upgradeChaincode - the Init of a chaincode deployed over existing state. Records the chaincode version in the
+//				config, creating it from the legacy auth mode if needed, and touches nothing else. authmode is
+//				the auth mode passed to Init, empty if none was; it must match the one the chaincode was
+//				deployed with.
+//==============================================================================================================================
+func upgradeChaincode(stub shim.ChaincodeStubInterface, authmode string) ([]byte, error) {
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		legacymode, err := getAuthMode(stub)
+		if err != nil {
+			return nil, err
+		}
+		config = &ChaincodeConfig{AuthMode: legacymode}
+
+		err = stub.DelState(authModeKey)
+		if err != nil {
+			return nil, newError(E_INTERNAL, "Failed to delete "+authModeKey)
+		}
+	}
+
+	if authmode != "" && authmode != config.AuthMode {
+		return nil, newError(E_ILLEGAL_STATE, "Chaincode was deployed in "+config.AuthMode+" mode, the auth mode can not be changed by an upgrade")
+	}
+
+	config.Version = CHAINCODE_VERSION
+	config.SchemaVersion = PKG_SCHEMA_VERSION
+	config.UpgradedAt = timestamp
+	config.Upgrades++
+
+	err = putChaincodeConfig(stub, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(config)
+}
+
+//=================================================================================================================================
+//	queryconfig - query function returning the chaincode config record
+//=================================================================================================================================
+func (t *SimpleChaincode) queryconfig(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 0 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting 0")
+	}
+
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, newError(E_NOT_FOUND, "Chaincode config not found, upgrade the chaincode to record it")
+	}
+
+	return json.Marshal(config)
+}
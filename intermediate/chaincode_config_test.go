@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+var sampleArgs = []string{"SHIPPER", "INSURER", "CONSIGNEE", "PROVIDER", "-20", "-10", "sample"}
+
+// getConfig reads the chaincode config through queryconfig
+func getConfig(t *testing.T, stub *mockstub.MockStub) ChaincodeConfig {
+	t.Helper()
+	var config ChaincodeConfig
+	if err := json.Unmarshal(mustQuery(t, stub, "queryconfig"), &config); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestInitModes(t *testing.T) {
+	insecureSample := append(append([]string{}, sampleArgs...), AUTH_MODE_INSECURE)
+	tests := []struct {
+		name     string
+		function string
+		args     []string
+		code     string
+		sample   bool
+	}{
+		{"init with sample", "init", insecureSample, "", true},
+		{"init without sample", "init", []string{AUTH_MODE_INSECURE}, "", false},
+		{"bootstrap", "bootstrap", insecureSample, "", true},
+		{"bootstrap without sample arguments", "bootstrap", []string{AUTH_MODE_INSECURE}, E_ARGS, false},
+		{"upgrade of an empty ledger", "upgrade", nil, E_ILLEGAL_STATE, false},
+		{"upgrade with arguments", "upgrade", []string{AUTH_MODE_INSECURE}, E_ARGS, false},
+		{"init with unknown auth mode", "init", []string{"open"}, E_ARGS, false},
+		{"unknown mode", "deploy", insecureSample, E_ARGS, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := mockstub.NewMockStub("intermediate", new(SimpleChaincode))
+			_, err := stub.MockInit(test.function, test.args...)
+			checkCode(t, err, test.code)
+			if test.code != "" {
+				return
+			}
+
+			config := getConfig(t, stub)
+			if config.Version != CHAINCODE_VERSION || config.SchemaVersion != PKG_SCHEMA_VERSION || config.AuthMode != AUTH_MODE_INSECURE || config.DeployedAt == 0 || config.Upgrades != 0 {
+				t.Fatalf("unexpected config %+v", config)
+			}
+			if got := pkgIds(t, mustQuery(t, stub, "queryallpkg")); test.sample != equalStrings(got, []string{"1Z20170426"}) {
+				t.Fatalf("packages after deploy %v", got)
+			}
+		})
+	}
+}
+
+func TestInitKeepsExistingState(t *testing.T) {
+	stub := newTestStub(t, createP1, []string{"acceptpkg", "P1", "PRV"})
+	deployed := getConfig(t, stub)
+
+	// redeploying with other sample data upgrades, leaving the packages as they were
+	mustInit(t, stub, "init", "OTHER", "OTHER", "OTHER", "OTHER", "0", "1", "other")
+	if packageinfo := getPkg(t, stub, "1Z20170426"); packageinfo.Shipper != "SHIPPER" {
+		t.Fatalf("sample package was overwritten: %+v", packageinfo)
+	}
+	if packageinfo := getPkg(t, stub, "P1"); packageinfo.PkgStatus != STATUS_IN_TRANSIT {
+		t.Fatalf("package was overwritten: %+v", packageinfo)
+	}
+	if got := pkgIds(t, mustQuery(t, stub, "queryallpkg")); !equalStrings(got, []string{"1Z20170426", "P1"}) {
+		t.Fatalf("packages after upgrade %v", got)
+	}
+
+	mustInit(t, stub, "upgrade")
+	config := getConfig(t, stub)
+	if config.Upgrades != 2 || config.DeployedAt != deployed.DeployedAt || config.UpgradedAt <= deployed.DeployedAt || config.AuthMode != AUTH_MODE_INSECURE {
+		t.Fatalf("unexpected config %+v after %+v", config, deployed)
+	}
+
+	_, err := stub.MockInit("bootstrap", sampleArgs...)
+	checkCode(t, err, E_ILLEGAL_STATE)
+	_, err = stub.MockInit("init", AUTH_MODE_SECURE)
+	checkCode(t, err, E_ILLEGAL_STATE)
+	_, err = stub.MockInit("init", AUTH_MODE_INSECURE)
+	checkCode(t, err, "")
+}
+
+func TestUpgradeFromLegacyLedger(t *testing.T) {
+	// a deployment from before the config record: the auth mode under its own key and packages
+	stub := mockstub.NewMockStub("intermediate", new(SimpleChaincode))
+	stub.State[authModeKey] = []byte(AUTH_MODE_INSECURE)
+	key, _ := pkgKey("L1")
+	stub.State[key] = []byte(`{"packageid": "L1", "shipper": "SHP", "provider": "PRV", "Tempraturemin": 2, "Tempraturemax": 8, "pkgstatus": "In_Transit"}`)
+
+	_, err := stub.MockQuery("queryconfig")
+	checkCode(t, err, E_NOT_FOUND)
+
+	mustInit(t, stub, "init", sampleArgs...)
+	if _, ok := stub.State[authModeKey]; ok {
+		t.Fatal("legacy auth mode was not moved into the config")
+	}
+	if config := getConfig(t, stub); config.AuthMode != AUTH_MODE_INSECURE || config.Upgrades != 1 {
+		t.Fatalf("unexpected config %+v", config)
+	}
+	if got := pkgIds(t, mustQuery(t, stub, "queryallpkg")); !equalStrings(got, []string{"L1"}) {
+		t.Fatalf("packages after upgrade %v", got)
+	}
+
+	// still insecure: the provider is taken from the arguments
+	mustInvoke(t, stub, "updatetemp", "L1", "5", "PRV")
+
+	// a ledger holding nothing but packages is upgraded too, in the default secure mode
+	stub = mockstub.NewMockStub("intermediate", new(SimpleChaincode))
+	stub.State[key] = []byte(`{"packageid": "L1", "shipper": "SHP", "provider": "PRV", "Tempraturemin": 2, "Tempraturemax": 8, "pkgstatus": "In_Transit"}`)
+	mustInit(t, stub, "upgrade")
+	var config ChaincodeConfig
+	if err := json.Unmarshal(stub.State[chaincodeConfigKey], &config); err != nil || config.AuthMode != AUTH_MODE_SECURE {
+		t.Fatalf("unexpected config %+v, %v", config, err)
+	}
+}
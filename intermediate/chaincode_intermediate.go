@@ -1,9 +1,7 @@
 package main
 
 import (
-	"errors"
 	"fmt"
-	"strconv"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"encoding/json"
@@ -29,25 +27,134 @@ type PackageInfo struct {
   Insurer    string `json:"insurer"`
   Consignee  string `json:"consignee"`
   Provider      string `json:"provider"`
-  TempratureMin int `json:"Tempraturemin"`
-  TempratureMax int `json:"Tempraturemax"`
+  TempratureMin Temperature `json:"Tempraturemin"`
+  TempratureMax Temperature `json:"Tempraturemax"`
+  TempUnit   string `json:"tempunit"`
+  LegacyTemprature string `json:"legacytemprature,omitempty"`
   PackageDes string `json:"packagedes"`
   PkgStatus  string `json:"pkgstatus"`
+  ReadingSeq int `json:"readingseq"`
+  Deliveries []DeliveryAttempt `json:"deliveries,omitempty"`
+  Custodian  string `json:"custodian,omitempty"`
+  Custody    []CustodyTransfer `json:"custody,omitempty"`
+  ShipmentId string `json:"shipmentid,omitempty"`
+  ExcursionPolicy *ExcursionPolicy `json:"excursionpolicy,omitempty"`
+  Excursion  *ExcursionState `json:"excursion,omitempty"`
+  TemplateId string `json:"templateid,omitempty"`
+  TemplateVersion int `json:"templateversion,omitempty"`
+  Conditions []ConditionLimit `json:"conditions,omitempty"`
+  ConditionSeq int `json:"conditionseq,omitempty"`
+  DamageChannel string `json:"damagechannel,omitempty"`
+  SchemaVersion int `json:"schemaversion"`
+  StatusChangedAt int64 `json:"statuschangedat,omitempty"`
+  CancelReason string `json:"cancelreason,omitempty"`
+  ArchivedAt int64 `json:"archivedat,omitempty"`
+  ArchivedBy string `json:"archivedby,omitempty"`
 }
 
 //==============================================================================================================================
-//	 PkgStatus types - Asset lifecycle is broken down into 4 statuses, this is part of the business logic to determine what can
+//	 PkgStatus types - Asset lifecycle is broken down into 8 statuses, this is part of the business logic to determine what can
 //					be done to the package at points in it's lifecycle
 //==============================================================================================================================
 //  1 - Label_Generated
 //  2 - In_Transit
 //  3 - Pkg_Damaged
 //  4 - Pkg_Delivered
+//  5 - Delivery_Pending   - delivered by the Provider, waiting for the Consignee to confirm
+//  6 - Delivery_Rejected  - the Consignee refused the delivery, the Provider may deliver again
+//  7 - Temp_Warning       - a temprature excursion within the package excursion policy is in progress
+//  8 - Pkg_Cancelled      - cancelled by the Shipper before a Provider accepted it, see cancelpkg
+//==============================================================================================================================
+const (
+	STATUS_LABEL_GENERATED   = "Label_Generated"
+	STATUS_IN_TRANSIT        = "In_Transit"
+	STATUS_PKG_DAMAGED       = "Pkg_Damaged"
+	STATUS_PKG_DELIVERED     = "Pkg_Delivered"
+	STATUS_DELIVERY_PENDING  = "Delivery_Pending"
+	STATUS_DELIVERY_REJECTED = "Delivery_Rejected"
+	STATUS_TEMP_WARNING      = "Temp_Warning"
+	STATUS_PKG_CANCELLED     = "Pkg_Cancelled"
+)
+
+//==============================================================================================================================
+//	 Status transitions - Every status change made by an Invoke function must be listed here. A status that has no
+//					outgoing transitions is terminal and the package can no longer be changed. A package in
+//					Temp_Warning returns to the status it had before the excursion once a reading is back in range,
+//					and may take no other of the transitions back, see checkPkgTransition.
+//==============================================================================================================================
+var pkgTransitions = map[string][]string{
+	STATUS_LABEL_GENERATED:   {STATUS_IN_TRANSIT, STATUS_PKG_DAMAGED, STATUS_TEMP_WARNING, STATUS_PKG_CANCELLED},
+	STATUS_IN_TRANSIT:        {STATUS_DELIVERY_PENDING, STATUS_PKG_DAMAGED, STATUS_TEMP_WARNING},
+	STATUS_DELIVERY_PENDING:  {STATUS_PKG_DELIVERED, STATUS_DELIVERY_REJECTED, STATUS_PKG_DAMAGED, STATUS_TEMP_WARNING},
+	STATUS_DELIVERY_REJECTED: {STATUS_DELIVERY_PENDING, STATUS_PKG_DAMAGED, STATUS_TEMP_WARNING},
+	STATUS_TEMP_WARNING:      {STATUS_LABEL_GENERATED, STATUS_IN_TRANSIT, STATUS_DELIVERY_PENDING, STATUS_DELIVERY_REJECTED, STATUS_PKG_DAMAGED},
+	STATUS_PKG_DAMAGED:       {},
+	STATUS_PKG_DELIVERED:     {},
+	STATUS_PKG_CANCELLED:     {},
+}
 
+//==============================================================================================================================
+//	IllegalTransitionError - Returned when an Invoke function tries to move a package to a status that is not
+//				reachable from its current status.
+//==============================================================================================================================
+type IllegalTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *IllegalTransitionError) Error() string {
+	return (&ChaincodeError{Code: E_ILLEGAL_STATE, Message: e.message()}).Error()
+}
+
+func (e *IllegalTransitionError) message() string {
+	return "illegal transition from " + e.From + " to " + e.To
+}
+
+//==============================================================================================================================
+//	isValidStatus - true if the status is one of the known package statuses
+//==============================================================================================================================
+func isValidStatus(status string) bool {
+	_, ok := pkgTransitions[status]
+	return ok
+}
+
+//==============================================================================================================================
+//	isTerminalStatus - true if no further transitions are allowed out of the status
+//==============================================================================================================================
+func isTerminalStatus(status string) bool {
+	return len(pkgTransitions[status]) == 0
+}
 
 //==============================================================================================================================
-//	Package Holder - Defines the structure that holds all the PkgId for Packages that have been created.
-//				Used as an index when querying all vehicles.
+//	checkTransition - consults the transition table and returns an IllegalTransitionError if the package may not
+//				move from status from to status to
+//==============================================================================================================================
+func checkTransition(from string, to string) error {
+	for _, next := range pkgTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return &IllegalTransitionError{From: from, To: to}
+}
+
+//==============================================================================================================================
+//	checkPkgTransition - checkTransition for a package, which while in Temp_Warning may only be damaged or return to
+//				the status it had before the excursion
+//==============================================================================================================================
+func checkPkgTransition(packageinfo *PackageInfo, to string) error {
+	if packageinfo.PkgStatus == STATUS_TEMP_WARNING && to != STATUS_PKG_DAMAGED {
+		if packageinfo.Excursion == nil || to != packageinfo.Excursion.ResumeStatus {
+			return &IllegalTransitionError{From: packageinfo.PkgStatus, To: to}
+		}
+	}
+	return checkTransition(packageinfo.PkgStatus, to)
+}
+
+
+//==============================================================================================================================
+//	Package Holder - Defines the structure that holds a list of PkgIds. Returned by queryallpkgids and read from
+//				the legacy PkgIdsKey index by migratepkgindex.
 //==============================================================================================================================
 type PKG_Holder struct {
 	PkgIds 	[]string `json:"packageids"`
@@ -65,18 +172,87 @@ if err != nil {
 
 
 //==============================================================================================================================
-//	Init Function - Called when the user deploys the chaincode
+//	Init Function - Called when the user deploys the chaincode. The function selects the Init mode, see
+//		  chaincode_config.go; an Init over existing ledger state never rewrites it.
 //==============================================================================================================================
 func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 
-var jsonResp string
 var packageinfo PackageInfo
 var err error
 
 //  Validate inpit
-if len(args) != 7 {
-  jsonResp = "Error: Incorrect number of arguments. Expecting 6 in order of Shipper, Insurer, Consignee, Temprature, PackageDes, Provider "
-  return nil, errors.New(jsonResp)
+sample := len(args) == 7 || len(args) == 8
+if function == INIT_MODE_UPGRADE {
+  if len(args) != 0 {
+    return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting 0 to upgrade")
+    }
+  } else if function == INIT_MODE_BOOTSTRAP {
+  if !sample {
+    return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting 7 in order of Shipper, Insurer, Consignee, Provider, TempratureMin, TempratureMax, PackageDes and optional auth mode (secure or insecure)")
+    }
+  } else if function == INIT_MODE_INIT {
+  if !sample && len(args) > 1 {
+    return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting an optional auth mode (secure or insecure), preceded by Shipper, Insurer, Consignee, Provider, TempratureMin, TempratureMax and PackageDes to bootstrap the sample package")
+    }
+  } else {
+  return nil, newError(E_ARGS, "Received unknown Init function: " + function + ". Expecting init, bootstrap or upgrade")
+  }
+
+//  the auth mode passed, last of the arguments
+requestedmode := ""
+if len(args) == 1 || len(args) == 8 {
+  requestedmode = args[len(args)-1]
+  if requestedmode != AUTH_MODE_SECURE && requestedmode != AUTH_MODE_INSECURE {
+    return nil, newError(E_ARGS, "Auth mode must be secure or insecure")
+    }
+  }
+
+//  never clobber the state of an earlier deployment
+existing, err := hasChaincodeState(stub)
+if err != nil {
+  return nil, err
+  }
+if existing {
+  if function == INIT_MODE_BOOTSTRAP {
+    return nil, newError(E_ILLEGAL_STATE, "Ledger already holds chaincode state, bootstrap would overwrite it. Deploy with upgrade to keep it")
+    }
+  return upgradeChaincode(stub, requestedmode)
+  }
+if function == INIT_MODE_UPGRADE {
+  return nil, newError(E_ILLEGAL_STATE, "Ledger holds no chaincode state to upgrade. Deploy with init or bootstrap")
+  }
+
+timestamp, err := getTxTimestamp(stub)
+if err != nil {
+  return nil, err
+  }
+
+//  record the deployment, parties are authorized from the caller certificate unless insecure is passed
+config := ChaincodeConfig{Version: CHAINCODE_VERSION, SchemaVersion: PKG_SCHEMA_VERSION, AuthMode: AUTH_MODE_SECURE, DeployedAt: timestamp}
+if requestedmode != "" {
+  config.AuthMode = requestedmode
+  }
+
+err = putChaincodeConfig(stub, &config)
+if err != nil {
+  return nil, err
+  }
+
+//  the deployer administers the participant registry
+if config.AuthMode == AUTH_MODE_SECURE {
+  deployer, err := getCallerParty(stub)
+  if err != nil {
+    return nil, wrapError(E_INTERNAL, "Could not identify deployer to register as Admin", err)
+    }
+
+  err = putParticipant(stub, &Participant{Id: deployer, Roles: []string{ROLE_ADMIN}, Active: true})
+  if err != nil {
+    return nil, err
+    }
+  }
+
+if !sample {
+  return json.Marshal(config)
   }
 
 
@@ -86,45 +262,30 @@ packageinfo.Shipper = args[0]
 packageinfo.Insurer  = args[1]
 packageinfo.Consignee  = args[2]
 packageinfo.Provider = args[3]
-packageinfo.TempratureMin, err = strconv.Atoi(args[4])
+packageinfo.TempratureMin, err = parseTemperature(args[4])
 if err != nil {
-  jsonResp = "Error :5th argument must be a numeric string"
-  return nil, errors.New(jsonResp)
+  return nil, newError(E_ARGS, "5th argument must be a temprature such as 4.5, 4.5C or 40.1F")
 	}
-packageinfo.TempratureMax, err = strconv.Atoi(args[5])
+packageinfo.TempratureMax, err = parseTemperature(args[5])
 if err != nil {
-    jsonResp = "Error: 6th argument must be a numeric string "
-    return nil, errors.New(jsonResp)
+    return nil, newError(E_ARGS, "6th argument must be a temprature such as 4.5, 4.5C or 40.1F")
   	}
 packageinfo.PackageDes = args[6]
-packageinfo.PkgStatus = "Label_Generated"
-
-
-//  populate package holder
-var packageids_array PKG_Holder
-packageids_array.PkgIds  = append(packageids_array.PkgIds , packageinfo.PkgId)
-
-bytes, err := json.Marshal(&packageids_array)
+packageinfo.PkgStatus = STATUS_LABEL_GENERATED
 
 //  write to blockchain
-err = stub.PutState("PkgIdsKey", bytes)
-if err != nil {
-  return nil, errors.New("Error writing to blockchain for PKG_Holder")
-  }
-
-bytes, err = json.Marshal(&packageinfo)
+err = putPackageInfo(stub, &packageinfo)
 if err != nil {
-          fmt.Println("Could not marshal personal info object", err)
-          return nil, errors.New("Could not marshal personal info object")
+  return nil, err
   }
 
-//  write to blockchain
-err = stub.PutState("1Z20170426", bytes)
+// announce the sample package so that off-chain read models following from deployment see it
+err = emitPkgEvents(stub, []PkgEvent{{Event: EVENT_PKG_CREATED, PkgId: packageinfo.PkgId, NewStatus: packageinfo.PkgStatus, Actor: eventActor(stub, packageinfo.Shipper), Timestamp: timestamp}})
 if err != nil {
-  return nil, errors.New("Error writing to blockchain for Package")
+  return nil, err
   }
 
-return nil, nil
+return json.Marshal(config)
 }
 
 
@@ -135,19 +296,83 @@ return nil, nil
 func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 fmt.Println("invoke is running " + function)
 
+// check the caller holds a role allowed to call the function
+err := authorizeFunction(stub, function)
+if err != nil {
+  return nil, err
+  }
+
 // Handle different functions
 if function == "create" {
   return t.create(stub,args)
+  } else if function == "createfromtemplate" {
+  return t.createfromtemplate(stub, args)
+  } else if function == "puttemplate" {
+  return t.puttemplate(stub, args)
   } else if function == "acceptpkg"{
   return t.acceptpkg(stub,args)
   } else if function == "deliverpkg"{
   return t.deliverpkg(stub,args)
+  } else if function == "handoverpkg"{
+  return t.handoverpkg(stub,args)
+  } else if function == "confirmdelivery"{
+  return t.confirmdelivery(stub,args)
+  } else if function == "rejectdelivery"{
+  return t.rejectdelivery(stub,args)
   } else if function == "updatetemp" {
   return t.updatetemp(stub, args)
+  } else if function == "updatetempbatch" {
+  return t.updatetempbatch(stub, args)
+  } else if function == "setexcursionpolicy" {
+  return t.setexcursionpolicy(stub, args)
+  } else if function == "setconditions" {
+  return t.setconditions(stub, args)
+  } else if function == "updatecondition" {
+  return t.updatecondition(stub, args)
+  } else if function == "migratepkgindex" {
+  return t.migratepkgindex(stub, args)
+  } else if function == "rebuildpkgindex" {
+  return t.rebuildpkgindex(stub, args)
+  } else if function == "migrate" {
+  return t.migrate(stub, args)
+  } else if function == "fileclaim" {
+  return t.fileclaim(stub, args)
+  } else if function == "approveclaim" {
+  return t.approveclaim(stub, args)
+  } else if function == "rejectclaim" {
+  return t.rejectclaim(stub, args)
+  } else if function == "payclaim" {
+  return t.payclaim(stub, args)
+  } else if function == "registerparticipant" {
+  return t.registerparticipant(stub, args)
+  } else if function == "assignrole" {
+  return t.assignrole(stub, args)
+  } else if function == "revokerole" {
+  return t.revokerole(stub, args)
+  } else if function == "revokeparticipant" {
+  return t.revokeparticipant(stub, args)
+  } else if function == "createshipment" {
+  return t.createshipment(stub, args)
+  } else if function == "addtoshipment" {
+  return t.addtoshipment(stub, args)
+  } else if function == "removefromshipment" {
+  return t.removefromshipment(stub, args)
+  } else if function == "sealshipment" {
+  return t.sealshipment(stub, args)
+  } else if function == "unsealshipment" {
+  return t.unsealshipment(stub, args)
+  } else if function == "updateshipmentstatus" {
+  return t.updateshipmentstatus(stub, args)
+  } else if function == "updateshipmenttemp" {
+  return t.updateshipmenttemp(stub, args)
+  } else if function == "cancelpkg" {
+  return t.cancelpkg(stub, args)
+  } else if function == "archivepkg" {
+  return t.archivepkg(stub, args)
   }
 
 fmt.Println("invoke did not find func: " + function)
-return nil, errors.New("Received unknown function invocation: " + function)
+return nil, newError(E_ARGS, "Received unknown function invocation: " + function)
 
 }
 
@@ -158,12 +383,17 @@ return nil, errors.New("Received unknown function invocation: " + function)
 func (t *SimpleChaincode) create(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 fmt.Println("running create()")
 
-var key, jsonResp string
+var key string
 var err error
 
 if len(args) != 8 {
-  jsonResp = " Error: Incorrect number of arguments. Expecting 8 in order of PkgID, Shipper, Insurer, Consignee, TempratureMin, TempratureMax, PackageDes, Provider "
-  return nil, errors.New(jsonResp)
+  return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting 8 in order of PkgID, Shipper, Insurer, Consignee, TempratureMin, TempratureMax, PackageDes, Provider")
+  }
+
+// in secure mode a Shipper can only create packages in its own name
+err = authorizeParty(stub, args, 1, args[1])
+if err != nil {
+  return nil, wrapError(E_FORBIDDEN, "Wrong Shipper - Can not create a package for another Shipper", err)
   }
 
 var packageinfo PackageInfo
@@ -174,54 +404,40 @@ packageinfo.PkgId  = args[0]
 packageinfo.Shipper = args[1]
 packageinfo.Insurer = args[2]
 packageinfo.Consignee  = args[3]
-packageinfo.TempratureMin , err = strconv.Atoi(args[4])
+packageinfo.TempratureMin , err = parseTemperature(args[4])
 if err != nil {
-  jsonResp = " Error: 5th argument must be a numeric string "
-  return nil, errors.New(jsonResp)
+  return nil, newError(E_ARGS, "5th argument must be a temprature such as 4.5, 4.5C or 40.1F")
 	}
-packageinfo.TempratureMax  , err = strconv.Atoi(args[5])
+packageinfo.TempratureMax  , err = parseTemperature(args[5])
 if err != nil {
-  jsonResp = " Error: 5th argument must be a numeric string "
-  return nil, errors.New(jsonResp)
+  return nil, newError(E_ARGS, "6th argument must be a temprature such as 4.5, 4.5C or 40.1F")
 	}
 packageinfo.PackageDes = args[6]
 packageinfo.Provider = args[7]
-packageinfo.PkgStatus = "Label_Generated"   // Label_Generated
+packageinfo.PkgStatus = STATUS_LABEL_GENERATED
 
-bytes, err := json.Marshal(&packageinfo)
+// check for duplicate package id
+exists, err := packageExists(stub, key)
 if err != nil {
-        fmt.Println("Could not marshal personal info object", err)
-        return nil, err
+  return nil, err
   }
 
-// check for duplicate package id
-valAsbytes, err := stub.GetState(key)
-
-if valAsbytes != nil {
-  jsonResp = " Package already present on blockchain " + key
-  return nil, errors.New(jsonResp)
+if exists {
+  return nil, newError(E_DUPLICATE, "Package already present on blockchain " + key)
   }
 
-//  populate package holder
-var packageids_array PKG_Holder
-packageids_arrayasbytes, err := stub.GetState("PkgIdsKey")
-
-err = json.Unmarshal(packageids_arrayasbytes, &packageids_array)
+timestamp, err := getTxTimestamp(stub)
 if err != nil {
-      fmt.Println("Could not marshal pkgid array object", err)
-      return nil, err
+  return nil, err
   }
 
-packageids_array.PkgIds  = append(packageids_array.PkgIds , packageinfo.PkgId)
-packageids_arrayasbytes, err = json.Marshal(&packageids_array)
-
 //  write to blockchain
-err = stub.PutState("PkgIdsKey", packageids_arrayasbytes)
+err = putPackageInfo(stub, &packageinfo)
 if err != nil {
-    return nil, errors.New("Error writing to blockchain for PKG_Holder")
+  return nil, err
   }
 
-err = stub.PutState(key, bytes)
+err = emitPkgEvents(stub, []PkgEvent{{Event: EVENT_PKG_CREATED, PkgId: packageinfo.PkgId, NewStatus: packageinfo.PkgStatus, Actor: eventActor(stub, packageinfo.Shipper), Timestamp: timestamp}})
 if err != nil {
   return nil, err
   }
@@ -234,57 +450,54 @@ return nil, nil
 //=================================================================================================================================
 func (t *SimpleChaincode) acceptpkg(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 fmt.Println("running acceptpkg()")
-var key , jsonResp string
+var key string
 var err error
 
-if len(args) != 2 {
-	jsonResp = " Error:Incorrect number of arguments. Expecting : PkgId and Provider "
-  	return nil, errors.New(jsonResp)
+if len(args) != 1 && len(args) != 2 {
+  	return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting : PkgId and, in insecure mode, Provider")
   }
 
   key = args[0]
-  var packageinfo PackageInfo
-
-  valAsbytes, err := stub.GetState(key)
 
+  // read the package, failing if it does not exist
+  packageinfo, err := getPackageInfo(stub, key)
   if err != nil {
-    jsonResp = " Error Failed to get state for " + key
-    return nil, errors.New(jsonResp)
+    return nil, err
     }
 
-  err = json.Unmarshal(valAsbytes, &packageinfo)
+  // check wheather the package can move to In_Transit from its current status
+  err = checkPkgTransition(&packageinfo, STATUS_IN_TRANSIT)
   if err != nil {
-        fmt.Println("Could not marshal personal info object", err)
-        return nil, err
-    }
-
-// validate pkd exist or not by checking temprature
-  if packageinfo.PkgId != key{
-    jsonResp = "Error: Invalid PackageId Passed "
-    return nil, errors.New(jsonResp)
+    return nil, err
     }
 
-  // check wheather the pkg temprature is in acceptable range and package in in valid status
-  if packageinfo.PkgStatus == "Pkg_Damaged" {    // Pkg_Damaged
-	  jsonResp = "Error : Temprature thershold crossed - Package Damaged "
-          return nil, errors.New(jsonResp)
+  // packages of a sealed shipment only move with the shipment
+  err = checkNotSealed(stub, &packageinfo)
+  if err != nil {
+    return nil, err
     }
 
-	if packageinfo.Provider != args[1] {    // Pkg_Damaged
-		  jsonResp = "Error : Wrong Provider passed - Can not accept the package "
-	          return nil, errors.New(jsonResp)
+	// check the caller is the carrier currently holding the package
+	err = authorizeParty(stub, args, 1, currentCustodian(&packageinfo))
+	if err != nil {
+	          return nil, wrapError(E_FORBIDDEN, "Wrong Provider - Can not accept the package", err)
 	    }
 
+  timestamp, err := getTxTimestamp(stub)
+  if err != nil {
+    return nil, err
+    }
+
   //packageinfo.Provider = args[1]
-  packageinfo.PkgStatus = "In_Transit"
+  oldStatus := packageinfo.PkgStatus
+  packageinfo.PkgStatus = STATUS_IN_TRANSIT
 
-  bytes, err := json.Marshal(&packageinfo)
+  err = putPackageInfo(stub, &packageinfo)
   if err != nil {
-          fmt.Println("Could not marshal personal info object", err)
-          return nil, err
+    return nil, err
     }
 
-  err = stub.PutState(key, bytes)
+  err = emitPkgEvents(stub, []PkgEvent{{Event: EVENT_PKG_ACCEPTED, PkgId: packageinfo.PkgId, OldStatus: oldStatus, NewStatus: packageinfo.PkgStatus, Actor: eventActor(stub, currentCustodian(&packageinfo)), Timestamp: timestamp}})
   if err != nil {
     return nil, err
     }
@@ -295,67 +508,59 @@ if len(args) != 2 {
 
 
 //=================================================================================================================================
-//	deliverpkg - deliver package to cosignee, change status of the package
+//	deliverpkg - deliver package to cosignee, the package waits in Delivery_Pending until the consignee confirms
 //=================================================================================================================================
 func (t *SimpleChaincode) deliverpkg(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 fmt.Println("running deliverpkg()")
-var key , jsonResp string
+var key string
 var err error
 
-if len(args) != 2 {
-	jsonResp = " Error : Incorrect number of arguments. Expecting 2 : PkgId and Provider "
-  	return nil, errors.New(jsonResp)
+if len(args) != 1 && len(args) != 2 {
+  	return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId and, in insecure mode, Provider")
   }
 
   key = args[0]
-  var packageinfo PackageInfo
-
-  valAsbytes, err := stub.GetState(key)
 
+  // read the package, failing if it does not exist
+  packageinfo, err := getPackageInfo(stub, key)
   if err != nil {
-    jsonResp = "Error : Failed to get state for " + key
-    return nil, errors.New(jsonResp)
+    return nil, err
     }
 
-  err = json.Unmarshal(valAsbytes, &packageinfo)
+  // check wheather the package can move to Delivery_Pending from its current status
+  err = checkPkgTransition(&packageinfo, STATUS_DELIVERY_PENDING)
   if err != nil {
-        fmt.Println("Could not marshal personal info object", err)
-        return nil, err
-    }
-
-// validate pkd exist or not by checking temprature
-  if packageinfo.PkgId != key{
-    jsonResp = "Error: Invalid PackageId Passed "
-    return nil, errors.New(jsonResp)
+    return nil, err
     }
 
-  // check wheather the pkg temprature is in acceptable range and package in in valid status
-  if packageinfo.PkgStatus == "Pkg_Damaged" {    // Pkg_Damaged
-	  jsonResp = " Error: Temprature thershold crossed - Package Damaged"
-          return nil, errors.New(jsonResp)
+  // packages of a sealed shipment only move with the shipment
+  err = checkNotSealed(stub, &packageinfo)
+  if err != nil {
+    return nil, err
     }
 
-	if packageinfo.PkgStatus == "Pkg_Delivered" {    // Pkg_Damaged
-	  jsonResp = " Error: Package Already Delivered"
-	  return nil, errors.New(jsonResp)
+ // check wheather the caller is the carrier currently holding the package
+err = authorizeParty(stub, args, 1, currentCustodian(&packageinfo))
+if err != nil {
+	  return nil, wrapError(E_FORBIDDEN, "Wrong Pkg Provider - Not authorized to deliver this Package", err)
 	  }
 
- // check wheather the pkg Provider is same as input value
-if packageinfo.Provider != args[1] {
-	  jsonResp = " Error :Wrong Pkg Provider passrd - Not authorized to deliver this Package"
-	  return nil, errors.New(jsonResp)
-	  }
+  timestamp, err := getTxTimestamp(stub)
+  if err != nil {
+    return nil, err
+    }
 
-//  packageinfo.Owner = args[1]
-  packageinfo.PkgStatus = "Pkg_Delivered"
+//  open a new delivery attempt for the consignee to confirm or reject
+  oldStatus := packageinfo.PkgStatus
+  packageinfo.PkgStatus = STATUS_DELIVERY_PENDING
+  packageinfo.Deliveries = append(packageinfo.Deliveries, DeliveryAttempt{DeliveredBy: currentCustodian(&packageinfo), SubmittedAt: timestamp})
 
-  bytes, err := json.Marshal(&packageinfo)
+  err = putPackageInfo(stub, &packageinfo)
   if err != nil {
-          fmt.Println("Could not marshal personal info object", err)
-          return nil, err
+    return nil, err
     }
 
-  err = stub.PutState(key, bytes)
+  err = emitPkgEvents(stub, []PkgEvent{{Event: EVENT_PKG_DELIVERED, PkgId: packageinfo.PkgId, OldStatus: oldStatus, NewStatus: packageinfo.PkgStatus, Actor: eventActor(stub, currentCustodian(&packageinfo)), Timestamp: timestamp}})
   if err != nil {
     return nil, err
     }
@@ -367,66 +572,70 @@ if packageinfo.Provider != args[1] {
 
 
 //=================================================================================================================================
-//	updatetemp - update pkg status based on the supplied temprature
+//	updatetemp - update pkg status based on the supplied temprature, reported by the carrier currently holding the
+//				package. Expects PkgId, temprature, Provider (only checked in insecure mode) and optional sensor id.
 //=================================================================================================================================
 
 func (t *SimpleChaincode) updatetemp(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-var key , jsonResp string
+var key string
 var err error
 fmt.Println("running updatetemp()")
 
-if len(args) != 2 {
-  jsonResp = "Error :Incorrect number of arguments. Expecting 2. name of the key and temprature value to set"
-  return nil, errors.New(jsonResp)
+if len(args) < 2 || len(args) > 4 {
+  return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting 2 to 4. name of the key, temprature value to set, Provider (in insecure mode) and optional sensor id")
   }
 
 
 key = args[0]
-var packageinfo PackageInfo
-var temprature_reading int
-
-valAsbytes, err := stub.GetState(key)
+var temprature_reading Temperature
 
+// read the package, failing if it does not exist
+packageinfo, err := getPackageInfo(stub, key)
 if err != nil {
-  jsonResp = "Error :Failed to get state for " + key
-  return nil, errors.New(jsonResp)
+  return nil, err
   }
 
-err = json.Unmarshal(valAsbytes, &packageinfo)
+// packages of a sealed shipment only take readings through updateshipmenttemp
+err = checkNotSealed(stub, &packageinfo)
 if err != nil {
-      fmt.Println("Could not marshal  info object", err)
-      return nil, err
-  }
-// validate pkd exist or not by checking temprature
-if packageinfo.PkgId != key{
-  jsonResp = " Error : Invalid PackageId Passed "
-  return nil, errors.New(jsonResp)
+  return nil, err
   }
 
-// check wheather the pkg temprature is in acceptable range and package in in valid status
-if packageinfo.PkgStatus == "Pkg_Damaged" {
-  jsonResp = " Error :Temprature thershold crossed - Package Damaged"
-  return nil, errors.New(jsonResp)
+// check the caller is the carrier currently holding the package
+err = authorizeParty(stub, args, 2, currentCustodian(&packageinfo))
+if err != nil {
+  return nil, wrapError(E_FORBIDDEN, "Wrong Provider - Not the current custodian of this Package", err)
   }
 
-temprature_reading, err = strconv.Atoi(args[1])
+temprature_reading, err = parseTemperature(args[1])
 if err != nil {
-	jsonResp = " Error : 2nd argument must be a numeric string"
-  	return nil, errors.New(jsonResp)
+  	return nil, newError(E_ARGS, "2nd argument must be a temprature such as 4.5, 4.5C or 40.1F")
 	}
 
+var sensorid string
+if len(args) == 4 {
+  sensorid = args[3]
+  }
+
+timestamp, err := getTxTimestamp(stub)
+if err != nil {
+  return nil, err
+  }
 
-if temprature_reading > packageinfo.TempratureMax  || temprature_reading < packageinfo.TempratureMin  {
-    packageinfo.PkgStatus = "Pkg_Damaged"
+// apply the reading to the package status and keep it in the package temprature history
+oldStatus := packageinfo.PkgStatus
+_, err = recordTempReading(stub, &packageinfo, temprature_reading, sensorid, timestamp)
+if err != nil {
+  return nil, err
   }
 
-bytes, err := json.Marshal(&packageinfo)
+err = putPackageInfo(stub, &packageinfo)
 if err != nil {
-        fmt.Println("Could not marshal personal info object", err)
-        return nil, err
+  return nil, err
   }
 
-err = stub.PutState(key, bytes)
+event := PkgEvent{Event: readingEventName(EVENT_PKG_TEMP_READING, packageinfo.PkgStatus), PkgId: packageinfo.PkgId, OldStatus: oldStatus, NewStatus: packageinfo.PkgStatus, Actor: eventActor(stub, currentCustodian(&packageinfo)), Reading: &temprature_reading, Timestamp: timestamp}
+err = emitPkgEvents(stub, []PkgEvent{event})
 if err != nil {
   return nil, err
   }
@@ -441,6 +650,12 @@ return nil, nil
 func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 fmt.Println("query is running " + function)
 
+// check the caller holds a role allowed to call the function
+err := authorizeFunction(stub, function)
+if err != nil {
+  return nil, err
+  }
+
 // Handle different functions
 if function == "querypkgbyid" {
   return t.querypkgbyid(stub, args)
@@ -458,10 +673,28 @@ if function == "querypkgbyid" {
   return t.querybyrole(stub, args)
   } else if function == "querybyrole_status"{
   return t.querybyrole_status(stub, args)
+  } else if function == "querytemphistory"{
+  return t.querytemphistory(stub, args)
+  } else if function == "queryconditionhistory"{
+  return t.queryconditionhistory(stub, args)
+  } else if function == "querypkgs"{
+  return t.querypkgs(stub, args)
+  } else if function == "queryparticipant"{
+  return t.queryparticipant(stub, args)
+  } else if function == "queryclaims"{
+  return t.queryclaims(stub, args)
+  } else if function == "queryshipment"{
+  return t.queryshipment(stub, args)
+  } else if function == "querytemplate"{
+  return t.querytemplate(stub, args)
+  } else if function == "querytemplates"{
+  return t.querytemplates(stub, args)
+  } else if function == "queryconfig"{
+  return t.queryconfig(stub, args)
   }
 
 fmt.Println("query did not find func: " + function)
-return nil, errors.New("Received unknown function query: " + function)
+return nil, newError(E_ARGS, "Received unknown function query: " + function)
 }
 
 
@@ -469,44 +702,18 @@ return nil, errors.New("Received unknown function query: " + function)
 //	querypkgbyid - query function to read key/value pair
 //=================================================================================================================================
 func (t *SimpleChaincode) querypkgbyid(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-var key, jsonResp string
-var err error
-
-key = args[0]
 
 if len(args) != 1 {
-  jsonResp = " Error: Incorrect number of arguments. Expecting PkgID to query  " + key
-	//return nil, errors.New("Incorrect number of arguments. Expecting name of the key to query")
-  return nil, errors.New(jsonResp)
+  return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgID to query")
   }
 
-valAsbytes, err := stub.GetState(key)
+// archived packages are still returned, see archive.go
+packageinfo, err := getPackageRecord(stub, args[0])
 if err != nil {
-  jsonResp = "Error :Failed to get state for " + key
-  return nil, errors.New(jsonResp)
-}
-
-if valAsbytes == nil {
-  jsonResp = " Error: Invalid PackageId Passed " + key
-  return nil, errors.New(jsonResp)
+  return nil, err
   }
 
-var packageinfo PackageInfo
-err = json.Unmarshal(valAsbytes, &packageinfo)
-if err != nil {
-      fmt.Println("Could not marshal personal info object", err)
-      jsonResp = " Error :Could not marshal personal info object"
-      return nil, errors.New(jsonResp)
-}
-
-// validate pkg exist or not by checking temprature
-if packageinfo.PkgId != key{
-	  fmt.Println("Invalid PackageId Passed")
-	  jsonResp = " Error :Invalid PackageId Passed " + key
-          return nil, errors.New(jsonResp)
-    }
-
-return valAsbytes, nil
+return json.Marshal(&packageinfo)
 }
 
 //=================================================================================================================================
@@ -514,26 +721,50 @@ return valAsbytes, nil
 //=================================================================================================================================
 func (t *SimpleChaincode) queryallpkgids(stub shim.ChaincodeStubInterface, args []string) ([]byte, error){
 
-var jsonResp string
-var err error
+opts, err := parsePkgListOptions(args)
+if err != nil {
+    return nil, err
+    }
 
-if len(args) != 0 {
-    jsonResp = " Error: Incorrect number of arguments "
-    return nil, errors.New(jsonResp)
+startKey, endKey, err := compositeKeyRange(packageObjectType, []string{})
+if err != nil {
+    return nil, err
     }
 
-valAsbytes, err := stub.GetState("PkgIdsKey")
+collect := func(key string, value []byte) (json.RawMessage, error) {
+    _, attributes, err := splitCompositeKey(key)
+    if err != nil {
+        return nil, err
+        }
+    return json.Marshal(attributes[0])
+    }
+
+sources, err := withArchivedPackages(opts, []scanSource{{startKey, endKey, collect}}, collect)
 if err != nil {
-    jsonResp = "Error: Failed to get state for PkgIdsKey "
-    return nil, errors.New(jsonResp)
+    return nil, err
     }
 
-if valAsbytes == nil {
-    jsonResp = "Error: Invalid PackageId Passed fpr PkgIdsKey "
-    return nil, errors.New(jsonResp)
+items, bookmark, hasMore, err := scanRanges(stub, sources, opts)
+if err != nil {
+    return nil, err
     }
 
-return valAsbytes, nil
+if opts.Paged {
+    return listResult(opts, items, bookmark, hasMore)
+    }
+
+var packageids_array PKG_Holder
+packageids_array.PkgIds = []string{}
+for _, item := range items {
+    var pkgId string
+    err = json.Unmarshal(item, &pkgId)
+    if err != nil {
+        return nil, wrapError(E_DECODE, "Could not unmarshal package id", err)
+        }
+    packageids_array.PkgIds = append(packageids_array.PkgIds, pkgId)
+    }
+
+return json.Marshal(&packageids_array)
 
 }
 
@@ -542,127 +773,39 @@ return valAsbytes, nil
 //=================================================================================================================================
 func (t *SimpleChaincode) queryallpkg(stub shim.ChaincodeStubInterface, args []string) ([]byte, error){
 
-  var jsonResp string
-  var err error
-
-  if len(args) != 0 {
-      jsonResp = "Error: Incorrect number of arguments."
-      return nil, errors.New(jsonResp)
-      }
 
-  valAsbytes, err := stub.GetState("PkgIdsKey")
-  if err != nil {
-      jsonResp = "Error:Failed to get state for PkgIdsKey "
-      return nil, errors.New(jsonResp)
+  if len(args) > 3 {
+      return nil, newError(E_ARGS, "Incorrect number of arguments. Optional includeArchived, PageSize and Bookmark")
       }
 
-  var package_holder PKG_Holder
-  err = json.Unmarshal(valAsbytes, &package_holder)
+  opts, err := parsePkgListOptions(args)
   if err != nil {
-            fmt.Println("Could not marshal personal info object", err)
-            jsonResp = "Error: Could not marshal personal info object"
-            return nil, errors.New(jsonResp)
+      return nil, err
       }
 
-  var pkginfo PackageInfo
-
-  result := "["
-
-  var temp []byte
-
-  for _, PkgId := range package_holder.PkgIds  {
-
-    pkginfoasbytes, err := stub.GetState(PkgId)
-    if err != nil {
-      jsonResp = "Error:Failed to get state for " + PkgId
-      return nil, errors.New(jsonResp)
-    }
-
-    err = json.Unmarshal(pkginfoasbytes, &pkginfo);
-    if err != nil {
-              fmt.Println("Could not marshal personal info object", err)
-              jsonResp = "Error: Could not marshal personal info object"
-              return nil, errors.New(jsonResp)
-    }
-
-    temp = pkginfoasbytes
-    result += string(temp) + ","
-
-  }
-
-  if len(result) == 1 {
-    result = "[]"
-  } else {
-    result = result[:len(result)-1] + "]"
-  }
-
-  return []byte(result), nil
+  return scanPackages(stub, opts, func(pkginfo PackageInfo) bool {
+    return true
+  })
 
 }
+
 //=================================================================================================================================
-//	querypkgbyprovider- query function to read key/value pair by given Provider
+//	querypkgbyprovider- query function to read key/value pair by given Provider, the carrier currently holding the package
 //=================================================================================================================================
 func (t *SimpleChaincode) querypkgbyprovider(stub shim.ChaincodeStubInterface, args []string) ([]byte, error){
 
 
-    var jsonResp string
-    var err error
-
-    if len(args) != 1 {
-        jsonResp = "Error:Incorrect number of arguments. Need to pass Provider"
-        return nil, errors.New(jsonResp)
+    if len(args) < 1 || len(args) > 4 {
+        return nil, newError(E_ARGS, "Incorrect number of arguments. Need to pass Provider and optional includeArchived, PageSize and Bookmark")
         }
 
-    valAsbytes, err := stub.GetState("PkgIdsKey")
+    opts, err := parsePkgListOptions(args[1:])
     if err != nil {
-        jsonResp = "Error:Failed to get state for PkgIdsKey "
-        return nil, errors.New(jsonResp)
-        }
-
-    var package_holder PKG_Holder
-    err = json.Unmarshal(valAsbytes, &package_holder)
-    if err != nil {
-              fmt.Println("Could not marshal personal info object", err)
-              jsonResp = "Error:Could not marshal personal info object"
-              return nil, errors.New(jsonResp)
+        return nil, err
         }
 
-    var pkginfo PackageInfo
-
-    result := "["
-
-    var temp []byte
-
-    for _, PkgId := range package_holder.PkgIds  {
-
-      pkginfoasbytes, err := stub.GetState(PkgId)
-      if err != nil {
-        jsonResp = " Error:Failed to get state for " + PkgId
-        return nil, errors.New(jsonResp)
-      }
-
-      err = json.Unmarshal(pkginfoasbytes, &pkginfo);
-      if err != nil {
-                fmt.Println("Could not marshal personal info object", err)
-                jsonResp = "Error: Could not marshal personal info object "
-                return nil, errors.New(jsonResp)
-      }
-
-  // check for inout owner
-      if pkginfo.Provider == args[0] {
-        temp = pkginfoasbytes
-        result += string(temp) + ","
-      }
-
-    }
-
-    if len(result) == 1 {
-      result = "[]"
-    } else {
-      result = result[:len(result)-1] + "]"
-    }
-
-    return []byte(result), nil
+  // read the packages of the Provider from the role index
+    return scanPackageIndex(stub, pkgRoleIndex, []string{ROLE_PROVIDER, args[0]}, opts)
 
 }
 
@@ -671,64 +814,18 @@ func (t *SimpleChaincode) querypkgbyprovider(stub shim.ChaincodeStubInterface, a
 //=================================================================================================================================
 func (t *SimpleChaincode) querypkgbyshipper(stub shim.ChaincodeStubInterface, args []string) ([]byte, error){
 
-  var jsonResp string
-  var err error
-
-  if len(args) != 1 {
-      jsonResp = "Error: Incorrect number of arguments. Need to pass Shipper "
-      return nil, errors.New(jsonResp)
-      }
 
-  valAsbytes, err := stub.GetState("PkgIdsKey")
-  if err != nil {
-      jsonResp = "Error: Failed to get state for PkgIdsKey "
-      return nil, errors.New(jsonResp)
+  if len(args) < 1 || len(args) > 4 {
+      return nil, newError(E_ARGS, "Incorrect number of arguments. Need to pass Shipper and optional includeArchived, PageSize and Bookmark")
       }
 
-  var package_holder PKG_Holder
-  err = json.Unmarshal(valAsbytes, &package_holder)
+  opts, err := parsePkgListOptions(args[1:])
   if err != nil {
-            fmt.Println("Could not marshal personal info object", err)
-            jsonResp = " Error:Could not marshal personal info object"
-            return nil, errors.New(jsonResp)
+      return nil, err
       }
 
-  var pkginfo PackageInfo
-
-  result := "["
-
-  var temp []byte
-
-  for _, PkgId := range package_holder.PkgIds  {
-
-    pkginfoasbytes, err := stub.GetState(PkgId)
-    if err != nil {
-      jsonResp = "Error:Failed to get state for " + PkgId
-      return nil, errors.New(jsonResp)
-    }
-
-    err = json.Unmarshal(pkginfoasbytes, &pkginfo);
-    if err != nil {
-              fmt.Println("Could not marshal personal info object", err)
-              jsonResp = " Error:Could not marshal personal info object"
-              return nil, errors.New(jsonResp)
-    }
-
-// check for inout Shipper
-    if pkginfo.Shipper == args[0] {
-      temp = pkginfoasbytes
-      result += string(temp) + ","
-    }
-
-  }
-
-  if len(result) == 1 {
-    result = "[]"
-  } else {
-    result = result[:len(result)-1] + "]"
-  }
-
-  return []byte(result), nil
+// read the packages of the Shipper from the role index
+  return scanPackageIndex(stub, pkgRoleIndex, []string{ROLE_SHIPPER, args[0]}, opts)
 
 }
 
@@ -737,64 +834,18 @@ func (t *SimpleChaincode) querypkgbyshipper(stub shim.ChaincodeStubInterface, ar
 //=================================================================================================================================
 func (t *SimpleChaincode) querybypkgstatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error){
 
-  var jsonResp string
-  var err error
 
-  if len(args) != 1 {
-      jsonResp = "Error: Incorrect number of arguments. Need to pass status"
-      return nil, errors.New(jsonResp)
+  if len(args) < 1 || len(args) > 4 {
+      return nil, newError(E_ARGS, "Incorrect number of arguments. Need to pass status and optional includeArchived, PageSize and Bookmark")
       }
 
-  valAsbytes, err := stub.GetState("PkgIdsKey")
+  opts, err := parsePkgListOptions(args[1:])
   if err != nil {
-      jsonResp = " Error:Failed to get state for PkgIdsKey "
-      return nil, errors.New(jsonResp)
-      }
-
-  var package_holder PKG_Holder
-  err = json.Unmarshal(valAsbytes, &package_holder)
-  if err != nil {
-            fmt.Println("Could not marshal personal info object", err)
-            jsonResp = " Error: Could not marshal personal info object"
-            return nil, errors.New(jsonResp)
+      return nil, err
       }
 
-  var pkginfo PackageInfo
-
-  result := "["
-
-  var temp []byte
-
-  for _, PkgId := range package_holder.PkgIds  {
-
-    pkginfoasbytes, err := stub.GetState(PkgId)
-    if err != nil {
-      jsonResp = "Error:Failed to get state for " + PkgId
-      return nil, errors.New(jsonResp)
-    }
-
-    err = json.Unmarshal(pkginfoasbytes, &pkginfo);
-    if err != nil {
-              fmt.Println("Could not marshal personal info object", err)
-              jsonResp = "Error: Could not marshal personal info object "
-              return nil, errors.New(jsonResp)
-    }
-
-// check for inout status
-    if pkginfo.PkgStatus  == args[0] {
-      temp = pkginfoasbytes
-      result += string(temp) + ","
-    }
-
-  }
-
-  if len(result) == 1 {
-    result = "[]"
-  } else {
-    result = result[:len(result)-1] + "]"
-  }
-
-  return []byte(result), nil
+// read the packages in the status from the status index
+  return scanPackageIndex(stub, pkgStatusIndex, []string{args[0]}, opts)
 
 }
 
@@ -803,96 +854,25 @@ func (t *SimpleChaincode) querybypkgstatus(stub shim.ChaincodeStubInterface, arg
 //=================================================================================================================================
 func (t *SimpleChaincode) querybyrole(stub shim.ChaincodeStubInterface, args []string) ([]byte, error){
 
-  var jsonResp string
-  var err error
 
-  if len(args) != 2 {
-      jsonResp = "Error:Incorrect number of arguments. Need to pass Role: Shipper, Provider, Insurer or Consignee & status value to be passed"
-      return nil, errors.New(jsonResp)
+  if len(args) < 2 || len(args) > 5 {
+      return nil, newError(E_ARGS, "Incorrect number of arguments. Need to pass Role: Shipper, Provider, Insurer or Consignee & value to be passed, and optional includeArchived, PageSize and Bookmark")
       }
 
-// validate role
-  if args[0] == "Shipper"{
-  fmt.Println("Shipper has been passed as Role")
-  } else if args[0] == "Provider" {
-  fmt.Println("Provider has been passed as Role")
-  } else if args[0] == "Insurer" {
-  fmt.Println("Insurer has been passed as Role")
-  } else if args[0] == "Consignee" {
-  fmt.Println("Consignee has been passed as Role")
-  } else {
-    jsonResp = " Error:Incorrect Role has been passed, should be: Shipper, Provider, Insurer or Consignee"
-    return nil, errors.New(jsonResp)
-  }
-
-  valAsbytes, err := stub.GetState("PkgIdsKey")
+  opts, err := parsePkgListOptions(args[2:])
   if err != nil {
-      jsonResp = "Error:Failed to get state for PkgIdsKey "
-      return nil, errors.New(jsonResp)
-      }
-
-  var package_holder PKG_Holder
-  err = json.Unmarshal(valAsbytes, &package_holder)
-  if err != nil {
-            fmt.Println("Could not marshal personal info object", err)
-            jsonResp = "Error:Could not marshal personal info object"
-            return nil, errors.New(jsonResp)
-      }
-
-  var pkginfo PackageInfo
-
-  result := "["
-
-  var temp []byte
-
-  for _, PkgId := range package_holder.PkgIds  {
-
-    pkginfoasbytes, err := stub.GetState(PkgId)
-    if err != nil {
-      jsonResp = "Error:Failed to get state for " + PkgId
-      return nil, errors.New(jsonResp)
-    }
-
-    err = json.Unmarshal(pkginfoasbytes, &pkginfo);
-    if err != nil {
-              fmt.Println("Could not marshal personal info object", err)
-              jsonResp = "Error:Could not marshal personal info object"
-              return nil, errors.New(jsonResp)
-    }
-
-    // check for inout role
-    if args[0] == "Provider"{
-      if pkginfo.Provider == args[1] {
-        temp = pkginfoasbytes
-        result += string(temp) + ","
-      }
-    } else if args[0] == "Shipper" {
-      if pkginfo.Shipper == args[1] {
-        temp = pkginfoasbytes
-        result += string(temp) + ","
-      }
-    } else if args[0] == "Insurer" {
-      if pkginfo.Insurer == args[1] {
-        temp = pkginfoasbytes
-        result += string(temp) + ","
-      }
-    } else if args[0] == "Consignee" {
-      if pkginfo.Consignee == args[1] {
-        temp = pkginfoasbytes
-        result += string(temp) + ","
+      return nil, err
       }
-    }
-
 
-  } // end of for loop
-
-  if len(result) == 1 {
-    result = "[]"
+// validate role
+  if containsString(pkgRoles, args[0]) {
+  fmt.Println(args[0] + " has been passed as Role")
   } else {
-    result = result[:len(result)-1] + "]"
+    return nil, newError(E_ARGS, "Incorrect Role has been passed, should be: Shipper, Provider, Insurer or Consignee")
   }
 
-  return []byte(result), nil
+  // read the packages of the party from the role index
+  return scanPackageIndex(stub, pkgRoleIndex, []string{args[0], args[1]}, opts)
 
 }
 
@@ -902,117 +882,48 @@ func (t *SimpleChaincode) querybyrole(stub shim.ChaincodeStubInterface, args []s
 //=================================================================================================================================
 func (t *SimpleChaincode) querybyrole_status(stub shim.ChaincodeStubInterface, args []string) ([]byte, error){
 
-  var jsonResp string
-  var err error
 
-  if len(args) != 3 {
-      jsonResp = "Error: Incorrect number of arguments. Need to pass Role, Value and Status "
-      return nil, errors.New(jsonResp)
+  if len(args) < 3 || len(args) > 6 {
+      return nil, newError(E_ARGS, "Incorrect number of arguments. Need to pass Role, Value and Status and optional includeArchived, PageSize and Bookmark")
+      }
+
+  opts, err := parsePkgListOptions(args[3:])
+  if err != nil {
+      return nil, err
       }
 
 // validate role
-  if args[0] == "Shipper"{
-  fmt.Println("Shipper has been passed as Role")
-  } else if args[0] == "Provider" {
-  fmt.Println("Provider has been passed as Role")
-  } else if args[0] == "Insurer" {
-  fmt.Println("Insurer has been passed as Role")
-  } else if args[0] == "Consignee" {
-  fmt.Println("Consignee has been passed as Role")
+  if containsString(pkgRoles, args[0]) {
+  fmt.Println(args[0] + " has been passed as Role")
   } else {
-    jsonResp = "Error:Incorrect Role has been passed, should be: Shipper, Provider, Insurer or Consignee"
-    return nil, errors.New(jsonResp)
+    return nil, newError(E_ARGS, "Incorrect Role has been passed, should be: Shipper, Provider, Insurer or Consignee")
   }
 
 // validate status
-    if args[2] == "Label_Generated"{
-    fmt.Println("Label_Generated has been passed as status")
-    } else if args[2] == "In_Transit" {
-    fmt.Println("In_Transit has been passed as status")
-    } else if args[2] == "Pkg_Damaged" {
-    fmt.Println("Pkg_Damaged has been passed as status")
-    } else if args[2] == "Pkg_Delivered" {
-    fmt.Println("Pkg_Delivered has been passed as status")
+    if isValidStatus(args[2]) {
+    fmt.Println(args[2] + " has been passed as status")
     } else {
-      jsonResp = "Error: Incorrect Status has been passed, should be: Label_Generated, In_Transit, Pkg_Damaged or Pkg_Delivered"
-      return nil, errors.New(jsonResp)
-    }
-
-  valAsbytes, err := stub.GetState("PkgIdsKey")
-  if err != nil {
-      jsonResp = "Error:Failed to get state for PkgIdsKey "
-      return nil, errors.New(jsonResp)
-      }
-
-  var package_holder PKG_Holder
-  err = json.Unmarshal(valAsbytes, &package_holder)
-  if err != nil {
-            fmt.Println("Could not marshal personal info object", err)
-            jsonResp = "Error:Could not marshal personal info object"
-            return nil, errors.New(jsonResp)
-      }
-
-  var pkginfo PackageInfo
-
-  result := "["
-
-  var temp []byte
-
-  for _, PkgId := range package_holder.PkgIds  {
-
-    pkginfoasbytes, err := stub.GetState(PkgId)
-    if err != nil {
-      jsonResp = "Error: Failed to get state for " + PkgId
-      return nil, errors.New(jsonResp)
-    }
-
-    err = json.Unmarshal(pkginfoasbytes, &pkginfo);
-    if err != nil {
-              fmt.Println("Could not marshal personal info object", err)
-              jsonResp = "Error:Could not marshal personal info object"
-              return nil, errors.New(jsonResp)
-    }
-
-    // check for inout role & Status - this is crude way to do this - need to find another way
-    if args[0] == "Provider"{
-      if pkginfo.Provider == args[1] {
-        if pkginfo.PkgStatus  == args[2] {
-        temp = pkginfoasbytes
-        result += string(temp) + ","
-        }
-      }
-    } else if args[0] == "Shipper" {
-      if pkginfo.Shipper == args[1] {
-        if pkginfo.PkgStatus == args[2] {
-        temp = pkginfoasbytes
-        result += string(temp) + ","
-        }
-      }
-    } else if args[0] == "Insurer" {
-      if pkginfo.Insurer == args[1] {
-        if pkginfo.PkgStatus == args[2] {
-        temp = pkginfoasbytes
-        result += string(temp) + ","
-        }
-      }
-    } else if args[0] == "Consignee" {
-      if pkginfo.Consignee == args[1] {
-        if pkginfo.PkgStatus == args[2] {
-        temp = pkginfoasbytes
-        result += string(temp) + ","
-        }
-      }
+      return nil, newError(E_ARGS, "Incorrect Status has been passed, should be: Label_Generated, In_Transit, Delivery_Pending, Delivery_Rejected, Pkg_Damaged, Pkg_Delivered, Temp_Warning or Pkg_Cancelled")
     }
 
+  // read the packages of the party in the status from the role & status index
+  return scanPackageIndex(stub, pkgRoleStatusIndex, []string{args[0], args[1], args[2]}, opts)
 
-  } // end of for loop
-
-  if len(result) == 1 {
-    result = "[]"
-  } else {
-    result = result[:len(result)-1] + "]"
-  }
-
-  return []byte(result), nil
+}
 
+//=================================================================================================================================
+//	pkgPartyForRole - returns the party holding role on the package, or an empty string for an unknown role. The
+//				Provider role is held by the carrier currently holding the package.
+//=================================================================================================================================
+func pkgPartyForRole(pkginfo PackageInfo, role string) string {
+	if role == ROLE_PROVIDER {
+		return currentCustodian(&pkginfo)
+	} else if role == ROLE_SHIPPER {
+		return pkginfo.Shipper
+	} else if role == ROLE_INSURER {
+		return pkginfo.Insurer
+	} else if role == ROLE_CONSIGNEE {
+		return pkginfo.Consignee
+	}
+	return ""
 }
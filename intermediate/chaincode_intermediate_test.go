@@ -0,0 +1,447 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+// The tests deploy the chaincode in insecure mode, where parties are passed as arguments, unless they test
+// authorization. createP1 creates package P1 between the parties below with a range of 2 to 8 degrees.
+var createP1 = []string{"create", "P1", "SHP", "INS", "CON", "2", "8", "vaccine", "PRV"}
+
+// newTestStub deploys the chaincode in insecure mode and runs the setup invokes, each of which must succeed.
+func newTestStub(t *testing.T, setup ...[]string) *mockstub.MockStub {
+	t.Helper()
+	stub := mockstub.NewMockStub("intermediate", new(SimpleChaincode))
+	mustInit(t, stub, "init", "SHIPPER", "INSURER", "CONSIGNEE", "PROVIDER", "-20", "-10", "sample", AUTH_MODE_INSECURE)
+	for _, invoke := range setup {
+		mustInvoke(t, stub, invoke[0], invoke[1:]...)
+	}
+	return stub
+}
+
+func mustInit(t *testing.T, stub *mockstub.MockStub, function string, args ...string) []byte {
+	t.Helper()
+	result, err := stub.MockInit(function, args...)
+	if err != nil {
+		t.Fatalf("init %v: %v", args, err)
+	}
+	return result
+}
+
+func mustInvoke(t *testing.T, stub *mockstub.MockStub, function string, args ...string) []byte {
+	t.Helper()
+	result, err := stub.MockInvoke(function, args...)
+	if err != nil {
+		t.Fatalf("%s %v: %v", function, args, err)
+	}
+	return result
+}
+
+func mustQuery(t *testing.T, stub *mockstub.MockStub, function string, args ...string) []byte {
+	t.Helper()
+	result, err := stub.MockQuery(function, args...)
+	if err != nil {
+		t.Fatalf("%s %v: %v", function, args, err)
+	}
+	return result
+}
+
+// checkCode fails unless err is the JSON error with code, or nil if code is empty.
+func checkCode(t *testing.T, err error, code string) {
+	t.Helper()
+	if code == "" {
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Fatalf("expected %s, got no error", code)
+	}
+	var chaincodeErr ChaincodeError
+	if json.Unmarshal([]byte(err.Error()), &chaincodeErr) != nil || chaincodeErr.Message == "" {
+		t.Fatalf("error is not a JSON error: %v", err)
+	}
+	if chaincodeErr.Code != code {
+		t.Fatalf("expected %s, got %v", code, err)
+	}
+}
+
+// getPkg reads a package through querypkgbyid
+func getPkg(t *testing.T, stub *mockstub.MockStub, pkgId string) PackageInfo {
+	t.Helper()
+	var packageinfo PackageInfo
+	if err := json.Unmarshal(mustQuery(t, stub, "querypkgbyid", pkgId), &packageinfo); err != nil {
+		t.Fatal(err)
+	}
+	return packageinfo
+}
+
+// pkgIds returns the sorted ids of a JSON array of packages
+func pkgIds(t *testing.T, result []byte) []string {
+	t.Helper()
+	var packages []PackageInfo
+	if err := json.Unmarshal(result, &packages); err != nil {
+		t.Fatalf("%s: %v", result, err)
+	}
+	ids := []string{}
+	for _, packageinfo := range packages {
+		ids = append(ids, packageinfo.PkgId)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// lastEvent decodes the event of the most recent transaction that set one
+func lastEvent(t *testing.T, stub *mockstub.MockStub) (string, PkgEvent) {
+	t.Helper()
+	event, ok := stub.LastEvent()
+	if !ok {
+		t.Fatal("no event was set")
+	}
+	var pkgEvent PkgEvent
+	if event.Name != EVENT_PKG_BATCH {
+		if err := json.Unmarshal(event.Payload, &pkgEvent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return event.Name, pkgEvent
+}
+
+// eventPkgIds returns the ids of the packages named by the last event, single or batched, checking that each is a
+// name event carrying the package snapshot
+func eventPkgIds(t *testing.T, stub *mockstub.MockStub, name string) []string {
+	t.Helper()
+	event, ok := stub.LastEvent()
+	if !ok {
+		t.Fatal("no event was set")
+	}
+	var events []PkgEvent
+	if event.Name == EVENT_PKG_BATCH {
+		if err := json.Unmarshal(event.Payload, &events); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		events = make([]PkgEvent, 1)
+		if err := json.Unmarshal(event.Payload, &events[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pkgIds := make([]string, 0, len(events))
+	for _, pkgEvent := range events {
+		if pkgEvent.Event != name || pkgEvent.Package == nil || pkgEvent.NewStatus != pkgEvent.Package.PkgStatus {
+			t.Fatalf("unexpected event %+v", pkgEvent)
+		}
+		pkgIds = append(pkgIds, pkgEvent.PkgId)
+	}
+	return pkgIds
+}
+
+// then returns the invokes of setup followed by more, without changing setup
+func then(setup [][]string, more ...[]string) [][]string {
+	return append(append([][]string{}, setup...), more...)
+}
+
+// invokeTest runs function on a fresh stub prepared by setup, expecting the error code (none if empty) and, if
+// status is set, P1 to end up in that status.
+type invokeTest struct {
+	name     string
+	setup    [][]string
+	function string
+	args     []string
+	code     string
+	status   string
+	check    func(t *testing.T, stub *mockstub.MockStub)
+}
+
+func runInvokeTests(t *testing.T, tests []invokeTest) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newTestStub(t, test.setup...)
+			_, err := stub.MockInvoke(test.function, test.args...)
+			checkCode(t, err, test.code)
+			if test.status != "" {
+				if got := getPkg(t, stub, "P1").PkgStatus; got != test.status {
+					t.Fatalf("P1 is %s, want %s", got, test.status)
+				}
+			}
+			if test.check != nil {
+				test.check(t, stub)
+			}
+		})
+	}
+}
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code string
+	}{
+		{"insecure", []string{"SHP", "INS", "CON", "PRV", "2", "8", "vaccine", AUTH_MODE_INSECURE}, ""},
+		{"too few arguments", []string{"SHP", "INS", "CON", "PRV", "2", "8"}, E_ARGS},
+		{"unknown auth mode", []string{"SHP", "INS", "CON", "PRV", "2", "8", "vaccine", "open"}, E_ARGS},
+		{"bad temperature", []string{"SHP", "INS", "CON", "PRV", "cold", "8", "vaccine", AUTH_MODE_INSECURE}, E_ARGS},
+		{"secure without caller", []string{"SHP", "INS", "CON", "PRV", "2", "8", "vaccine"}, E_INTERNAL},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := mockstub.NewMockStub("intermediate", new(SimpleChaincode))
+			_, err := stub.MockInit("init", test.args...)
+			checkCode(t, err, test.code)
+			if test.code != "" {
+				if len(stub.State) != 0 {
+					t.Fatal("a failed Init wrote to the ledger")
+				}
+				return
+			}
+
+			packageinfo := getPkg(t, stub, "1Z20170426")
+			if packageinfo.Shipper != "SHP" || packageinfo.PkgStatus != STATUS_LABEL_GENERATED || packageinfo.TempUnit != TEMP_UNIT_DECI_CELSIUS {
+				t.Fatalf("unexpected sample package %+v", packageinfo)
+			}
+			if name, event := lastEvent(t, stub); name != EVENT_PKG_CREATED || event.PkgId != "1Z20170426" {
+				t.Fatalf("unexpected event %s %+v", name, event)
+			}
+		})
+	}
+
+	stub := mockstub.NewMockStub("intermediate", new(SimpleChaincode))
+	mustInit(t, stub, "init", "SHP", "INS", "CON", "PRV", "2.5C", "46.4F", "vaccine", AUTH_MODE_INSECURE)
+	if packageinfo := getPkg(t, stub, "1Z20170426"); packageinfo.TempratureMin != 25 || packageinfo.TempratureMax != 80 {
+		t.Fatalf("temperatures stored as %d and %d tenths", packageinfo.TempratureMin, packageinfo.TempratureMax)
+	}
+}
+
+func TestUnknownFunctions(t *testing.T) {
+	stub := newTestStub(t)
+	_, err := stub.MockInvoke("nosuchinvoke")
+	checkCode(t, err, E_FORBIDDEN)
+	_, err = stub.MockQuery("nosuchquery")
+	checkCode(t, err, E_FORBIDDEN)
+	_, err = stub.MockQuery("create", createP1[1:]...)
+	checkCode(t, err, E_ARGS)
+}
+
+func TestCreate(t *testing.T) {
+	runInvokeTests(t, []invokeTest{
+		{name: "new package", function: "create", args: createP1[1:], status: STATUS_LABEL_GENERATED,
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				packageinfo := getPkg(t, stub, "P1")
+				if packageinfo.Provider != "PRV" || packageinfo.TempratureMin != 20 || packageinfo.TempratureMax != 80 {
+					t.Fatalf("unexpected package %+v", packageinfo)
+				}
+				if name, event := lastEvent(t, stub); name != EVENT_PKG_CREATED || event.Package == nil || event.Actor != "SHP" {
+					t.Fatalf("unexpected event %s %+v", name, event)
+				}
+			}},
+		{name: "duplicate", setup: [][]string{createP1}, function: "create", args: createP1[1:], code: E_DUPLICATE},
+		{name: "missing argument", function: "create", args: createP1[1:8], code: E_ARGS},
+		{name: "bad min", function: "create", args: []string{"P1", "SHP", "INS", "CON", "two", "8", "vaccine", "PRV"}, code: E_ARGS},
+		{name: "bad max", function: "create", args: []string{"P1", "SHP", "INS", "CON", "2", "8.25", "vaccine", "PRV"}, code: E_ARGS},
+	})
+}
+
+func TestAcceptpkg(t *testing.T) {
+	runInvokeTests(t, []invokeTest{
+		{name: "provider accepts", setup: [][]string{createP1}, function: "acceptpkg", args: []string{"P1", "PRV"}, status: STATUS_IN_TRANSIT,
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if name, event := lastEvent(t, stub); name != EVENT_PKG_ACCEPTED || event.OldStatus != STATUS_LABEL_GENERATED || event.NewStatus != STATUS_IN_TRANSIT {
+					t.Fatalf("unexpected event %s %+v", name, event)
+				}
+			}},
+		{name: "wrong provider", setup: [][]string{createP1}, function: "acceptpkg", args: []string{"P1", "OTHER"}, code: E_FORBIDDEN, status: STATUS_LABEL_GENERATED},
+		{name: "missing provider", setup: [][]string{createP1}, function: "acceptpkg", args: []string{"P1"}, code: E_ARGS},
+		{name: "unknown package", function: "acceptpkg", args: []string{"P9", "PRV"}, code: E_NOT_FOUND},
+		{name: "already in transit", setup: [][]string{createP1, {"acceptpkg", "P1", "PRV"}}, function: "acceptpkg", args: []string{"P1", "PRV"}, code: E_ILLEGAL_STATE},
+		{name: "too many arguments", setup: [][]string{createP1}, function: "acceptpkg", args: []string{"P1", "PRV", "x"}, code: E_ARGS},
+	})
+}
+
+func TestDeliverpkg(t *testing.T) {
+	inTransit := [][]string{createP1, {"acceptpkg", "P1", "PRV"}}
+	runInvokeTests(t, []invokeTest{
+		{name: "provider delivers", setup: inTransit, function: "deliverpkg", args: []string{"P1", "PRV"}, status: STATUS_DELIVERY_PENDING,
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				deliveries := getPkg(t, stub, "P1").Deliveries
+				if len(deliveries) != 1 || deliveries[0].DeliveredBy != "PRV" || deliveries[0].SubmittedAt == 0 {
+					t.Fatalf("unexpected deliveries %+v", deliveries)
+				}
+			}},
+		{name: "not accepted yet", setup: [][]string{createP1}, function: "deliverpkg", args: []string{"P1", "PRV"}, code: E_ILLEGAL_STATE},
+		{name: "wrong provider", setup: inTransit, function: "deliverpkg", args: []string{"P1", "OTHER"}, code: E_FORBIDDEN, status: STATUS_IN_TRANSIT},
+		{name: "unknown package", function: "deliverpkg", args: []string{"P9", "PRV"}, code: E_NOT_FOUND},
+		{name: "no arguments", function: "deliverpkg", code: E_ARGS},
+	})
+}
+
+func TestUpdatetemp(t *testing.T) {
+	runInvokeTests(t, []invokeTest{
+		{name: "in range", setup: [][]string{createP1}, function: "updatetemp", args: []string{"P1", "5", "PRV", "S1"}, status: STATUS_LABEL_GENERATED,
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if name, event := lastEvent(t, stub); name != EVENT_PKG_TEMP_READING || event.Reading == nil || *event.Reading != 50 || event.Actor != "PRV" {
+					t.Fatalf("unexpected event %s %+v", name, event)
+				}
+			}},
+		{name: "fahrenheit in range", setup: [][]string{createP1}, function: "updatetemp", args: []string{"P1", "41F", "PRV"}, status: STATUS_LABEL_GENERATED},
+		{name: "too warm", setup: [][]string{createP1}, function: "updatetemp", args: []string{"P1", "8.1", "PRV"}, status: STATUS_PKG_DAMAGED,
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if name, _ := lastEvent(t, stub); name != EVENT_PKG_DAMAGED {
+					t.Fatalf("unexpected event %s", name)
+				}
+				packageinfo := getPkg(t, stub, "P1")
+				if packageinfo.DamageChannel != CHANNEL_TEMPERATURE {
+					t.Fatalf("damage channel %q", packageinfo.DamageChannel)
+				}
+			}},
+		{name: "too cold", setup: [][]string{createP1}, function: "updatetemp", args: []string{"P1", "1.9", "PRV"}, status: STATUS_PKG_DAMAGED},
+		{name: "bad reading", setup: [][]string{createP1}, function: "updatetemp", args: []string{"P1", "warm", "PRV"}, code: E_ARGS},
+		{name: "unknown package", function: "updatetemp", args: []string{"P9", "5", "PRV"}, code: E_NOT_FOUND},
+		{name: "missing reading", setup: [][]string{createP1}, function: "updatetemp", args: []string{"P1"}, code: E_ARGS},
+		{name: "not the custodian", setup: [][]string{createP1, {"acceptpkg", "P1", "PRV"}}, function: "updatetemp", args: []string{"P1", "20", "PRV2"}, code: E_FORBIDDEN, status: STATUS_IN_TRANSIT},
+		{name: "previous custodian", setup: [][]string{createP1, {"acceptpkg", "P1", "PRV"}, {"handoverpkg", "P1", "PRV2", "PRV"}}, function: "updatetemp", args: []string{"P1", "20", "PRV"}, code: E_FORBIDDEN, status: STATUS_IN_TRANSIT},
+		{name: "new custodian", setup: [][]string{createP1, {"acceptpkg", "P1", "PRV"}, {"handoverpkg", "P1", "PRV2", "PRV"}}, function: "updatetemp", args: []string{"P1", "5", "PRV2"}, status: STATUS_IN_TRANSIT},
+		{name: "missing provider", setup: [][]string{createP1}, function: "updatetemp", args: []string{"P1", "5"}, code: E_ARGS, status: STATUS_LABEL_GENERATED},
+	})
+}
+
+func TestQuerypkgbyid(t *testing.T) {
+	stub := newTestStub(t, createP1)
+	if packageinfo := getPkg(t, stub, "P1"); packageinfo.PkgId != "P1" || packageinfo.Consignee != "CON" {
+		t.Fatalf("unexpected package %+v", packageinfo)
+	}
+
+	_, err := stub.MockQuery("querypkgbyid", "P9")
+	checkCode(t, err, E_NOT_FOUND)
+	_, err = stub.MockQuery("querypkgbyid")
+	checkCode(t, err, E_ARGS)
+}
+
+func TestListQueries(t *testing.T) {
+	stub := newTestStub(t,
+		createP1,
+		[]string{"create", "P2", "SHP", "INS", "CON", "2", "8", "vaccine", "PRV2"},
+		[]string{"create", "P3", "SHP2", "INS", "CON2", "2", "8", "vaccine", "PRV"},
+		[]string{"acceptpkg", "P1", "PRV"},
+		[]string{"acceptpkg", "P3", "PRV"},
+		[]string{"updatetemp", "P3", "20", "PRV"},
+	)
+
+	tests := []struct {
+		function string
+		args     []string
+		want     []string
+		code     string
+	}{
+		{"queryallpkg", nil, []string{"1Z20170426", "P1", "P2", "P3"}, ""},
+		{"querypkgbyprovider", []string{"PRV"}, []string{"P1", "P3"}, ""},
+		{"querypkgbyprovider", []string{"NOBODY"}, []string{}, ""},
+		{"querypkgbyshipper", []string{"SHP"}, []string{"P1", "P2"}, ""},
+		{"querybypkgstatus", []string{STATUS_IN_TRANSIT}, []string{"P1"}, ""},
+		{"querybypkgstatus", []string{STATUS_PKG_DAMAGED}, []string{"P3"}, ""},
+		{"querybyrole", []string{ROLE_CONSIGNEE, "CON"}, []string{"P1", "P2"}, ""},
+		{"querybyrole", []string{ROLE_INSURER, "INS"}, []string{"P1", "P2", "P3"}, ""},
+		{"querybyrole_status", []string{ROLE_PROVIDER, "PRV", STATUS_IN_TRANSIT}, []string{"P1"}, ""},
+		{"querybyrole_status", []string{ROLE_SHIPPER, "SHP", STATUS_LABEL_GENERATED}, []string{"P2"}, ""},
+		{"queryallpkg", []string{"1", "2", "3"}, nil, E_ARGS},
+		{"querypkgbyprovider", nil, nil, E_ARGS},
+		{"querypkgbyshipper", []string{"SHP", "0"}, nil, E_ARGS},
+		{"querybypkgstatus", nil, nil, E_ARGS},
+		{"querybyrole", []string{"Owner", "SHP"}, nil, E_ARGS},
+		{"querybyrole_status", []string{ROLE_SHIPPER, "SHP", "Lost"}, nil, E_ARGS},
+		{"querybyrole_status", []string{"Owner", "SHP", STATUS_IN_TRANSIT}, nil, E_ARGS},
+	}
+	for _, test := range tests {
+		result, err := stub.MockQuery(test.function, test.args...)
+		checkCode(t, err, test.code)
+		if test.code != "" {
+			continue
+		}
+		if got := pkgIds(t, result); !equalStrings(got, test.want) {
+			t.Errorf("%s %v = %v, want %v", test.function, test.args, got, test.want)
+		}
+	}
+}
+
+func TestQueryallpkgids(t *testing.T) {
+	stub := newTestStub(t, createP1)
+
+	var holder PKG_Holder
+	if err := json.Unmarshal(mustQuery(t, stub, "queryallpkgids"), &holder); err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(holder.PkgIds, []string{"1Z20170426", "P1"}) {
+		t.Fatalf("queryallpkgids = %v", holder.PkgIds)
+	}
+}
+
+func TestPaging(t *testing.T) {
+	stub := newTestStub(t, createP1,
+		[]string{"create", "P2", "SHP", "INS", "CON", "2", "8", "vaccine", "PRV"},
+		[]string{"create", "P3", "SHP", "INS", "CON", "2", "8", "vaccine", "PRV"},
+	)
+
+	var seen []string
+	bookmark := ""
+	for pages := 0; ; pages++ {
+		args := []string{"PRV", "2"}
+		if bookmark != "" {
+			args = append(args, bookmark)
+		}
+		var page ListPage
+		if err := json.Unmarshal(mustQuery(t, stub, "querypkgbyprovider", args...), &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range page.Items {
+			var packageinfo PackageInfo
+			json.Unmarshal(item, &packageinfo)
+			seen = append(seen, packageinfo.PkgId)
+		}
+		if !page.HasMore {
+			break
+		}
+		if pages > 2 {
+			t.Fatal("paging does not terminate")
+		}
+		bookmark = page.Bookmark
+	}
+	if !equalStrings(seen, []string{"P1", "P2", "P3"}) {
+		t.Fatalf("pages returned %v", seen)
+	}
+
+	_, err := stub.MockQuery("querypkgbyprovider", "PRV", "2", "not a bookmark")
+	checkCode(t, err, E_ARGS)
+	_, err = stub.MockQuery("querypkgbyprovider", "PRV", "-1")
+	checkCode(t, err, E_ARGS)
+}
+
+func TestTransitions(t *testing.T) {
+	for from, targets := range pkgTransitions {
+		for _, to := range []string{STATUS_LABEL_GENERATED, STATUS_IN_TRANSIT, STATUS_DELIVERY_PENDING, STATUS_DELIVERY_REJECTED, STATUS_TEMP_WARNING, STATUS_PKG_DAMAGED, STATUS_PKG_DELIVERED} {
+			err := checkTransition(from, to)
+			if containsString(targets, to) != (err == nil) {
+				t.Errorf("checkTransition(%s, %s) = %v", from, to, err)
+			}
+			if err != nil && errorCode(err) != E_ILLEGAL_STATE {
+				t.Errorf("checkTransition(%s, %s) has code %s", from, to, errorCode(err))
+			}
+		}
+	}
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	Claim - An insurance claim against a damaged package. Claims are opened automatically when a reading damages the
+//				package (ReadingSeq then points at the offending reading in the temprature history, or in the
+//				condition history when the package DamageChannel is not temperature) or filed by the Shipper or
+//				Consignee, and are settled by the package Insurer only.
+//				Claims are stored under Claim~PkgId~ClaimId.
+//==============================================================================================================================
+type Claim struct {
+	ClaimId     string `json:"claimid"`
+	PkgId       string `json:"packageid"`
+	Insurer     string `json:"insurer"`
+	FiledBy     string `json:"filedby"`
+	ClaimStatus string `json:"claimstatus"`
+	Reason      string `json:"reason"`
+	ReadingSeq  int    `json:"readingseq,omitempty"`
+	OpenedAt    int64  `json:"openedat"`
+	Amount      string `json:"amount,omitempty"`
+	SettledAt   int64  `json:"settledat,omitempty"`
+	Notes       string `json:"notes,omitempty"`
+	PaymentRef  string `json:"paymentref,omitempty"`
+}
+
+const claimObjectType = "Claim"
+
+//==============================================================================================================================
+//	 Claim statuses - Claim_Open -> Claim_Approved -> Claim_Paid, or Claim_Open -> Claim_Rejected
+//==============================================================================================================================
+const (
+	CLAIM_OPEN     = "Claim_Open"
+	CLAIM_APPROVED = "Claim_Approved"
+	CLAIM_REJECTED = "Claim_Rejected"
+	CLAIM_PAID     = "Claim_Paid"
+)
+
+var claimTransitions = map[string][]string{
+	CLAIM_OPEN:     {CLAIM_APPROVED, CLAIM_REJECTED},
+	CLAIM_APPROVED: {CLAIM_PAID},
+	CLAIM_REJECTED: {},
+	CLAIM_PAID:     {},
+}
+
+//==============================================================================================================================
+//	claimKey - ledger key of a claim
+//==============================================================================================================================
+func claimKey(pkgId string, claimId string) (string, error) {
+	return createCompositeKey(claimObjectType, []string{pkgId, claimId})
+}
+
+//==============================================================================================================================
+//	getClaims - reads every claim of a package in the order they were opened
+//==============================================================================================================================
+func getClaims(stub shim.ChaincodeStubInterface, pkgId string) ([]Claim, error) {
+	startKey, endKey, err := compositeKeyRange(claimObjectType, []string{pkgId})
+	if err != nil {
+		return nil, err
+	}
+
+	// claim ids are numbered in the order claims are opened, see openClaim
+	entries, err := getStateRange(stub, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := []Claim{}
+	for _, entry := range entries {
+		var claim Claim
+		err = json.Unmarshal(entry.value, &claim)
+		if err != nil {
+			fmt.Println("Could not unmarshal claim object", err)
+			return nil, newError(E_DECODE, "Could not unmarshal claim object")
+		}
+		claims = append(claims, claim)
+	}
+	return claims, nil
+}
+
+//==============================================================================================================================
+//	getClaim - reads one claim of a package
+//==============================================================================================================================
+func getClaim(stub shim.ChaincodeStubInterface, pkgId string, claimId string) (Claim, error) {
+	var claim Claim
+
+	key, err := claimKey(pkgId, claimId)
+	if err != nil {
+		return claim, err
+	}
+
+	valAsbytes, err := stub.GetState(key)
+	if err != nil {
+		return claim, newError(E_INTERNAL, "Failed to get state for claim "+claimId)
+	}
+	if valAsbytes == nil {
+		return claim, newError(E_NOT_FOUND, "Invalid ClaimId Passed "+claimId)
+	}
+
+	err = json.Unmarshal(valAsbytes, &claim)
+	if err != nil {
+		fmt.Println("Could not unmarshal claim object", err)
+		return claim, newError(E_DECODE, "Could not unmarshal claim object "+claimId)
+	}
+	return claim, nil
+}
+
+//==============================================================================================================================
+//	putClaim - writes a claim to the ledger
+//==============================================================================================================================
+func putClaim(stub shim.ChaincodeStubInterface, claim *Claim) error {
+	key, err := claimKey(claim.PkgId, claim.ClaimId)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(claim)
+	if err != nil {
+		fmt.Println("Could not marshal claim object", err)
+		return newError(E_INTERNAL, "Could not marshal claim object "+claim.ClaimId)
+	}
+
+	err = stub.PutState(key, bytes)
+	if err != nil {
+		return newError(E_INTERNAL, "Failed writing to blockchain for claim "+claim.ClaimId)
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	openClaim - opens a new claim on a damaged package. Only one claim per package may be in progress (open or
+//				approved) at a time. Claim ids are numbered per package so that they sort in the order opened.
+//==============================================================================================================================
+func openClaim(stub shim.ChaincodeStubInterface, packageinfo *PackageInfo, filedBy string, reason string, readingSeq int) (Claim, error) {
+	claims, err := getClaims(stub, packageinfo.PkgId)
+	if err != nil {
+		return Claim{}, err
+	}
+
+	for _, existing := range claims {
+		if existing.ClaimStatus == CLAIM_OPEN || existing.ClaimStatus == CLAIM_APPROVED {
+			return Claim{}, newError(E_ILLEGAL_STATE, "Claim "+existing.ClaimId+" is already in progress for "+packageinfo.PkgId)
+		}
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return Claim{}, err
+	}
+
+	claim := Claim{
+		ClaimId:     fmt.Sprintf("CLM%06d", len(claims)+1),
+		PkgId:       packageinfo.PkgId,
+		Insurer:     packageinfo.Insurer,
+		FiledBy:     filedBy,
+		ClaimStatus: CLAIM_OPEN,
+		Reason:      reason,
+		ReadingSeq:  readingSeq,
+		OpenedAt:    timestamp,
+	}
+
+	return claim, putClaim(stub, &claim)
+}
+
+//==============================================================================================================================
+//	settleClaim - moves a claim to status on behalf of the package Insurer. Expects PkgId, ClaimId, Insurer (only
+//				checked in insecure mode, and optional in secure mode unless more arguments follow) followed by the
+//				arguments handed to update.
+//==============================================================================================================================
+func settleClaim(stub shim.ChaincodeStubInterface, args []string, maxArgs int, status string, update func(claim *Claim, extra []string)) ([]byte, error) {
+
+	if len(args) < 2 || len(args) > maxArgs {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId, ClaimId, Insurer (in insecure mode) and up to "+fmt.Sprint(maxArgs-3)+" optional arguments")
+	}
+
+	packageinfo, err := getPackageInfo(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	claim, err := getClaim(stub, args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// only the Insurer of the package may settle its claims
+	err = authorizeParty(stub, args, 2, packageinfo.Insurer)
+	if err != nil {
+		return nil, wrapError(E_FORBIDDEN, "Wrong Insurer - Not authorized to settle this claim", err)
+	}
+
+	if !containsString(claimTransitions[claim.ClaimStatus], status) {
+		return nil, &IllegalTransitionError{From: claim.ClaimStatus, To: status}
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	var extra []string
+	if len(args) > 3 {
+		extra = args[3:]
+	}
+
+	claim.ClaimStatus = status
+	claim.SettledAt = timestamp
+	update(&claim, extra)
+
+	err = putClaim(stub, &claim)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&claim)
+}
+
+//=================================================================================================================================
+//	fileclaim - the Shipper or Consignee of a damaged package opens a claim. Expects PkgId, Claimant (only checked
+//				in insecure mode, and optional in secure mode unless a Reason follows) and optional Reason.
+//=================================================================================================================================
+func (t *SimpleChaincode) fileclaim(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running fileclaim()")
+
+	if len(args) < 1 || len(args) > 3 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId, Claimant (in insecure mode) and optional Reason")
+	}
+
+	packageinfo, err := getPackageInfo(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	claimant, err := getCallerFromArgs(stub, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if claimant != packageinfo.Shipper && claimant != packageinfo.Consignee {
+		return nil, newError(E_FORBIDDEN, "Caller "+claimant+" is not the Shipper or Consignee of "+packageinfo.PkgId)
+	}
+
+	if packageinfo.PkgStatus != STATUS_PKG_DAMAGED {
+		return nil, newError(E_ILLEGAL_STATE, "Claims can only be filed for packages in status "+STATUS_PKG_DAMAGED)
+	}
+
+	var reason string
+	if len(args) == 3 {
+		reason = args[2]
+	}
+
+	claim, err := openClaim(stub, &packageinfo, claimant, reason, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&claim)
+}
+
+//=================================================================================================================================
+//	approveclaim - the Insurer approves an open claim. Expects PkgId, ClaimId, Insurer and optional Amount and Notes.
+//=================================================================================================================================
+func (t *SimpleChaincode) approveclaim(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running approveclaim()")
+	return settleClaim(stub, args, 5, CLAIM_APPROVED, func(claim *Claim, extra []string) {
+		if len(extra) > 0 {
+			claim.Amount = extra[0]
+		}
+		if len(extra) > 1 {
+			claim.Notes = extra[1]
+		}
+	})
+}
+
+//=================================================================================================================================
+//	rejectclaim - the Insurer rejects an open claim. Expects PkgId, ClaimId, Insurer and optional Notes.
+//=================================================================================================================================
+func (t *SimpleChaincode) rejectclaim(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running rejectclaim()")
+	return settleClaim(stub, args, 4, CLAIM_REJECTED, func(claim *Claim, extra []string) {
+		if len(extra) > 0 {
+			claim.Notes = extra[0]
+		}
+	})
+}
+
+//=================================================================================================================================
+//	payclaim - the Insurer records payment of an approved claim. Expects PkgId, ClaimId, Insurer and optional
+//				PaymentRef.
+//=================================================================================================================================
+func (t *SimpleChaincode) payclaim(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running payclaim()")
+	return settleClaim(stub, args, 4, CLAIM_PAID, func(claim *Claim, extra []string) {
+		if len(extra) > 0 {
+			claim.PaymentRef = extra[0]
+		}
+	})
+}
+
+//=================================================================================================================================
+//	queryclaims - query function to read the claims of a package in the order they were opened
+//=================================================================================================================================
+func (t *SimpleChaincode) queryclaims(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	if len(args) < 1 || len(args) > 3 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId and optional PageSize and Bookmark")
+	}
+
+	opts, err := parseListOptions(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	startKey, endKey, err := compositeKeyRange(claimObjectType, []string{args[0]})
+	if err != nil {
+		return nil, err
+	}
+
+	items, bookmark, hasMore, err := scanRange(stub, startKey, endKey, opts, func(key string, valAsbytes []byte) (json.RawMessage, error) {
+		return json.RawMessage(valAsbytes), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return listResult(opts, items, bookmark, hasMore)
+}
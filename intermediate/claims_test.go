@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+// damagedP1 damages P1 with an out of range reading, which opens claim CLM000001
+var damagedP1 = [][]string{createP1, {"acceptpkg", "P1", "PRV"}, {"updatetemp", "P1", "12", "PRV"}}
+
+// getClaimList reads the claims of a package through queryclaims
+func getClaimList(t *testing.T, stub *mockstub.MockStub, pkgId string) []Claim {
+	t.Helper()
+	var claims []Claim
+	if err := json.Unmarshal(mustQuery(t, stub, "queryclaims", pkgId), &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestDamageOpensClaim(t *testing.T) {
+	stub := newTestStub(t, damagedP1...)
+	claims := getClaimList(t, stub, "P1")
+	if len(claims) != 1 || claims[0].ClaimId != "CLM000001" || claims[0].ClaimStatus != CLAIM_OPEN || claims[0].Insurer != "INS" || claims[0].ReadingSeq != 1 {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+func TestClaims(t *testing.T) {
+	rejected := then(damagedP1, []string{"rejectclaim", "P1", "CLM000001", "INS", "sensor fault"})
+	approved := then(damagedP1, []string{"approveclaim", "P1", "CLM000001", "INS", "100.00", "ok"})
+	claimStatus := func(claimId string, status string) func(t *testing.T, stub *mockstub.MockStub) {
+		return func(t *testing.T, stub *mockstub.MockStub) {
+			for _, claim := range getClaimList(t, stub, "P1") {
+				if claim.ClaimId == claimId {
+					if claim.ClaimStatus != status {
+						t.Fatalf("claim %s is %s, want %s", claimId, claim.ClaimStatus, status)
+					}
+					return
+				}
+			}
+			t.Fatalf("claim %s not found", claimId)
+		}
+	}
+
+	runInvokeTests(t, []invokeTest{
+		{name: "approve", setup: damagedP1, function: "approveclaim", args: []string{"P1", "CLM000001", "INS", "100.00", "ok"}, check: claimStatus("CLM000001", CLAIM_APPROVED)},
+		{name: "reject", setup: damagedP1, function: "rejectclaim", args: []string{"P1", "CLM000001", "INS"}, check: claimStatus("CLM000001", CLAIM_REJECTED)},
+		{name: "pay approved", setup: approved, function: "payclaim", args: []string{"P1", "CLM000001", "INS", "REF-1"}, check: claimStatus("CLM000001", CLAIM_PAID)},
+		{name: "pay open", setup: damagedP1, function: "payclaim", args: []string{"P1", "CLM000001", "INS"}, code: E_ILLEGAL_STATE},
+		{name: "wrong insurer", setup: damagedP1, function: "approveclaim", args: []string{"P1", "CLM000001", "SHP"}, code: E_FORBIDDEN},
+		{name: "unknown claim", setup: damagedP1, function: "approveclaim", args: []string{"P1", "CLM000009", "INS"}, code: E_NOT_FOUND},
+		{name: "too many arguments", setup: damagedP1, function: "rejectclaim", args: []string{"P1", "CLM000001", "INS", "a", "b"}, code: E_ARGS},
+		{name: "file while open", setup: damagedP1, function: "fileclaim", args: []string{"P1", "CON", "broken"}, code: E_ILLEGAL_STATE},
+		{name: "file after rejection", setup: rejected, function: "fileclaim", args: []string{"P1", "CON", "broken"}, check: claimStatus("CLM000002", CLAIM_OPEN)},
+		{name: "file by other party", setup: rejected, function: "fileclaim", args: []string{"P1", "PRV", "broken"}, code: E_FORBIDDEN},
+		{name: "file on intact package", setup: [][]string{createP1}, function: "fileclaim", args: []string{"P1", "SHP", "broken"}, code: E_ILLEGAL_STATE},
+		{name: "file without reason", setup: rejected, function: "fileclaim", args: []string{"P1", "SHP"}, check: claimStatus("CLM000002", CLAIM_OPEN)},
+		{name: "file without claimant", setup: rejected, function: "fileclaim", args: []string{"P1"}, code: E_ARGS},
+		{name: "settle without insurer", setup: damagedP1, function: "rejectclaim", args: []string{"P1", "CLM000001"}, code: E_ARGS},
+	})
+
+	// claims are listed in the order they were opened
+	stub := newTestStub(t, then(damagedP1,
+		[]string{"rejectclaim", "P1", "CLM000001", "INS"}, []string{"fileclaim", "P1", "CON", "broken"},
+		[]string{"rejectclaim", "P1", "CLM000002", "INS"}, []string{"fileclaim", "P1", "SHP", "late"})...)
+	claims := getClaimList(t, stub, "P1")
+	if len(claims) != 3 || claims[0].ClaimId != "CLM000001" || claims[1].ClaimId != "CLM000002" || claims[2].ClaimId != "CLM000003" || claims[2].FiledBy != "SHP" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	stub = newTestStub(t, createP1)
+	if claims := getClaimList(t, stub, "P1"); len(claims) != 0 {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+	_, err := stub.MockQuery("queryclaims")
+	checkCode(t, err, E_ARGS)
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	Condition channels - A package can declare limits on any number of sensor channels besides temprature. Values
+//					are tenths of the channel unit:
+//						humidity    - %RH
+//						shock       - g-force
+//						light       - light exposure, a limit of max 0 flags any exposure as tampering
+//					Readings on the temperature channel are applied with the temprature rules of the package.
+//==============================================================================================================================
+const (
+	CHANNEL_TEMPERATURE = "temperature"
+	CHANNEL_HUMIDITY    = "humidity"
+	CHANNEL_SHOCK       = "shock"
+	CHANNEL_LIGHT       = "light"
+)
+
+var channelNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+//==============================================================================================================================
+//	ConditionLimit - The limits of one channel of a package in tenths of the channel unit. A missing Min or Max
+//				leaves that side unbounded.
+//==============================================================================================================================
+type ConditionLimit struct {
+	Channel string `json:"channel"`
+	Min     *int   `json:"min,omitempty"`
+	Max     *int   `json:"max,omitempty"`
+}
+
+//==============================================================================================================================
+//	ConditionLimitInput - A channel limit as given to setconditions, in the channel unit, e.g.
+//					{"channel": "shock", "max": 2.5}
+//==============================================================================================================================
+type ConditionLimitInput struct {
+	Channel string       `json:"channel"`
+	Min     *json.Number `json:"min"`
+	Max     *json.Number `json:"max"`
+}
+
+//==============================================================================================================================
+//	ConditionReading - A single reading received on a non temprature channel. Readings are stored under the composite
+//				key ConditionReading~PkgId~Seq in the order they were received.
+//==============================================================================================================================
+type ConditionReading struct {
+	PkgId     string `json:"packageid"`
+	Seq       int    `json:"seq"`
+	Channel   string `json:"channel"`
+	Value     int    `json:"value"`
+	SensorId  string `json:"sensorid"`
+	Timestamp int64  `json:"timestamp"`
+	PkgStatus string `json:"pkgstatus"`
+}
+
+const conditionReadingObjectType = "ConditionReading"
+
+//==============================================================================================================================
+//	conditionReadingKey - ledger key of the seq'th condition reading of a package
+//==============================================================================================================================
+func conditionReadingKey(pkgId string, seq int) (string, error) {
+	return createCompositeKey(conditionReadingObjectType, []string{pkgId, fmt.Sprintf("%010d", seq)})
+}
+
+//==============================================================================================================================
+//	conditionLimit - the limit the package declares for channel, nil if it declares none
+//==============================================================================================================================
+func conditionLimit(packageinfo *PackageInfo, channel string) *ConditionLimit {
+	for i := range packageinfo.Conditions {
+		if packageinfo.Conditions[i].Channel == channel {
+			return &packageinfo.Conditions[i]
+		}
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	parseConditionValue - parses a channel reading. Light exposure may also be reported as true or false.
+//==============================================================================================================================
+func parseConditionValue(value string) (int, error) {
+	switch value {
+	case "true":
+		return 10, nil
+	case "false":
+		return 0, nil
+	}
+	return parseTenths(value)
+}
+
+//==============================================================================================================================
+//	recordConditionReading - applies a reading on a non temprature channel to the package, moving it to Pkg_Damaged,
+//				recording the channel that breached and opening a claim when the reading is outside of the channel
+//				limits, and persists the reading. As with recordTempReading the package is only changed once the
+//				reading is applied. The caller is responsible for writing the package back.
+//==============================================================================================================================
+func recordConditionReading(stub shim.ChaincodeStubInterface, original *PackageInfo, channel string, value int, sensorId string, timestamp int64) (ConditionReading, error) {
+	var reading ConditionReading
+
+	// readings are only accepted while the package is still moving through the lifecycle
+	if isTerminalStatus(original.PkgStatus) {
+		return reading, &IllegalTransitionError{From: original.PkgStatus, To: original.PkgStatus}
+	}
+
+	updated := *original
+	packageinfo := &updated
+
+	limit := conditionLimit(packageinfo, channel)
+	if limit == nil {
+		return reading, newError(E_ILLEGAL_STATE, "Package "+packageinfo.PkgId+" declares no limits for channel "+channel)
+	}
+
+	reason := ""
+	if limit.Max != nil && value > *limit.Max {
+		reason = fmt.Sprintf("%s reading %s above max %s", channel, formatTenths(value), formatTenths(*limit.Max))
+	} else if limit.Min != nil && value < *limit.Min {
+		reason = fmt.Sprintf("%s reading %s below min %s", channel, formatTenths(value), formatTenths(*limit.Min))
+	}
+
+	if reason != "" {
+		err := checkTransition(packageinfo.PkgStatus, STATUS_PKG_DAMAGED)
+		if err != nil {
+			return reading, err
+		}
+		packageinfo.PkgStatus = STATUS_PKG_DAMAGED
+		packageinfo.DamageChannel = channel
+	}
+
+	packageinfo.ConditionSeq++
+
+	reading.PkgId = packageinfo.PkgId
+	reading.Seq = packageinfo.ConditionSeq
+	reading.Channel = channel
+	reading.Value = value
+	reading.SensorId = sensorId
+	reading.Timestamp = timestamp
+	reading.PkgStatus = packageinfo.PkgStatus
+
+	// a reading that damages the package opens an insurance claim referencing it in the condition history
+	if reason != "" {
+		_, err := openClaim(stub, packageinfo, "system", reason, reading.Seq)
+		if err != nil {
+			return reading, err
+		}
+	}
+
+	key, err := conditionReadingKey(reading.PkgId, reading.Seq)
+	if err != nil {
+		return reading, err
+	}
+
+	bytes, err := json.Marshal(&reading)
+	if err != nil {
+		fmt.Println("Could not marshal condition reading object", err)
+		return reading, newError(E_INTERNAL, "Could not marshal condition reading object")
+	}
+
+	err = stub.PutState(key, bytes)
+	if err != nil {
+		return reading, newError(E_INTERNAL, "Failed writing to blockchain for condition reading "+key)
+	}
+
+	*original = updated
+	return reading, nil
+}
+
+//=================================================================================================================================
+//	setconditions - the Shipper declares the channel limits of a package before it is shipped, replacing any limits
+//				declared before. Expects PkgId, a JSON array of {channel, min, max} and, in insecure mode, the
+//				Shipper.
+//=================================================================================================================================
+func (t *SimpleChaincode) setconditions(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running setconditions()")
+
+	if len(args) != 2 && len(args) != 3 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId, JSON array of {channel, min, max} and, in insecure mode, Shipper")
+	}
+
+	packageinfo, err := getPackageInfo(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	err = authorizeParty(stub, args, 2, packageinfo.Shipper)
+	if err != nil {
+		return nil, wrapError(E_FORBIDDEN, "Wrong Shipper - Not authorized to change this Package", err)
+	}
+
+	if packageinfo.PkgStatus != STATUS_LABEL_GENERATED {
+		return nil, newError(E_ILLEGAL_STATE, "Channel limits can only be set in status "+STATUS_LABEL_GENERATED+", "+packageinfo.PkgId+" is "+packageinfo.PkgStatus)
+	}
+
+	var inputs []ConditionLimitInput
+	err = json.Unmarshal([]byte(args[1]), &inputs)
+	if err != nil {
+		return nil, wrapError(E_ARGS, "Could not unmarshal channel limits", err)
+	}
+
+	limits := []ConditionLimit{}
+	seen := []string{}
+	for _, input := range inputs {
+		if !channelNamePattern.MatchString(input.Channel) || input.Channel == CHANNEL_TEMPERATURE {
+			return nil, newError(E_ARGS, "Invalid channel "+input.Channel+", temprature limits are set with create")
+		}
+		if containsString(seen, input.Channel) {
+			return nil, newError(E_ARGS, "Channel "+input.Channel+" is given more than once")
+		}
+		seen = append(seen, input.Channel)
+
+		limit := ConditionLimit{Channel: input.Channel}
+		if input.Min != nil {
+			min, err := parseTenths(input.Min.String())
+			if err != nil {
+				return nil, err
+			}
+			limit.Min = &min
+		}
+		if input.Max != nil {
+			max, err := parseTenths(input.Max.String())
+			if err != nil {
+				return nil, err
+			}
+			limit.Max = &max
+		}
+		if limit.Min == nil && limit.Max == nil {
+			return nil, newError(E_ARGS, "Channel "+input.Channel+" needs a min or a max")
+		}
+		if limit.Min != nil && limit.Max != nil && *limit.Min > *limit.Max {
+			return nil, newError(E_ARGS, "Channel "+input.Channel+" min must not be greater than max")
+		}
+		limits = append(limits, limit)
+	}
+
+	packageinfo.Conditions = limits
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putPackageInfo(stub, &packageinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitPkgEvents(stub, []PkgEvent{{Event: EVENT_PKG_UPDATED, PkgId: packageinfo.PkgId, OldStatus: packageinfo.PkgStatus, NewStatus: packageinfo.PkgStatus, Actor: eventActor(stub, packageinfo.Shipper), Timestamp: timestamp}})
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	updatecondition - the carrier currently holding a package applies a sensor reading on one channel to it. Expects
+//				PkgId, Channel, value, Provider (only checked in insecure mode) and optional sensor id. Returns the
+//				recorded reading.
+//=================================================================================================================================
+func (t *SimpleChaincode) updatecondition(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running updatecondition()")
+
+	if len(args) < 3 || len(args) > 5 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId, Channel, value, Provider (in insecure mode) and optional sensor id")
+	}
+
+	packageinfo, err := getPackageInfo(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	custodian := currentCustodian(&packageinfo)
+	err = authorizeParty(stub, args, 3, custodian)
+	if err != nil {
+		return nil, wrapError(E_FORBIDDEN, "Wrong Provider - Not the current custodian of this Package", err)
+	}
+
+	// the packages of a sealed shipment take readings together through updateshipmenttemp
+	err = checkNotSealed(stub, &packageinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	var sensorId string
+	if len(args) == 5 {
+		sensorId = args[4]
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	event := PkgEvent{PkgId: packageinfo.PkgId, OldStatus: packageinfo.PkgStatus, Actor: eventActor(stub, custodian), Timestamp: timestamp}
+
+	var result interface{}
+	if args[1] == CHANNEL_TEMPERATURE {
+		reading, err := parseTemperature(args[2])
+		if err != nil {
+			return nil, err
+		}
+		result, err = recordTempReading(stub, &packageinfo, reading, sensorId, timestamp)
+		if err != nil {
+			return nil, err
+		}
+		event.Event = readingEventName(EVENT_PKG_TEMP_READING, packageinfo.PkgStatus)
+		event.Reading = &reading
+	} else {
+		value, err := parseConditionValue(args[2])
+		if err != nil {
+			return nil, err
+		}
+		result, err = recordConditionReading(stub, &packageinfo, args[1], value, sensorId, timestamp)
+		if err != nil {
+			return nil, err
+		}
+		event.Event = readingEventName(EVENT_PKG_CONDITION_READING, packageinfo.PkgStatus)
+		event.Channel = args[1]
+		event.Value = &value
+	}
+	event.NewStatus = packageinfo.PkgStatus
+
+	err = putPackageInfo(stub, &packageinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitPkgEvents(stub, []PkgEvent{event})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+//=================================================================================================================================
+//	queryconditionhistory - query function to read the non temprature readings of a package in the order they were
+//				received, with optional PageSize and Bookmark
+//=================================================================================================================================
+func (t *SimpleChaincode) queryconditionhistory(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	if len(args) < 1 || len(args) > 3 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId to query and optional PageSize and Bookmark")
+	}
+
+	opts, err := parseListOptions(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	startKey, endKey, err := compositeKeyRange(conditionReadingObjectType, []string{args[0]})
+	if err != nil {
+		return nil, err
+	}
+
+	items, bookmark, hasMore, err := scanRange(stub, startKey, endKey, opts, func(key string, valAsbytes []byte) (json.RawMessage, error) {
+		return json.RawMessage(valAsbytes), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return listResult(opts, items, bookmark, hasMore)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+var conditionsP1 = []string{"setconditions", "P1", `[{"channel": "humidity", "max": 80}, {"channel": "shock", "min": 0, "max": 5.5}, {"channel": "light", "max": 0}]`, "SHP"}
+
+func TestSetconditions(t *testing.T) {
+	runInvokeTests(t, []invokeTest{
+		{name: "set", setup: [][]string{createP1}, function: conditionsP1[0], args: conditionsP1[1:],
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				conditions := getPkg(t, stub, "P1").Conditions
+				if len(conditions) != 3 || conditions[1].Channel != CHANNEL_SHOCK || *conditions[1].Max != 55 || conditions[0].Min != nil {
+					t.Fatalf("unexpected conditions %+v", conditions)
+				}
+				if got := eventPkgIds(t, stub, EVENT_PKG_UPDATED); !equalStrings(got, []string{"P1"}) {
+					t.Fatalf("event for %v", got)
+				}
+			}},
+		{name: "not json", setup: [][]string{createP1}, function: "setconditions", args: []string{"P1", "humidity<80", "SHP"}, code: E_ARGS},
+		{name: "temperature channel", setup: [][]string{createP1}, function: "setconditions", args: []string{"P1", `[{"channel": "temperature", "max": 8}]`, "SHP"}, code: E_ARGS},
+		{name: "channel twice", setup: [][]string{createP1}, function: "setconditions", args: []string{"P1", `[{"channel": "shock", "max": 8}, {"channel": "shock", "max": 9}]`, "SHP"}, code: E_ARGS},
+		{name: "no bounds", setup: [][]string{createP1}, function: "setconditions", args: []string{"P1", `[{"channel": "shock"}]`, "SHP"}, code: E_ARGS},
+		{name: "min above max", setup: [][]string{createP1}, function: "setconditions", args: []string{"P1", `[{"channel": "shock", "min": 9, "max": 8}]`, "SHP"}, code: E_ARGS},
+		{name: "wrong shipper", setup: [][]string{createP1}, function: conditionsP1[0], args: []string{"P1", conditionsP1[2], "CON"}, code: E_FORBIDDEN},
+		{name: "already shipped", setup: [][]string{createP1, {"acceptpkg", "P1", "PRV"}}, function: conditionsP1[0], args: conditionsP1[1:], code: E_ILLEGAL_STATE},
+	})
+}
+
+func TestUpdatecondition(t *testing.T) {
+	withConditions := [][]string{createP1, conditionsP1, {"acceptpkg", "P1", "PRV"}}
+	damagedBy := func(channel string) func(t *testing.T, stub *mockstub.MockStub) {
+		return func(t *testing.T, stub *mockstub.MockStub) {
+			if packageinfo := getPkg(t, stub, "P1"); packageinfo.DamageChannel != channel {
+				t.Fatalf("damage channel %q, want %q", packageinfo.DamageChannel, channel)
+			}
+			// the claim points at the damaging reading, the first of its history in these tests
+			if claims := getClaimList(t, stub, "P1"); len(claims) != 1 || claims[0].ClaimStatus != CLAIM_OPEN || claims[0].ReadingSeq != 1 {
+				t.Fatalf("unexpected claims %+v", claims)
+			}
+			// temprature readings are announced without a channel, as with updatetemp
+			if name, event := lastEvent(t, stub); name != EVENT_PKG_DAMAGED || (event.Channel != channel && channel != CHANNEL_TEMPERATURE) {
+				t.Fatalf("unexpected event %s %+v", name, event)
+			}
+		}
+	}
+	runInvokeTests(t, []invokeTest{
+		{name: "in range", setup: withConditions, function: "updatecondition", args: []string{"P1", CHANNEL_HUMIDITY, "79.9", "PRV", "H1"}, status: STATUS_IN_TRANSIT,
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if name, event := lastEvent(t, stub); name != EVENT_PKG_CONDITION_READING || event.Value == nil || *event.Value != 799 || event.Actor != "PRV" {
+					t.Fatalf("unexpected event %s %+v", name, event)
+				}
+			}},
+		{name: "humidity too high", setup: withConditions, function: "updatecondition", args: []string{"P1", CHANNEL_HUMIDITY, "80.1", "PRV"}, status: STATUS_PKG_DAMAGED, check: damagedBy(CHANNEL_HUMIDITY)},
+		{name: "shock too low", setup: withConditions, function: "updatecondition", args: []string{"P1", CHANNEL_SHOCK, "-0.1", "PRV"}, status: STATUS_PKG_DAMAGED, check: damagedBy(CHANNEL_SHOCK)},
+		{name: "light exposure", setup: withConditions, function: "updatecondition", args: []string{"P1", CHANNEL_LIGHT, "true", "PRV"}, status: STATUS_PKG_DAMAGED, check: damagedBy(CHANNEL_LIGHT)},
+		{name: "no light", setup: withConditions, function: "updatecondition", args: []string{"P1", CHANNEL_LIGHT, "false", "PRV"}, status: STATUS_IN_TRANSIT},
+		{name: "temperature channel", setup: withConditions, function: "updatecondition", args: []string{"P1", CHANNEL_TEMPERATURE, "9", "PRV"}, status: STATUS_PKG_DAMAGED, check: damagedBy(CHANNEL_TEMPERATURE)},
+		{name: "undeclared channel", setup: [][]string{createP1}, function: "updatecondition", args: []string{"P1", CHANNEL_HUMIDITY, "50", "PRV"}, code: E_ILLEGAL_STATE},
+		{name: "bad value", setup: withConditions, function: "updatecondition", args: []string{"P1", CHANNEL_HUMIDITY, "wet", "PRV"}, code: E_ARGS},
+		{name: "after delivery", setup: then(withConditions, []string{"deliverpkg", "P1", "PRV"}, []string{"confirmdelivery", "P1", "CON"}), function: "updatecondition", args: []string{"P1", CHANNEL_HUMIDITY, "50", "PRV"}, code: E_ILLEGAL_STATE},
+		{name: "missing value", setup: withConditions, function: "updatecondition", args: []string{"P1", CHANNEL_HUMIDITY}, code: E_ARGS},
+		{name: "not the custodian", setup: withConditions, function: "updatecondition", args: []string{"P1", CHANNEL_SHOCK, "9", "PRV2"}, code: E_FORBIDDEN, status: STATUS_IN_TRANSIT},
+		{name: "missing provider", setup: withConditions, function: "updatecondition", args: []string{"P1", CHANNEL_SHOCK, "1"}, code: E_ARGS, status: STATUS_IN_TRANSIT},
+	})
+}
+
+func TestConditionClaimReadingSeq(t *testing.T) {
+	stub := newTestStub(t, createP1, conditionsP1, []string{"acceptpkg", "P1", "PRV"},
+		[]string{"updatecondition", "P1", CHANNEL_HUMIDITY, "40", "PRV"},
+		[]string{"updatecondition", "P1", CHANNEL_SHOCK, "1.5", "PRV"},
+		[]string{"updatetemp", "P1", "5", "PRV"},
+		[]string{"updatecondition", "P1", CHANNEL_SHOCK, "6", "PRV"},
+	)
+
+	claims := getClaimList(t, stub, "P1")
+	if len(claims) != 1 || claims[0].ReadingSeq != 3 {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	var readings []ConditionReading
+	if err := json.Unmarshal(mustQuery(t, stub, "queryconditionhistory", "P1"), &readings); err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != 3 || readings[2].Seq != claims[0].ReadingSeq || readings[2].Channel != CHANNEL_SHOCK || readings[2].PkgStatus != STATUS_PKG_DAMAGED {
+		t.Fatalf("claim does not point at the damaging reading %+v", readings)
+	}
+}
+
+func TestQueryconditionhistory(t *testing.T) {
+	stub := newTestStub(t, createP1, conditionsP1,
+		[]string{"updatecondition", "P1", CHANNEL_HUMIDITY, "40", "PRV"},
+		[]string{"updatecondition", "P1", CHANNEL_SHOCK, "1.5", "PRV"},
+		[]string{"updatecondition", "P1", CHANNEL_TEMPERATURE, "4", "PRV"},
+	)
+
+	var readings []ConditionReading
+	if err := json.Unmarshal(mustQuery(t, stub, "queryconditionhistory", "P1"), &readings); err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != 2 || readings[0].Channel != CHANNEL_HUMIDITY || readings[1].Seq != 2 || readings[1].Value != 15 {
+		t.Fatalf("unexpected readings %+v", readings)
+	}
+
+	_, err := stub.MockQuery("queryconditionhistory")
+	checkCode(t, err, E_ARGS)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	CustodyTransfer - One handover of a package between carriers. The full chain of transfers is kept on the package;
+//				PackageInfo.Provider stays the carrier the package was created with while PackageInfo.Custodian
+//				is the carrier currently holding it.
+//==============================================================================================================================
+type CustodyTransfer struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+//==============================================================================================================================
+//	currentCustodian - the carrier currently holding the package. Packages that were never handed over are held by
+//				their original Provider.
+//==============================================================================================================================
+func currentCustodian(packageinfo *PackageInfo) string {
+	if packageinfo.Custodian == "" {
+		return packageinfo.Provider
+	}
+	return packageinfo.Custodian
+}
+
+//=================================================================================================================================
+//	handoverpkg - the current custodian hands an in transit package over to the next carrier, which takes its place in
+//				the Provider indexes. Expects PkgId, NextProvider and the current custodian (only checked in insecure
+//				mode).
+//=================================================================================================================================
+func (t *SimpleChaincode) handoverpkg(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running handoverpkg()")
+
+	if len(args) != 2 && len(args) != 3 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId, NextProvider and, in insecure mode, the current Provider")
+	}
+
+	packageinfo, err := getPackageInfo(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if packageinfo.PkgStatus != STATUS_IN_TRANSIT {
+		return nil, newError(E_ILLEGAL_STATE, "Only packages in status "+STATUS_IN_TRANSIT+" can be handed over, "+packageinfo.PkgId+" is "+packageinfo.PkgStatus)
+	}
+
+	err = checkNotSealed(stub, &packageinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	custodian := currentCustodian(&packageinfo)
+
+	// check the caller is the carrier currently holding the package
+	err = authorizeParty(stub, args, 2, custodian)
+	if err != nil {
+		return nil, wrapError(E_FORBIDDEN, "Wrong Provider - Not the current custodian of this Package", err)
+	}
+
+	if args[1] == "" || args[1] == custodian {
+		return nil, newError(E_ARGS, "NextProvider must be a different carrier than "+custodian)
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	packageinfo.Custody = append(packageinfo.Custody, CustodyTransfer{From: custodian, To: args[1], Timestamp: timestamp})
+	packageinfo.Custodian = args[1]
+
+	err = putPackageInfo(stub, &packageinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitPkgEvents(stub, []PkgEvent{{Event: EVENT_PKG_HANDED_OVER, PkgId: packageinfo.PkgId, OldStatus: packageinfo.PkgStatus, NewStatus: packageinfo.PkgStatus, Actor: eventActor(stub, custodian), Timestamp: timestamp}})
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+func TestHandoverpkg(t *testing.T) {
+	inTransit := [][]string{createP1, {"acceptpkg", "P1", "PRV"}}
+	handedOver := then(inTransit, []string{"handoverpkg", "P1", "PRV2", "PRV"})
+	runInvokeTests(t, []invokeTest{
+		{name: "custodian hands over", setup: inTransit, function: "handoverpkg", args: []string{"P1", "PRV2", "PRV"}, status: STATUS_IN_TRANSIT,
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				packageinfo := getPkg(t, stub, "P1")
+				if packageinfo.Custodian != "PRV2" || packageinfo.Provider != "PRV" || len(packageinfo.Custody) != 1 || packageinfo.Custody[0].From != "PRV" {
+					t.Fatalf("unexpected custody %+v", packageinfo)
+				}
+				if name, event := lastEvent(t, stub); name != EVENT_PKG_HANDED_OVER || event.Actor != "PRV" || event.Package == nil || event.Package.Custodian != "PRV2" {
+					t.Fatalf("unexpected event %s %+v", name, event)
+				}
+				if got := pkgIds(t, mustQuery(t, stub, "querypkgbyprovider", "PRV2")); !equalStrings(got, []string{"P1"}) {
+					t.Fatalf("packages of the new custodian %v", got)
+				}
+				if got := pkgIds(t, mustQuery(t, stub, "querypkgbyprovider", "PRV")); len(got) != 0 {
+					t.Fatalf("packages of the old custodian %v", got)
+				}
+				if got := pkgIds(t, mustQuery(t, stub, "querybyrole_status", ROLE_PROVIDER, "PRV2", STATUS_IN_TRANSIT)); !equalStrings(got, []string{"P1"}) {
+					t.Fatalf("in transit packages of the new custodian %v", got)
+				}
+			}},
+		{name: "new custodian delivers", setup: handedOver, function: "deliverpkg", args: []string{"P1", "PRV2"}, status: STATUS_DELIVERY_PENDING},
+		{name: "old custodian can not deliver", setup: handedOver, function: "deliverpkg", args: []string{"P1", "PRV"}, code: E_FORBIDDEN},
+		{name: "not the custodian", setup: inTransit, function: "handoverpkg", args: []string{"P1", "PRV2", "PRV3"}, code: E_FORBIDDEN},
+		{name: "to itself", setup: inTransit, function: "handoverpkg", args: []string{"P1", "PRV", "PRV"}, code: E_ARGS},
+		{name: "not in transit", setup: [][]string{createP1}, function: "handoverpkg", args: []string{"P1", "PRV2", "PRV"}, code: E_ILLEGAL_STATE},
+		{name: "missing next provider", setup: inTransit, function: "handoverpkg", args: []string{"P1"}, code: E_ARGS},
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	DeliveryAttempt - One handover of the package to the Consignee. deliverpkg opens an attempt, the Consignee closes
+//				it with confirmdelivery or rejectdelivery. Every attempt is kept on the package so that rejected
+//				deliveries stay on the ledger after the package is delivered again.
+//==============================================================================================================================
+type DeliveryAttempt struct {
+	DeliveredBy   string `json:"deliveredby"`
+	SubmittedAt   int64  `json:"submittedat"`
+	Decision      string `json:"decision,omitempty"`
+	DecidedBy     string `json:"decidedby,omitempty"`
+	DecidedAt     int64  `json:"decidedat,omitempty"`
+	SignatureHash string `json:"signaturehash,omitempty"`
+	PhotoHash     string `json:"photohash,omitempty"`
+	Notes         string `json:"notes,omitempty"`
+}
+
+const (
+	DELIVERY_CONFIRMED = "Confirmed"
+	DELIVERY_REJECTED  = "Rejected"
+)
+
+//=================================================================================================================================
+//	confirmdelivery - the Consignee accepts a pending delivery, the package becomes Pkg_Delivered
+//=================================================================================================================================
+func (t *SimpleChaincode) confirmdelivery(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running confirmdelivery()")
+	return decideDelivery(stub, args, DELIVERY_CONFIRMED, STATUS_PKG_DELIVERED)
+}
+
+//=================================================================================================================================
+//	rejectdelivery - the Consignee refuses a pending delivery, the package becomes Delivery_Rejected
+//=================================================================================================================================
+func (t *SimpleChaincode) rejectdelivery(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running rejectdelivery()")
+	return decideDelivery(stub, args, DELIVERY_REJECTED, STATUS_DELIVERY_REJECTED)
+}
+
+//==============================================================================================================================
+//	decideDelivery - records the Consignee's decision on the open delivery attempt. Expects PkgId, Consignee (only
+//				checked in insecure mode, and optional in secure mode unless more arguments follow) and optional
+//				SignatureHash, PhotoHash and Notes.
+//==============================================================================================================================
+func decideDelivery(stub shim.ChaincodeStubInterface, args []string, decision string, status string) ([]byte, error) {
+
+	if len(args) < 1 || len(args) > 5 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId, Consignee (in insecure mode) and optional SignatureHash, PhotoHash and Notes")
+	}
+
+	packageinfo, err := getPackageInfo(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkPkgTransition(&packageinfo, status)
+	if err != nil {
+		return nil, err
+	}
+
+	// check the caller is the Consignee of the package
+	err = authorizeParty(stub, args, 1, packageinfo.Consignee)
+	if err != nil {
+		return nil, wrapError(E_FORBIDDEN, "Wrong Consignee - Not authorized to decide on this delivery", err)
+	}
+
+	if len(packageinfo.Deliveries) == 0 {
+		return nil, newError(E_ILLEGAL_STATE, "No delivery has been submitted for "+packageinfo.PkgId)
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt := &packageinfo.Deliveries[len(packageinfo.Deliveries)-1]
+	attempt.Decision = decision
+	attempt.DecidedBy = packageinfo.Consignee
+	attempt.DecidedAt = timestamp
+	if len(args) > 2 {
+		attempt.SignatureHash = args[2]
+	}
+	if len(args) > 3 {
+		attempt.PhotoHash = args[3]
+	}
+	if len(args) > 4 {
+		attempt.Notes = args[4]
+	}
+
+	event := PkgEvent{Event: EVENT_PKG_DELIVERY_CONFIRMED, PkgId: packageinfo.PkgId, OldStatus: packageinfo.PkgStatus, NewStatus: status, Actor: eventActor(stub, packageinfo.Consignee), Timestamp: timestamp}
+	if decision == DELIVERY_REJECTED {
+		event.Event = EVENT_PKG_DELIVERY_REJECTED
+	}
+
+	packageinfo.PkgStatus = status
+
+	err = putPackageInfo(stub, &packageinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitPkgEvents(stub, []PkgEvent{event})
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+var deliveredP1 = [][]string{createP1, {"acceptpkg", "P1", "PRV"}, {"deliverpkg", "P1", "PRV"}}
+
+func TestConfirmdelivery(t *testing.T) {
+	runInvokeTests(t, []invokeTest{
+		{name: "consignee confirms", setup: deliveredP1, function: "confirmdelivery", args: []string{"P1", "CON", "sig", "photo", "left at door"}, status: STATUS_PKG_DELIVERED,
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				attempt := getPkg(t, stub, "P1").Deliveries[0]
+				if attempt.Decision != DELIVERY_CONFIRMED || attempt.DecidedBy != "CON" || attempt.SignatureHash != "sig" || attempt.PhotoHash != "photo" || attempt.Notes != "left at door" {
+					t.Fatalf("unexpected delivery attempt %+v", attempt)
+				}
+				if name, event := lastEvent(t, stub); name != EVENT_PKG_DELIVERY_CONFIRMED || event.NewStatus != STATUS_PKG_DELIVERED {
+					t.Fatalf("unexpected event %s %+v", name, event)
+				}
+			}},
+		{name: "wrong consignee", setup: deliveredP1, function: "confirmdelivery", args: []string{"P1", "SHP"}, code: E_FORBIDDEN, status: STATUS_DELIVERY_PENDING},
+		{name: "not delivered", setup: [][]string{createP1, {"acceptpkg", "P1", "PRV"}}, function: "confirmdelivery", args: []string{"P1", "CON"}, code: E_ILLEGAL_STATE},
+		{name: "unknown package", function: "confirmdelivery", args: []string{"P9", "CON"}, code: E_NOT_FOUND},
+		{name: "too many arguments", setup: deliveredP1, function: "confirmdelivery", args: []string{"P1", "CON", "a", "b", "c", "d"}, code: E_ARGS},
+	})
+}
+
+func TestRejectdelivery(t *testing.T) {
+	rejected := then(deliveredP1, []string{"rejectdelivery", "P1", "CON"})
+	runInvokeTests(t, []invokeTest{
+		{name: "consignee rejects", setup: deliveredP1, function: "rejectdelivery", args: []string{"P1", "CON", "", "", "wrong address"}, status: STATUS_DELIVERY_REJECTED,
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if name, _ := lastEvent(t, stub); name != EVENT_PKG_DELIVERY_REJECTED {
+					t.Fatalf("unexpected event %s", name)
+				}
+			}},
+		{name: "redelivered after rejection", setup: rejected, function: "deliverpkg", args: []string{"P1", "PRV"}, status: STATUS_DELIVERY_PENDING,
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				deliveries := getPkg(t, stub, "P1").Deliveries
+				if len(deliveries) != 2 || deliveries[0].Decision != DELIVERY_REJECTED || deliveries[1].Decision != "" {
+					t.Fatalf("unexpected deliveries %+v", deliveries)
+				}
+			}},
+		{name: "rejected twice", setup: rejected, function: "rejectdelivery", args: []string{"P1", "CON"}, code: E_ILLEGAL_STATE},
+		{name: "missing consignee", setup: deliveredP1, function: "rejectdelivery", args: []string{"P1"}, code: E_ARGS},
+	})
+}
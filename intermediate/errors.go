@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+//==============================================================================================================================
+//	ChaincodeError - The error returned by every Init, Invoke and Query function. Its text is the JSON
+//					{"code": "E_NOT_FOUND", "message": "Package 1Z20170426 not found"}
+//				so that clients can branch on the code instead of parsing the message.
+//==============================================================================================================================
+type ChaincodeError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+//==============================================================================================================================
+//	 Error codes - Stable codes clients can rely on; messages may change
+//==============================================================================================================================
+const (
+	E_ARGS          = "E_ARGS"          // wrong number or format of arguments
+	E_NOT_FOUND     = "E_NOT_FOUND"     // an object named by the arguments does not exist
+	E_DUPLICATE     = "E_DUPLICATE"     // the object to create already exists
+	E_FORBIDDEN     = "E_FORBIDDEN"     // the caller may not call the function or change the object
+	E_ILLEGAL_STATE = "E_ILLEGAL_STATE" // the object is not in a state that allows the change
+	E_DECODE        = "E_DECODE"        // an object stored on the ledger could not be decoded
+	E_INTERNAL      = "E_INTERNAL"      // the ledger could not be read or written
+)
+
+func (e *ChaincodeError) Error() string {
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return e.Code + ": " + e.Message
+	}
+	return string(bytes)
+}
+
+//==============================================================================================================================
+//	newError - a ChaincodeError with the given code and message
+//==============================================================================================================================
+func newError(code string, message string) error {
+	return &ChaincodeError{Code: code, Message: message}
+}
+
+//==============================================================================================================================
+//	wrapError - a ChaincodeError with code whose message is message followed by the message of err. Argument and
+//				ledger errors keep their own code, they explain the failure better than code does.
+//==============================================================================================================================
+func wrapError(code string, message string, err error) error {
+	if chaincodeErr, ok := err.(*ChaincodeError); ok {
+		switch chaincodeErr.Code {
+		case E_ARGS, E_DECODE, E_INTERNAL:
+			code = chaincodeErr.Code
+		}
+	}
+	return &ChaincodeError{Code: code, Message: message + ": " + errorMessage(err)}
+}
+
+//==============================================================================================================================
+//	errorCode - the code of err, E_INTERNAL for errors that are not ChaincodeErrors
+//==============================================================================================================================
+func errorCode(err error) string {
+	switch e := err.(type) {
+	case *ChaincodeError:
+		return e.Code
+	case *IllegalTransitionError:
+		return E_ILLEGAL_STATE
+	}
+	return E_INTERNAL
+}
+
+//==============================================================================================================================
+//	errorMessage - the message of err without its code
+//==============================================================================================================================
+func errorMessage(err error) string {
+	switch e := err.(type) {
+	case *ChaincodeError:
+		return e.Message
+	case *IllegalTransitionError:
+		return e.message()
+	}
+	return err.Error()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChaincodeError(t *testing.T) {
+	err := newError(E_NOT_FOUND, `Invalid PackageId Passed "P1"`)
+	if got := err.Error(); got != `{"code":"E_NOT_FOUND","message":"Invalid PackageId Passed \"P1\""}` {
+		t.Fatalf("Error() = %s", got)
+	}
+
+	transition := &IllegalTransitionError{From: STATUS_PKG_DELIVERED, To: STATUS_IN_TRANSIT}
+	if got := transition.Error(); got != `{"code":"E_ILLEGAL_STATE","message":"illegal transition from Pkg_Delivered to In_Transit"}` {
+		t.Fatalf("Error() = %s", got)
+	}
+
+	tests := []struct {
+		code    string
+		err     error
+		want    string
+		message string
+	}{
+		{E_FORBIDDEN, newError(E_FORBIDDEN, "Caller X is not authorized"), E_FORBIDDEN, "Wrong: Caller X is not authorized"},
+		{E_FORBIDDEN, newError(E_ARGS, "caller missing"), E_ARGS, "Wrong: caller missing"},
+		{E_FORBIDDEN, newError(E_INTERNAL, "no certificate"), E_INTERNAL, "Wrong: no certificate"},
+		{E_ARGS, errors.New("unexpected end of JSON input"), E_ARGS, "Wrong: unexpected end of JSON input"},
+		{E_FORBIDDEN, transition, E_FORBIDDEN, "Wrong: illegal transition from Pkg_Delivered to In_Transit"},
+	}
+	for _, test := range tests {
+		wrapped := wrapError(test.code, "Wrong", test.err)
+		if errorCode(wrapped) != test.want || errorMessage(wrapped) != test.message {
+			t.Errorf("wrapError(%s, %v) = %v", test.code, test.err, wrapped)
+		}
+	}
+
+	if errorCode(errors.New("boom")) != E_INTERNAL || errorMessage(errors.New("boom")) != "boom" {
+		t.Fatal("plain errors are not reported as E_INTERNAL")
+	}
+}
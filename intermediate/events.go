@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	PkgEvent - The payload of the chaincode events emitted by every transaction that writes a package: when it is
+//				created, changes status, receives a reading or has its settings changed or migrated, so that
+//				listeners do not have to poll. Reading is the temprature in tenths of a degree
+//				Celsius; Channel and Value are set for readings on other channels. Package is the package as
+//				written by the transaction, so that off-chain read models can be kept without querying the ledger.
+//==============================================================================================================================
+type PkgEvent struct {
+	Event     string       `json:"event"`
+	PkgId     string       `json:"packageid"`
+	OldStatus string       `json:"oldstatus,omitempty"`
+	NewStatus string       `json:"newstatus"`
+	Actor     string       `json:"actor"`
+	Reading   *Temperature `json:"reading,omitempty"`
+	Channel   string       `json:"channel,omitempty"`
+	Value     *int         `json:"value,omitempty"`
+	Timestamp int64        `json:"timestamp"`
+	Package   *PackageInfo `json:"package,omitempty"`
+}
+
+//==============================================================================================================================
+//	 Event names - The name a single PkgEvent is emitted under. A transaction can only emit one chaincode event, so
+//					transactions that produce more than one PkgEvent emit a JSON array of them under
+//					EVENT_PKG_BATCH.
+//==============================================================================================================================
+const (
+	EVENT_PKG_CREATED            = "PkgCreated"
+	EVENT_PKG_ACCEPTED           = "PkgAccepted"
+	EVENT_PKG_HANDED_OVER        = "PkgHandedOver"
+	EVENT_PKG_DELIVERED          = "PkgDelivered"
+	EVENT_PKG_DELIVERY_CONFIRMED = "PkgDeliveryConfirmed"
+	EVENT_PKG_DELIVERY_REJECTED  = "PkgDeliveryRejected"
+	EVENT_PKG_TEMP_READING       = "PkgTempReading"
+	EVENT_PKG_CONDITION_READING  = "PkgConditionReading"
+	EVENT_PKG_DAMAGED            = "PkgDamaged"
+	EVENT_PKG_CANCELLED          = "PkgCancelled"
+	EVENT_PKG_ARCHIVED           = "PkgArchived"
+	EVENT_PKG_UPDATED            = "PkgUpdated"
+	EVENT_PKG_MIGRATED           = "PkgMigrated"
+	EVENT_PKG_BATCH              = "PkgEventBatch"
+)
+
+//==============================================================================================================================
+//	eventActor - the party to report as the actor of an event: the caller when identities are checked, fallback (the
+//				party named in the arguments) in insecure mode or when the caller can not be identified
+//==============================================================================================================================
+func eventActor(stub shim.ChaincodeStubInterface, fallback string) string {
+	mode, err := getAuthMode(stub)
+	if err != nil || mode == AUTH_MODE_INSECURE {
+		return fallback
+	}
+
+	caller, err := getCallerParty(stub)
+	if err != nil {
+		return fallback
+	}
+	return caller
+}
+
+//==============================================================================================================================
+//	readingEventName - the event a reading is emitted under, PkgDamaged if the reading damaged the package
+//==============================================================================================================================
+func readingEventName(name string, newStatus string) string {
+	if newStatus == STATUS_PKG_DAMAGED {
+		return EVENT_PKG_DAMAGED
+	}
+	return name
+}
+
+//==============================================================================================================================
+//	emitPkgEvents - attaches the package snapshots and sets the chaincode event of the transaction: a single event
+//				under its own name, several as a JSON array under EVENT_PKG_BATCH. Must be called after the packages
+//				are written or archived.
+//==============================================================================================================================
+func emitPkgEvents(stub shim.ChaincodeStubInterface, events []PkgEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	snapshots := map[string]*PackageInfo{}
+	for i := range events {
+		snapshot, ok := snapshots[events[i].PkgId]
+		if !ok {
+			packageinfo, err := getPackageRecord(stub, events[i].PkgId)
+			if err != nil {
+				return err
+			}
+			snapshot = &packageinfo
+			snapshots[events[i].PkgId] = snapshot
+		}
+		events[i].Package = snapshot
+	}
+
+	var name string
+	var payload []byte
+	var err error
+	if len(events) == 1 {
+		name = events[0].Event
+		payload, err = json.Marshal(events[0])
+	} else {
+		name = EVENT_PKG_BATCH
+		payload, err = json.Marshal(events)
+	}
+	if err != nil {
+		fmt.Println("Could not marshal package event", err)
+		return newError(E_INTERNAL, "Could not marshal package event")
+	}
+
+	err = stub.SetEvent(name, payload)
+	if err != nil {
+		return newError(E_INTERNAL, "Failed to set event "+name)
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	emitMigratedEvents - emits EVENT_PKG_MIGRATED for packages rewritten or reindexed by an administrator, which keep
+//				their status
+//==============================================================================================================================
+func emitMigratedEvents(stub shim.ChaincodeStubInterface, packages []PackageInfo) error {
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return err
+	}
+
+	actor := eventActor(stub, ROLE_ADMIN)
+	events := make([]PkgEvent, 0, len(packages))
+	for i := range packages {
+		events = append(events, PkgEvent{Event: EVENT_PKG_MIGRATED, PkgId: packages[i].PkgId, OldStatus: packages[i].PkgStatus, NewStatus: packages[i].PkgStatus, Actor: actor, Timestamp: timestamp})
+	}
+	return emitPkgEvents(stub, events)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	ExcursionPolicy - How long a package may stay outside of [TempratureMin, TempratureMax] before it is damaged.
+//				MaxSingleSecs bounds one continuous excursion and MaxCumulativeSecs all excursions together.
+//				A reading outside of [HardMin, HardMax] damages the package immediately. Packages without a
+//				policy are damaged by the first reading outside of [TempratureMin, TempratureMax].
+//==============================================================================================================================
+type ExcursionPolicy struct {
+	MaxCumulativeSecs int64       `json:"maxcumulativesecs"`
+	MaxSingleSecs     int64       `json:"maxsinglesecs"`
+	HardMin           Temperature `json:"hardmin"`
+	HardMax           Temperature `json:"hardmax"`
+}
+
+//==============================================================================================================================
+//	ExcursionState - Excursion time accumulated over the readings received so far. Time is accrued between a reading
+//				outside of the range and the next reading. ResumeStatus is the status the package returns to
+//				when a reading is back in range.
+//==============================================================================================================================
+type ExcursionState struct {
+	Active         bool   `json:"active"`
+	StartedAt      int64  `json:"startedat,omitempty"`
+	LastAt         int64  `json:"lastat,omitempty"`
+	CumulativeSecs int64  `json:"cumulativesecs"`
+	ResumeStatus   string `json:"resumestatus,omitempty"`
+}
+
+//==============================================================================================================================
+//	evaluateReading - applies a reading to the excursion state of the package and reports whether it damages the
+//				package, with the reason to open the claim with. A package entering an excursion is moved to
+//				Temp_Warning and returned to its previous status when a reading is back in range; moving it to
+//				Pkg_Damaged is left to the caller.
+//==============================================================================================================================
+func evaluateReading(packageinfo *PackageInfo, reading Temperature, timestamp int64) (bool, string, error) {
+	outOfRange := reading > packageinfo.TempratureMax || reading < packageinfo.TempratureMin
+
+	policy := packageinfo.ExcursionPolicy
+	if policy == nil {
+		if outOfRange {
+			return true, fmt.Sprintf("Temprature reading %s outside of [%s, %s]", reading, packageinfo.TempratureMin, packageinfo.TempratureMax), nil
+		}
+		return false, "", nil
+	}
+
+	if reading > policy.HardMax || reading < policy.HardMin {
+		return true, fmt.Sprintf("Temprature reading %s outside of hard limits [%s, %s]", reading, policy.HardMin, policy.HardMax), nil
+	}
+
+	if packageinfo.Excursion == nil {
+		packageinfo.Excursion = &ExcursionState{}
+	}
+	state := packageinfo.Excursion
+
+	// the time since the previous reading counts against the policy while an excursion is in progress
+	if state.Active && timestamp > state.LastAt {
+		state.CumulativeSecs += timestamp - state.LastAt
+		state.LastAt = timestamp
+	}
+
+	// an excursion in progress counts up to this reading against the single limit, also when this reading ends it
+	if state.Active {
+		if single := state.LastAt - state.StartedAt; single > policy.MaxSingleSecs {
+			return true, fmt.Sprintf("Temprature excursion of %ds exceeds the single excursion limit of %ds", single, policy.MaxSingleSecs), nil
+		}
+	}
+
+	if outOfRange {
+		if !state.Active {
+			err := checkTransition(packageinfo.PkgStatus, STATUS_TEMP_WARNING)
+			if err != nil {
+				return false, "", err
+			}
+			state.Active = true
+			state.StartedAt = timestamp
+			state.LastAt = timestamp
+			state.ResumeStatus = packageinfo.PkgStatus
+			packageinfo.PkgStatus = STATUS_TEMP_WARNING
+		}
+	} else if state.Active {
+		err := checkTransition(packageinfo.PkgStatus, state.ResumeStatus)
+		if err != nil {
+			return false, "", err
+		}
+		packageinfo.PkgStatus = state.ResumeStatus
+		state.Active = false
+		state.ResumeStatus = ""
+	}
+
+	if state.CumulativeSecs > policy.MaxCumulativeSecs {
+		return true, fmt.Sprintf("Temprature excursions of %ds exceed the cumulative limit of %ds", state.CumulativeSecs, policy.MaxCumulativeSecs), nil
+	}
+
+	return false, "", nil
+}
+
+//=================================================================================================================================
+//	setexcursionpolicy - the Shipper sets the excursion policy of a package before it is shipped. Expects PkgId,
+//				MaxCumulativeSecs, MaxSingleSecs, HardMin, HardMax and, in insecure mode, the Shipper.
+//=================================================================================================================================
+func (t *SimpleChaincode) setexcursionpolicy(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running setexcursionpolicy()")
+
+	if len(args) != 5 && len(args) != 6 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId, MaxCumulativeSecs, MaxSingleSecs, HardMin, HardMax and, in insecure mode, Shipper")
+	}
+
+	packageinfo, err := getPackageInfo(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	err = authorizeParty(stub, args, 5, packageinfo.Shipper)
+	if err != nil {
+		return nil, wrapError(E_FORBIDDEN, "Wrong Shipper - Not authorized to change this Package", err)
+	}
+
+	if packageinfo.PkgStatus != STATUS_LABEL_GENERATED {
+		return nil, newError(E_ILLEGAL_STATE, "The excursion policy can only be set in status "+STATUS_LABEL_GENERATED+", "+packageinfo.PkgId+" is "+packageinfo.PkgStatus)
+	}
+
+	policy, err := parseExcursionPolicy(args[1:5], packageinfo.TempratureMin, packageinfo.TempratureMax)
+	if err != nil {
+		return nil, err
+	}
+
+	packageinfo.ExcursionPolicy = &policy
+	packageinfo.Excursion = nil
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putPackageInfo(stub, &packageinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitPkgEvents(stub, []PkgEvent{{Event: EVENT_PKG_UPDATED, PkgId: packageinfo.PkgId, OldStatus: packageinfo.PkgStatus, NewStatus: packageinfo.PkgStatus, Actor: eventActor(stub, packageinfo.Shipper), Timestamp: timestamp}})
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+//==============================================================================================================================
+//	parseExcursionPolicy - parses MaxCumulativeSecs, MaxSingleSecs, HardMin and HardMax, checking the hard limits
+//				include the temprature range [tempMin, tempMax]
+//==============================================================================================================================
+func parseExcursionPolicy(args []string, tempMin Temperature, tempMax Temperature) (ExcursionPolicy, error) {
+	var policy ExcursionPolicy
+	var err error
+
+	policy.MaxCumulativeSecs, err = strconv.ParseInt(args[0], 10, 64)
+	if err != nil || policy.MaxCumulativeSecs < 0 {
+		return policy, newError(E_ARGS, "MaxCumulativeSecs must be a non negative numeric string")
+	}
+	policy.MaxSingleSecs, err = strconv.ParseInt(args[1], 10, 64)
+	if err != nil || policy.MaxSingleSecs < 0 {
+		return policy, newError(E_ARGS, "MaxSingleSecs must be a non negative numeric string")
+	}
+	policy.HardMin, err = parseTemperature(args[2])
+	if err != nil {
+		return policy, newError(E_ARGS, "HardMin must be a temprature such as 4.5, 4.5C or 40.1F")
+	}
+	policy.HardMax, err = parseTemperature(args[3])
+	if err != nil {
+		return policy, newError(E_ARGS, "HardMax must be a temprature such as 4.5, 4.5C or 40.1F")
+	}
+
+	if policy.HardMin > tempMin || policy.HardMax < tempMax {
+		return policy, newError(E_ARGS, fmt.Sprintf("Hard limits [%s, %s] must include the temprature range [%s, %s]", policy.HardMin, policy.HardMax, tempMin, tempMax))
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+func TestSetexcursionpolicy(t *testing.T) {
+	runInvokeTests(t, []invokeTest{
+		{name: "set", setup: [][]string{createP1}, function: "setexcursionpolicy", args: []string{"P1", "600", "300", "-5", "15", "SHP"},
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if getPkg(t, stub, "P1").ExcursionPolicy == nil {
+					t.Fatal("no excursion policy")
+				}
+				if got := eventPkgIds(t, stub, EVENT_PKG_UPDATED); !equalStrings(got, []string{"P1"}) {
+					t.Fatalf("event for %v", got)
+				}
+			}},
+		{name: "wrong shipper", setup: [][]string{createP1}, function: "setexcursionpolicy", args: []string{"P1", "600", "300", "-5", "15", "CON"}, code: E_FORBIDDEN},
+		{name: "already shipped", setup: [][]string{createP1, {"acceptpkg", "P1", "PRV"}}, function: "setexcursionpolicy", args: []string{"P1", "600", "300", "-5", "15", "SHP"}, code: E_ILLEGAL_STATE},
+		{name: "negative limit", setup: [][]string{createP1}, function: "setexcursionpolicy", args: []string{"P1", "-1", "300", "-5", "15", "SHP"}, code: E_ARGS},
+		{name: "hard limits inside range", setup: [][]string{createP1}, function: "setexcursionpolicy", args: []string{"P1", "600", "300", "5", "15", "SHP"}, code: E_ARGS},
+		{name: "unknown package", function: "setexcursionpolicy", args: []string{"P9", "600", "300", "-5", "15", "SHP"}, code: E_NOT_FOUND},
+	})
+}
+
+func TestExcursions(t *testing.T) {
+	// readings one minute apart against at most 300s of excursions in total and 180s in one go
+	tests := []struct {
+		name     string
+		readings []string
+		statuses []string
+	}{
+		{"short excursion recovers", []string{"10", "10", "5"}, []string{STATUS_TEMP_WARNING, STATUS_TEMP_WARNING, STATUS_IN_TRANSIT}},
+		{"single limit", []string{"10", "10", "10", "10", "10"}, []string{STATUS_TEMP_WARNING, STATUS_TEMP_WARNING, STATUS_TEMP_WARNING, STATUS_TEMP_WARNING, STATUS_PKG_DAMAGED}},
+		{"single limit ended by an in range reading", []string{"10", "10", "10", "10", "5"}, []string{STATUS_TEMP_WARNING, STATUS_TEMP_WARNING, STATUS_TEMP_WARNING, STATUS_TEMP_WARNING, STATUS_PKG_DAMAGED}},
+		{"cumulative limit", []string{"10", "10", "10", "5", "10", "10", "10", "10"}, []string{STATUS_TEMP_WARNING, STATUS_TEMP_WARNING, STATUS_TEMP_WARNING, STATUS_IN_TRANSIT, STATUS_TEMP_WARNING, STATUS_TEMP_WARNING, STATUS_TEMP_WARNING, STATUS_PKG_DAMAGED}},
+		{"hard limit", []string{"10", "15.1"}, []string{STATUS_TEMP_WARNING, STATUS_PKG_DAMAGED}},
+		{"cold excursion", []string{"1", "-5.1"}, []string{STATUS_TEMP_WARNING, STATUS_PKG_DAMAGED}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newTestStub(t, createP1, []string{"setexcursionpolicy", "P1", "300", "180", "-5", "15", "SHP"}, []string{"acceptpkg", "P1", "PRV"})
+			stub.Step = time.Minute
+			for i, reading := range test.readings {
+				mustInvoke(t, stub, "updatetemp", "P1", reading, "PRV")
+				if got := getPkg(t, stub, "P1").PkgStatus; got != test.statuses[i] {
+					t.Fatalf("after reading %d (%s) P1 is %s, want %s", i+1, reading, got, test.statuses[i])
+				}
+			}
+		})
+	}
+
+	stub := newTestStub(t, createP1, []string{"setexcursionpolicy", "P1", "300", "180", "-5", "15", "SHP"}, []string{"acceptpkg", "P1", "PRV"}, []string{"updatetemp", "P1", "10", "PRV"})
+	_, err := stub.MockInvoke("deliverpkg", "P1", "PRV")
+	checkCode(t, err, E_ILLEGAL_STATE)
+
+	// a package in Temp_Warning only returns to the status it had before the excursion
+	stub = newTestStub(t, createP1, []string{"setexcursionpolicy", "P1", "300", "180", "-5", "15", "SHP"}, []string{"updatetemp", "P1", "10", "PRV"})
+	_, err = stub.MockInvoke("acceptpkg", "P1", "PRV")
+	checkCode(t, err, E_ILLEGAL_STATE)
+	packageinfo := getPkg(t, stub, "P1")
+	for to, allowed := range map[string]bool{STATUS_LABEL_GENERATED: true, STATUS_PKG_DAMAGED: true, STATUS_IN_TRANSIT: false, STATUS_DELIVERY_PENDING: false} {
+		if err := checkPkgTransition(&packageinfo, to); (err == nil) != allowed {
+			t.Errorf("checkPkgTransition(%s, %s) = %v", packageinfo.PkgStatus, to, err)
+		}
+	}
+	mustInvoke(t, stub, "updatetemp", "P1", "5", "PRV")
+	if got := getPkg(t, stub, "P1").PkgStatus; got != STATUS_LABEL_GENERATED {
+		t.Fatalf("P1 is %s after the excursion", got)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	 Caller identity - Parties on a package (Shipper, Provider, ...) are organisation names, so the caller's party is
+//					read from the "party" attribute of its transaction certificate. Callers whose certificate has
+//					no such attribute are identified by the common name of their certificate (the enrollment id).
+//==============================================================================================================================
+const callerPartyAttribute = "party"
+
+//==============================================================================================================================
+//	 Auth mode - In the default secure mode parties are authorized from the caller's certificate. The insecure mode
+//					(for development networks without membership services) keeps the old behaviour of trusting a
+//					party name passed as an argument. The mode is chosen at deploy time and stored in the
+//					ChaincodeConfig; deployments from before the config existed stored it under authModeKey.
+//==============================================================================================================================
+const authModeKey = "AuthModeKey"
+
+const (
+	AUTH_MODE_SECURE   = "secure"
+	AUTH_MODE_INSECURE = "insecure"
+)
+
+//==============================================================================================================================
+//	getAuthMode - returns the auth mode the chaincode was deployed with, secure if none was recorded
+//==============================================================================================================================
+func getAuthMode(stub shim.ChaincodeStubInterface) (string, error) {
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return "", err
+	}
+
+	var mode string
+	if config != nil {
+		mode = config.AuthMode
+	} else {
+		legacymode, err := stub.GetState(authModeKey)
+		if err != nil {
+			return "", newError(E_INTERNAL, "Failed to get state for "+authModeKey)
+		}
+		mode = string(legacymode)
+	}
+
+	if mode == AUTH_MODE_INSECURE {
+		return AUTH_MODE_INSECURE, nil
+	}
+	return AUTH_MODE_SECURE, nil
+}
+
+//==============================================================================================================================
+//	getCallerParty - derives the identity of the caller of the current transaction from its certificate
+//==============================================================================================================================
+func getCallerParty(stub shim.ChaincodeStubInterface) (string, error) {
+	party, err := stub.ReadCertAttribute(callerPartyAttribute)
+	if err == nil && len(party) > 0 {
+		return string(party), nil
+	}
+
+	certificate, err := stub.GetCallerCertificate()
+	if err != nil || len(certificate) == 0 {
+		return "", newError(E_INTERNAL, "Failed to get caller certificate")
+	}
+
+	// certificates are passed DER encoded, accept PEM as well
+	if block, _ := pem.Decode(certificate); block != nil {
+		certificate = block.Bytes
+	}
+
+	cert, err := x509.ParseCertificate(certificate)
+	if err != nil {
+		fmt.Println("Could not parse caller certificate", err)
+		return "", newError(E_FORBIDDEN, "Could not parse caller certificate")
+	}
+
+	if cert.Subject.CommonName == "" {
+		return "", newError(E_FORBIDDEN, "Caller certificate has no common name")
+	}
+	return cert.Subject.CommonName, nil
+}
+
+//==============================================================================================================================
+//	getCallerFromArgs - identifies the caller of the current transaction. In insecure mode the caller is instead taken
+//				from args[argIndex], which must then be present.
+//==============================================================================================================================
+func getCallerFromArgs(stub shim.ChaincodeStubInterface, args []string, argIndex int) (string, error) {
+	mode, err := getAuthMode(stub)
+	if err != nil {
+		return "", err
+	}
+
+	if mode == AUTH_MODE_INSECURE {
+		if len(args) <= argIndex {
+			return "", newError(E_ARGS, "Insecure mode - caller must be passed as argument "+fmt.Sprint(argIndex+1))
+		}
+		return args[argIndex], nil
+	}
+
+	return getCallerParty(stub)
+}
+
+//==============================================================================================================================
+//	authorizeParty - checks that the caller, as returned by getCallerFromArgs, is party
+//==============================================================================================================================
+func authorizeParty(stub shim.ChaincodeStubInterface, args []string, argIndex int, party string) error {
+	caller, err := getCallerFromArgs(stub, args, argIndex)
+	if err != nil {
+		return err
+	}
+
+	if caller != party {
+		return newError(E_FORBIDDEN, "Caller "+caller+" is not authorized, expecting "+party)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	 Composite keys - The v0.6 shim has no composite key support, so keys are built here in the same layout later
+//					fabric releases use: a leading namespace byte, the object type and every attribute each terminated
+//					by the separator. All keys of one object type (and any leading attributes) share a prefix and
+//					can be enumerated with a single RangeQueryState.
+//==============================================================================================================================
+const compositeKeyNamespace = "\x00"
+const compositeKeySeparator = "\x00"
+const maxUnicodeRuneValue = utf8.MaxRune
+
+//==============================================================================================================================
+//	createCompositeKey - builds the ledger key for objectType from the given attributes
+//==============================================================================================================================
+func createCompositeKey(objectType string, attributes []string) (string, error) {
+	if !utf8.ValidString(objectType) || strings.Contains(objectType, compositeKeySeparator) {
+		return "", newError(E_ARGS, "Invalid object type for composite key "+objectType)
+	}
+
+	key := compositeKeyNamespace + objectType + compositeKeySeparator
+	for _, attribute := range attributes {
+		if !utf8.ValidString(attribute) || strings.Contains(attribute, compositeKeySeparator) {
+			return "", newError(E_ARGS, "Invalid attribute for composite key "+attribute)
+		}
+		key += attribute + compositeKeySeparator
+	}
+	return key, nil
+}
+
+//==============================================================================================================================
+//	splitCompositeKey - returns the object type and attributes a composite key was built from
+//==============================================================================================================================
+func splitCompositeKey(key string) (string, []string, error) {
+	if !strings.HasPrefix(key, compositeKeyNamespace) || !strings.HasSuffix(key, compositeKeySeparator) {
+		return "", nil, newError(E_DECODE, "Not a composite key")
+	}
+
+	components := strings.Split(key[len(compositeKeyNamespace):len(key)-len(compositeKeySeparator)], compositeKeySeparator)
+	return components[0], components[1:], nil
+}
+
+//==============================================================================================================================
+//	compositeKeyRange - returns the start and end key for a RangeQueryState over every key of objectType whose
+//				leading attributes match the given partial attributes
+//==============================================================================================================================
+func compositeKeyRange(objectType string, attributes []string) (string, string, error) {
+	startKey, err := createCompositeKey(objectType, attributes)
+	if err != nil {
+		return "", "", err
+	}
+	return startKey, startKey + string(maxUnicodeRuneValue), nil
+}
+
+//==============================================================================================================================
+//	 Range queries - The v0.6 RangeQueryState returns the keys of a range in no particular order and includes the
+//					end key. getStateRange reads the whole range and sorts it, so that callers see composite keys in
+//					the order of their attributes and may rely on an exclusive end key.
+//==============================================================================================================================
+type stateEntry struct {
+	key   string
+	value []byte
+}
+
+//==============================================================================================================================
+//	getStateRange - every key in [startKey, endKey) and its value, sorted by key
+//==============================================================================================================================
+func getStateRange(stub shim.ChaincodeStubInterface, startKey string, endKey string) ([]stateEntry, error) {
+	iter, err := stub.RangeQueryState(startKey, endKey)
+	if err != nil {
+		return nil, newError(E_INTERNAL, "Failed to range query ledger")
+	}
+	defer iter.Close()
+
+	entries := []stateEntry{}
+	for iter.HasNext() {
+		key, value, err := iter.Next()
+		if err != nil {
+			return nil, newError(E_INTERNAL, "Failed to read ledger during range query")
+		}
+		if key >= endKey {
+			continue
+		}
+		entries = append(entries, stateEntry{key: key, value: value})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	return entries, nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	 Package schema versions - Every package record is written with the version of its shape in SchemaVersion.
+//					Records read in an older shape are upgraded to the current one by decodePackageRecord, and
+//					rewritten in the current shape on their next write or by migrate.
+//						0 - start / finished shape: a single Temprature reading, no PkgId, PkgStatus or limits.
+//						    The reading is kept as LegacyTemprature and the limits are passed to migrate.
+//						1 - mycode shape: TempratureMin / TempratureMax in whole degrees Celsius
+//						2 - tempratures in tenths of a degree Celsius with TempUnit
+//					Records written before SchemaVersion existed carry no version, packageSchemaVersion infers it
+//					from their shape. Start shape records live under bare keys that no index lists, migrate only
+//					finds them when their keys are passed after MIGRATE_LEGACY_KEYS.
+//==============================================================================================================================
+const (
+	PKG_SCHEMA_V0 = 0
+	PKG_SCHEMA_V1 = 1
+	PKG_SCHEMA_V2 = 2
+
+	PKG_SCHEMA_VERSION = PKG_SCHEMA_V2
+)
+
+//==============================================================================================================================
+//	 Legacy keys - migrate reads the bare keys passed after this flag and the temprature range to give the start shape
+//					packages among them, e.g. migrate legacyKeys 2 8 1Z20170426 1Z20170427
+//==============================================================================================================================
+const MIGRATE_LEGACY_KEYS = "legacyKeys"
+
+//==============================================================================================================================
+//	legacyTempRange - The temprature limits given to start shape packages, which only recorded a single reading
+//==============================================================================================================================
+type legacyTempRange struct {
+	Min Temperature
+	Max Temperature
+}
+
+//==============================================================================================================================
+//	packageRecordShape - The fields of a stored package record that tell its schema version
+//==============================================================================================================================
+type packageRecordShape struct {
+	SchemaVersion int             `json:"schemaversion"`
+	TempUnit      string          `json:"tempunit"`
+	Temprature    *string         `json:"temprature"`
+	TempratureMin json.RawMessage `json:"Tempraturemin"`
+}
+
+//==============================================================================================================================
+//	packageSchemaVersion - the schema version a package record was written in
+//==============================================================================================================================
+func packageSchemaVersion(shape packageRecordShape) int {
+	if shape.SchemaVersion != 0 {
+		return shape.SchemaVersion
+	}
+	if shape.TempUnit != "" {
+		return PKG_SCHEMA_V2
+	}
+	if shape.Temprature != nil && shape.TempratureMin == nil {
+		return PKG_SCHEMA_V0
+	}
+	return PKG_SCHEMA_V1
+}
+
+//==============================================================================================================================
+//	decodePackageRecord - unmarshals a package record stored under the id pkgId and upgrades it to the current schema
+//				version. Reports whether the stored record needs rewriting: it is in an older shape or carries no
+//				schema version. Start shape records come back without limits, see moveLegacyPackage.
+//==============================================================================================================================
+func decodePackageRecord(pkgId string, pkginfoasbytes []byte, packageinfo *PackageInfo) (bool, error) {
+	var shape packageRecordShape
+	err := json.Unmarshal(pkginfoasbytes, &shape)
+	if err != nil {
+		return false, err
+	}
+
+	version := packageSchemaVersion(shape)
+	if version > PKG_SCHEMA_VERSION {
+		return false, fmt.Errorf("schema version %d is newer than %d", version, PKG_SCHEMA_VERSION)
+	}
+
+	err = json.Unmarshal(pkginfoasbytes, packageinfo)
+	if err != nil {
+		return false, err
+	}
+
+	switch version {
+	case PKG_SCHEMA_V0:
+		packageinfo.LegacyTemprature = *shape.Temprature
+		packageinfo.TempUnit = TEMP_UNIT_DECI_CELSIUS
+		if packageinfo.PkgStatus == "" {
+			packageinfo.PkgStatus = STATUS_LABEL_GENERATED
+		}
+	case PKG_SCHEMA_V1:
+		upgradePackageTemps(packageinfo)
+	}
+
+	if packageinfo.PkgId == "" {
+		packageinfo.PkgId = pkgId
+	}
+	packageinfo.SchemaVersion = PKG_SCHEMA_VERSION
+
+	return shape.SchemaVersion != PKG_SCHEMA_VERSION, nil
+}
+
+//==============================================================================================================================
+//	MigrationResult - Result of one migrate batch: the packages rewritten in the current schema version out of the
+//				Scanned packages of the batch. Bookmark is passed to the next migrate call while HasMore is true.
+//==============================================================================================================================
+type MigrationResult struct {
+	Migrated []string `json:"migrated"`
+	Scanned  int      `json:"scanned"`
+	Bookmark string   `json:"bookmark"`
+	HasMore  bool     `json:"hasMore"`
+}
+
+//==============================================================================================================================
+//	 Migration batches - migrate reads at most this many packages when no batch size is passed
+//==============================================================================================================================
+const migrateBatchSize = 100
+
+//=================================================================================================================================
+//	migrate - rewrites the packages stored in an older schema version in the current one, a batch at a time so that
+//				no transaction has to touch every package. Arguments are the optional BatchSize and the bookmark
+//				returned by the previous batch. Running it again after the last batch rewrites nothing.
+//				migrate MIGRATE_LEGACY_KEYS TempratureMin TempratureMax Key... instead moves the packages stored under the
+//				given bare keys, such as the start shape packages, to their composite keys. Start shape packages are
+//				given the temprature range passed, other packages keep their own.
+//=================================================================================================================================
+func (t *SimpleChaincode) migrate(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running migrate()")
+
+	if len(args) > 0 && args[0] == MIGRATE_LEGACY_KEYS {
+		return migrateLegacyKeys(stub, args[1:])
+	}
+
+	if len(args) > 2 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting optional BatchSize and Bookmark")
+	}
+
+	opts := ListOptions{PageSize: migrateBatchSize, Paged: true}
+	if len(args) > 0 {
+		batchSize, err := strconv.Atoi(args[0])
+		if err != nil || batchSize <= 0 {
+			return nil, newError(E_ARGS, "BatchSize must be a positive numeric string")
+		}
+		opts.PageSize = batchSize
+	}
+	if len(args) > 1 {
+		opts.Bookmark = args[1]
+	}
+
+	startKey, endKey, err := compositeKeyRange(packageObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	// collect first so that the package writes do not interleave with the range scan
+	var packages []PackageInfo
+	var outdated []bool
+	items, bookmark, hasMore, err := scanRange(stub, startKey, endKey, opts, func(key string, pkginfoasbytes []byte) (json.RawMessage, error) {
+		_, attributes, err := splitCompositeKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		var pkginfo PackageInfo
+		rewrite, err := decodePackageRecord(attributes[0], pkginfoasbytes, &pkginfo)
+		if err != nil {
+			fmt.Println("Could not unmarshal package info object", err)
+			return nil, newError(E_DECODE, "Could not unmarshal package info object "+attributes[0])
+		}
+		packages = append(packages, pkginfo)
+		outdated = append(outdated, rewrite)
+		return json.Marshal(pkginfo.PkgId)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// scanRange reads one package past a full batch to tell whether more follow, it is not part of this batch
+	result := MigrationResult{Migrated: []string{}, Scanned: len(items), Bookmark: bookmark, HasMore: hasMore}
+	var migrated []PackageInfo
+	for i := range items {
+		if !outdated[i] {
+			continue
+		}
+
+		err = putPackageInfo(stub, &packages[i])
+		if err != nil {
+			return nil, err
+		}
+		result.Migrated = append(result.Migrated, packages[i].PkgId)
+		migrated = append(migrated, packages[i])
+	}
+
+	err = emitMigratedEvents(stub, migrated)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(result)
+}
+
+//==============================================================================================================================
+//	migrateLegacyKeys - moves the packages stored under the bare keys following the temprature range in args to their
+//				composite keys. Keys holding nothing are skipped.
+//==============================================================================================================================
+func migrateLegacyKeys(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 3 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting TempratureMin, TempratureMax and at least one legacy key after "+MIGRATE_LEGACY_KEYS)
+	}
+
+	var tempRange legacyTempRange
+	var err error
+	tempRange.Min, err = parseTemperature(args[0])
+	if err != nil {
+		return nil, err
+	}
+	tempRange.Max, err = parseTemperature(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if tempRange.Min > tempRange.Max {
+		return nil, newError(E_ARGS, "TempratureMin must not be above TempratureMax")
+	}
+
+	keys := args[2:]
+
+	result := MigrationResult{Migrated: []string{}, Scanned: len(keys)}
+	var migrated []PackageInfo
+	for _, key := range keys {
+		packageinfo, err := moveLegacyPackage(stub, key, &tempRange)
+		if err != nil {
+			return nil, err
+		}
+		if packageinfo != nil {
+			result.Migrated = append(result.Migrated, key)
+			migrated = append(migrated, *packageinfo)
+		}
+	}
+
+	err = emitMigratedEvents(stub, migrated)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(result)
+}
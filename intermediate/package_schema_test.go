@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodePackageRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  string
+		version int
+		rewrite bool
+		want    PackageInfo
+		fails   bool
+	}{
+		{"start shape", `{"shipper": "UPS", "consignee": "RAHUL", "temprature": "2", "packagedes": "ANTIBIOTICS"}`, PKG_SCHEMA_V0, true,
+			PackageInfo{PkgId: "S1", Shipper: "UPS", Consignee: "RAHUL", LegacyTemprature: "2", PackageDes: "ANTIBIOTICS", PkgStatus: STATUS_LABEL_GENERATED}, false},
+		{"start shape in transit", `{"shipper": "UPS", "temprature": "-20", "pkgstatus": "In_Transit"}`, PKG_SCHEMA_V0, true,
+			PackageInfo{PkgId: "S1", Shipper: "UPS", LegacyTemprature: "-20", PkgStatus: STATUS_IN_TRANSIT}, false},
+		{"mycode shape", `{"packageid": "S1", "provider": "PRV", "Tempraturemin": -2, "Tempraturemax": 8, "pkgstatus": "In_Transit"}`, PKG_SCHEMA_V1, true,
+			PackageInfo{PkgId: "S1", Provider: "PRV", TempratureMin: -20, TempratureMax: 80, PkgStatus: STATUS_IN_TRANSIT}, false},
+		{"tenths without version", `{"packageid": "S1", "Tempraturemin": 25, "Tempraturemax": 80, "tempunit": "0.1C", "pkgstatus": "Pkg_Damaged"}`, PKG_SCHEMA_V2, true,
+			PackageInfo{PkgId: "S1", TempratureMin: 25, TempratureMax: 80, PkgStatus: STATUS_PKG_DAMAGED}, false},
+		{"current version", `{"packageid": "S1", "Tempraturemin": 25, "Tempraturemax": 80, "tempunit": "0.1C", "pkgstatus": "In_Transit", "schemaversion": 2}`, PKG_SCHEMA_V2, false,
+			PackageInfo{PkgId: "S1", TempratureMin: 25, TempratureMax: 80, PkgStatus: STATUS_IN_TRANSIT}, false},
+		{"newer version", `{"packageid": "S1", "schemaversion": 99}`, 99, false, PackageInfo{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var shape packageRecordShape
+			if err := json.Unmarshal([]byte(test.record), &shape); err != nil {
+				t.Fatal(err)
+			}
+			if version := packageSchemaVersion(shape); version != test.version {
+				t.Fatalf("version %d, want %d", version, test.version)
+			}
+
+			var packageinfo PackageInfo
+			rewrite, err := decodePackageRecord("S1", []byte(test.record), &packageinfo)
+			if (err != nil) != test.fails || rewrite != test.rewrite {
+				t.Fatalf("rewrite %v, error %v", rewrite, err)
+			}
+			if test.fails {
+				return
+			}
+
+			want := test.want
+			want.TempUnit = TEMP_UNIT_DECI_CELSIUS
+			want.SchemaVersion = PKG_SCHEMA_VERSION
+			got, _ := json.Marshal(packageinfo)
+			expected, _ := json.Marshal(want)
+			if string(got) != string(expected) {
+				t.Fatalf("decoded %s, want %s", got, expected)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	stub := newTestStub(t, createP1)
+
+	// packages left on the ledger in the shapes of earlier chaincode versions, the start shape under its bare key
+	stub.State["M0"] = []byte(`{"shipper": "SHP", "consignee": "CON", "temprature": "4", "packagedes": "insulin"}`)
+	for pkgId, record := range map[string]string{
+		"M1": `{"packageid": "M1", "shipper": "SHP", "provider": "PRV", "Tempraturemin": 2, "Tempraturemax": 8, "pkgstatus": "In_Transit"}`,
+		"M2": `{"packageid": "M2", "shipper": "SHP", "provider": "PRV", "Tempraturemin": 20, "Tempraturemax": 80, "tempunit": "0.1C", "pkgstatus": "Label_Generated"}`,
+	} {
+		key, _ := pkgKey(pkgId)
+		stub.State[key] = []byte(record)
+	}
+
+	migrate := func(args ...string) MigrationResult {
+		t.Helper()
+		var result MigrationResult
+		if err := json.Unmarshal(mustInvoke(t, stub, "migrate", args...), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// 1Z20170426 and M1 form the first batch, M2 and P1 the second. The sample package was written before
+	// packages carried a schema version.
+	key, _ := pkgKey("1Z20170426")
+	stub.State[key] = []byte(`{"packageid": "1Z20170426", "Tempraturemin": -200, "Tempraturemax": -100, "tempunit": "0.1C", "pkgstatus": "Label_Generated"}`)
+	first := migrate("2")
+	if !equalStrings(first.Migrated, []string{"1Z20170426", "M1"}) || first.Scanned != 2 || !first.HasMore {
+		t.Fatalf("first batch %+v", first)
+	}
+	if got := eventPkgIds(t, stub, EVENT_PKG_MIGRATED); !equalStrings(got, first.Migrated) {
+		t.Fatalf("events for %v", got)
+	}
+	second := migrate("2", first.Bookmark)
+	if !equalStrings(second.Migrated, []string{"M2"}) || second.Scanned != 2 || second.HasMore {
+		t.Fatalf("second batch %+v", second)
+	}
+
+	// the start shape package is only moved once its key is named
+	if _, err := stub.MockQuery("querypkgbyid", "M0"); errorCode(err) != E_NOT_FOUND {
+		t.Fatalf("legacy package found before its migration: %v", err)
+	}
+	legacy := migrate(MIGRATE_LEGACY_KEYS, "2", "8", "M0", "GONE")
+	if !equalStrings(legacy.Migrated, []string{"M0"}) || legacy.Scanned != 2 || stub.State["M0"] != nil {
+		t.Fatalf("legacy keys %+v", legacy)
+	}
+	if got := eventPkgIds(t, stub, EVENT_PKG_MIGRATED); !equalStrings(got, []string{"M0"}) {
+		t.Fatalf("events for %v", got)
+	}
+
+	for _, pkgId := range []string{"M0", "M1", "M2", "P1"} {
+		key, _ := pkgKey(pkgId)
+		var shape packageRecordShape
+		if err := json.Unmarshal(stub.State[key], &shape); err != nil || shape.SchemaVersion != PKG_SCHEMA_VERSION {
+			t.Fatalf("%s stored as %s", pkgId, stub.State[key])
+		}
+	}
+	if packageinfo := getPkg(t, stub, "M0"); packageinfo.PkgId != "M0" || packageinfo.TempratureMin != 20 || packageinfo.TempratureMax != 80 || packageinfo.LegacyTemprature != "4" || packageinfo.PkgStatus != STATUS_LABEL_GENERATED {
+		t.Fatalf("unexpected migrated package %+v", packageinfo)
+	}
+	if packageinfo := getPkg(t, stub, "M1"); packageinfo.TempratureMin != 20 || packageinfo.TempratureMax != 80 {
+		t.Fatalf("unexpected migrated package %+v", packageinfo)
+	}
+
+	if again := migrate(); len(again.Migrated) != 0 || again.Scanned != 5 || again.HasMore {
+		t.Fatalf("second run %+v", again)
+	}
+
+	for _, args := range [][]string{{"0"}, {"many"}, {"3", "not a bookmark!"}, {"3", "", "x"}, {MIGRATE_LEGACY_KEYS}, {MIGRATE_LEGACY_KEYS, "2", "8"}, {MIGRATE_LEGACY_KEYS, "8", "2", "M9"}, {MIGRATE_LEGACY_KEYS, "cold", "8", "M9"}} {
+		_, err := stub.MockInvoke("migrate", args...)
+		checkCode(t, err, E_ARGS)
+	}
+
+	// a legacy record never replaces a package stored under its composite key
+	stub.State["P1"] = []byte(`{"shipper": "SHP", "temprature": "4"}`)
+	_, err := stub.MockInvoke("migrate", MIGRATE_LEGACY_KEYS, "2", "8", "P1")
+	checkCode(t, err, E_DUPLICATE)
+}
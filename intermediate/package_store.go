@@ -0,0 +1,536 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	 Package storage - Every package is stored under the composite key Package~PkgId. All packages share the
+//					Package prefix, so list queries enumerate them with a range scan instead of reading a
+//					single index blob, and creating a package only writes its own key.
+//==============================================================================================================================
+const packageObjectType = "Package"
+
+//==============================================================================================================================
+//	 Legacy index - Before packages were range scanned, their ids were kept in a PKG_Holder stored under this key.
+//					migratepkgindex reads it once and moves every package it lists under its composite key.
+//==============================================================================================================================
+const legacyPkgIdsKey = "PkgIdsKey"
+
+//==============================================================================================================================
+//	pkgKey - ledger key of a package
+//==============================================================================================================================
+func pkgKey(pkgId string) (string, error) {
+	return createCompositeKey(packageObjectType, []string{pkgId})
+}
+
+//==============================================================================================================================
+//	getPackageInfo - reads and unmarshals the package with the given id, failing if no such package exists or it was
+//				archived
+//==============================================================================================================================
+func getPackageInfo(stub shim.ChaincodeStubInterface, pkgId string) (PackageInfo, error) {
+	var packageinfo PackageInfo
+
+	key, err := pkgKey(pkgId)
+	if err != nil {
+		return packageinfo, err
+	}
+
+	valAsbytes, err := stub.GetState(key)
+	if err != nil {
+		return packageinfo, newError(E_INTERNAL, "Failed to get state for "+pkgId)
+	}
+
+	if valAsbytes == nil {
+		archived, err := getArchivedPackage(stub, pkgId)
+		if err != nil {
+			return packageinfo, err
+		}
+		if archived != nil {
+			return packageinfo, newError(E_ILLEGAL_STATE, "Package "+pkgId+" is archived")
+		}
+		return packageinfo, newError(E_NOT_FOUND, "Invalid PackageId Passed "+pkgId)
+	}
+
+	err = decodePackageInfo(pkgId, valAsbytes, &packageinfo)
+	if err != nil {
+		fmt.Println("Could not unmarshal package info object", err)
+		return packageinfo, newError(E_DECODE, "Could not unmarshal package info object "+pkgId)
+	}
+
+	if packageinfo.PkgId != pkgId {
+		return packageinfo, newError(E_NOT_FOUND, "Invalid PackageId Passed "+pkgId)
+	}
+
+	return packageinfo, nil
+}
+
+//==============================================================================================================================
+//	decodePackageInfo - unmarshals the package record stored under the id pkgId, upgrading records written in an
+//				older schema version
+//==============================================================================================================================
+func decodePackageInfo(pkgId string, pkginfoasbytes []byte, packageinfo *PackageInfo) error {
+	_, err := decodePackageRecord(pkgId, pkginfoasbytes, packageinfo)
+	return err
+}
+
+//==============================================================================================================================
+//	 Secondary indexes - Alongside every package the chaincode keeps index entries so that role and status queries
+//					only range scan the matching packages:
+//						PkgRole~Role~Party~PkgId
+//						PkgStatus~Status~PkgId
+//						PkgRoleStatus~Role~Party~Status~PkgId
+//					Index entries carry no data; the PkgId is the last attribute of the key. The Provider entries
+//					follow the current custodian of the package.
+//==============================================================================================================================
+const pkgRoleIndex = "PkgRole"
+const pkgStatusIndex = "PkgStatus"
+const pkgRoleStatusIndex = "PkgRoleStatus"
+
+var pkgRoles = []string{ROLE_SHIPPER, ROLE_PROVIDER, ROLE_INSURER, ROLE_CONSIGNEE}
+
+var pkgIndexValue = []byte{0x00}
+
+//==============================================================================================================================
+//	pkgIndexKeys - every secondary index key a package should have for its current parties and status
+//==============================================================================================================================
+func pkgIndexKeys(packageinfo *PackageInfo) ([]string, error) {
+	var keys []string
+
+	key, err := createCompositeKey(pkgStatusIndex, []string{packageinfo.PkgStatus, packageinfo.PkgId})
+	if err != nil {
+		return nil, err
+	}
+	keys = append(keys, key)
+
+	for _, role := range pkgRoles {
+		party := pkgPartyForRole(*packageinfo, role)
+
+		key, err = createCompositeKey(pkgRoleIndex, []string{role, party, packageinfo.PkgId})
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+
+		key, err = createCompositeKey(pkgRoleStatusIndex, []string{role, party, packageinfo.PkgStatus, packageinfo.PkgId})
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+//==============================================================================================================================
+//	putPackageInfo - marshals the package and writes it under its composite key. Index entries of the version
+//				currently on the ledger that no longer apply are deleted and the new ones written in the same
+//				transaction, so the indexes always match the stored package. Packages are always written in the
+//				current schema version, with tempratures in TEMP_UNIT_DECI_CELSIUS. StatusChangedAt is set when
+//				a package is created or its status differs from the stored one.
+//==============================================================================================================================
+func putPackageInfo(stub shim.ChaincodeStubInterface, packageinfo *PackageInfo) error {
+	packageinfo.TempUnit = TEMP_UNIT_DECI_CELSIUS
+	packageinfo.SchemaVersion = PKG_SCHEMA_VERSION
+
+	key, err := pkgKey(packageinfo.PkgId)
+	if err != nil {
+		return err
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return err
+	}
+
+	var oldKeys []string
+	oldasbytes, err := stub.GetState(key)
+	if err != nil {
+		return newError(E_INTERNAL, "Failed to get state for "+packageinfo.PkgId)
+	}
+	if oldasbytes == nil {
+		packageinfo.StatusChangedAt = timestamp
+	} else {
+		var oldinfo PackageInfo
+		err = decodePackageInfo(packageinfo.PkgId, oldasbytes, &oldinfo)
+		if err != nil {
+			fmt.Println("Could not unmarshal package info object", err)
+			return newError(E_DECODE, "Could not unmarshal package info object "+packageinfo.PkgId)
+		}
+		oldKeys, err = pkgIndexKeys(&oldinfo)
+		if err != nil {
+			return err
+		}
+		if oldinfo.PkgStatus != packageinfo.PkgStatus {
+			packageinfo.StatusChangedAt = timestamp
+		}
+	}
+
+	newKeys, err := pkgIndexKeys(packageinfo)
+	if err != nil {
+		return err
+	}
+
+	err = updatePkgIndexes(stub, oldKeys, newKeys)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(packageinfo)
+	if err != nil {
+		fmt.Println("Could not marshal package info object", err)
+		return newError(E_INTERNAL, "Could not marshal package info object "+packageinfo.PkgId)
+	}
+
+	err = stub.PutState(key, bytes)
+	if err != nil {
+		return newError(E_INTERNAL, "Failed writing to blockchain for Package "+packageinfo.PkgId)
+	}
+
+	return nil
+}
+
+//==============================================================================================================================
+//	updatePkgIndexes - deletes the index keys only in oldKeys and writes the ones only in newKeys
+//==============================================================================================================================
+func updatePkgIndexes(stub shim.ChaincodeStubInterface, oldKeys []string, newKeys []string) error {
+	for _, key := range oldKeys {
+		if !containsString(newKeys, key) {
+			err := stub.DelState(key)
+			if err != nil {
+				return newError(E_INTERNAL, "Failed to delete package index entry")
+			}
+		}
+	}
+
+	for _, key := range newKeys {
+		if !containsString(oldKeys, key) {
+			err := stub.PutState(key, pkgIndexValue)
+			if err != nil {
+				return newError(E_INTERNAL, "Failed writing to blockchain for package index entry")
+			}
+		}
+	}
+
+	return nil
+}
+
+//==============================================================================================================================
+//	packageExists - true if a package with the given id is already on the ledger, active or archived. The id of an
+//				archived package is never reused.
+//==============================================================================================================================
+func packageExists(stub shim.ChaincodeStubInterface, pkgId string) (bool, error) {
+	key, err := pkgKey(pkgId)
+	if err != nil {
+		return false, err
+	}
+
+	valAsbytes, err := stub.GetState(key)
+	if err != nil {
+		return false, newError(E_INTERNAL, "Failed to get state for "+pkgId)
+	}
+	if valAsbytes != nil {
+		return true, nil
+	}
+
+	archived, err := getArchivedPackage(stub, pkgId)
+	if err != nil {
+		return false, err
+	}
+	return archived != nil, nil
+}
+
+//==============================================================================================================================
+//	scanPackages - range scans every package, and the archived ones if opts asks for them, and returns the packages
+//				accepted by match as a list query result
+//==============================================================================================================================
+func scanPackages(stub shim.ChaincodeStubInterface, opts ListOptions, match func(packageinfo PackageInfo) bool) ([]byte, error) {
+	startKey, endKey, err := compositeKeyRange(packageObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	collect := func(key string, pkginfoasbytes []byte) (json.RawMessage, error) {
+		_, attributes, err := splitCompositeKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		var pkginfo PackageInfo
+		err = decodePackageInfo(attributes[0], pkginfoasbytes, &pkginfo)
+		if err != nil {
+			fmt.Println("Could not unmarshal package info object", err)
+			return nil, newError(E_DECODE, "Could not unmarshal package info object")
+		}
+
+		if !match(pkginfo) {
+			return nil, nil
+		}
+		return json.Marshal(pkginfo)
+	}
+
+	// archived packages are stored in the same shape, only under another object type
+	sources, err := withArchivedPackages(opts, []scanSource{{startKey, endKey, collect}}, collect)
+	if err != nil {
+		return nil, err
+	}
+
+	items, bookmark, hasMore, err := scanRanges(stub, sources, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return listResult(opts, items, bookmark, hasMore)
+}
+
+//==============================================================================================================================
+//	scanPackageIndex - range scans the secondary index objectType for the given leading attributes and returns the
+//				packages the matching entries point at as a list query result. Archived packages have no index
+//				entries; if opts asks for them every archived package is read and returned if one of the index
+//				entries it had would match.
+//==============================================================================================================================
+func scanPackageIndex(stub shim.ChaincodeStubInterface, objectType string, attributes []string, opts ListOptions) ([]byte, error) {
+	startKey, endKey, err := compositeKeyRange(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	collect := func(indexKey string, value []byte) (json.RawMessage, error) {
+		_, keyAttributes, err := splitCompositeKey(indexKey)
+		if err != nil {
+			return nil, err
+		}
+		pkgId := keyAttributes[len(keyAttributes)-1]
+
+		key, err := pkgKey(pkgId)
+		if err != nil {
+			return nil, err
+		}
+
+		pkginfoasbytes, err := stub.GetState(key)
+		if err != nil {
+			return nil, newError(E_INTERNAL, "Failed to get state for "+pkgId)
+		}
+		if pkginfoasbytes == nil {
+			fmt.Println("Skipping dangling index entry for", pkgId)
+			return nil, nil
+		}
+
+		var pkginfo PackageInfo
+		err = decodePackageInfo(pkgId, pkginfoasbytes, &pkginfo)
+		if err != nil {
+			fmt.Println("Could not unmarshal package info object", err)
+			return nil, newError(E_DECODE, "Could not unmarshal package info object "+pkgId)
+		}
+		return json.Marshal(pkginfo)
+	}
+
+	collectArchived := func(key string, pkginfoasbytes []byte) (json.RawMessage, error) {
+		_, keyAttributes, err := splitCompositeKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		var pkginfo PackageInfo
+		err = decodePackageInfo(keyAttributes[0], pkginfoasbytes, &pkginfo)
+		if err != nil {
+			fmt.Println("Could not unmarshal package info object", err)
+			return nil, newError(E_DECODE, "Could not unmarshal package info object "+keyAttributes[0])
+		}
+
+		indexKeys, err := pkgIndexKeys(&pkginfo)
+		if err != nil {
+			return nil, err
+		}
+		for _, indexKey := range indexKeys {
+			if indexKey >= startKey && indexKey < endKey {
+				return json.Marshal(pkginfo)
+			}
+		}
+		return nil, nil
+	}
+
+	sources, err := withArchivedPackages(opts, []scanSource{{startKey, endKey, collect}}, collectArchived)
+	if err != nil {
+		return nil, err
+	}
+
+	items, bookmark, hasMore, err := scanRanges(stub, sources, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return listResult(opts, items, bookmark, hasMore)
+}
+
+//=================================================================================================================================
+//	rebuildpkgindex - rewrites the secondary index entries of every package. Needed once for packages that were
+//				stored before the indexes existed; safe to run again at any time.
+//=================================================================================================================================
+func (t *SimpleChaincode) rebuildpkgindex(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running rebuildpkgindex()")
+
+	if len(args) != 0 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting 0")
+	}
+
+	startKey, endKey, err := compositeKeyRange(packageObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := getStateRange(stub, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect first so that the index writes do not interleave with reading the range
+	var packages []PackageInfo
+	for _, entry := range entries {
+		_, attributes, err := splitCompositeKey(entry.key)
+		if err != nil {
+			return nil, err
+		}
+
+		var pkginfo PackageInfo
+		err = decodePackageInfo(attributes[0], entry.value, &pkginfo)
+		if err != nil {
+			fmt.Println("Could not unmarshal package info object", err)
+			return nil, newError(E_DECODE, "Could not unmarshal package info object")
+		}
+		packages = append(packages, pkginfo)
+	}
+
+	reindexed := []string{}
+	for i := range packages {
+		keys, err := pkgIndexKeys(&packages[i])
+		if err != nil {
+			return nil, err
+		}
+
+		err = updatePkgIndexes(stub, nil, keys)
+		if err != nil {
+			return nil, err
+		}
+		reindexed = append(reindexed, packages[i].PkgId)
+	}
+
+	// the reindexed packages are announced as well, which lets off-chain read models resync from the ledger
+	err = emitMigratedEvents(stub, packages)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(PKG_Holder{PkgIds: reindexed})
+}
+
+//=================================================================================================================================
+//	migratepkgindex - one off migration from the legacy PKG_Holder index. Every package listed under PkgIdsKey is
+//				moved from its bare PkgId key to its composite key and the legacy index is deleted. Running it
+//				again once the index is gone does nothing.
+//=================================================================================================================================
+func (t *SimpleChaincode) migratepkgindex(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running migratepkgindex()")
+
+	if len(args) != 0 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting 0")
+	}
+
+	valAsbytes, err := stub.GetState(legacyPkgIdsKey)
+	if err != nil {
+		return nil, newError(E_INTERNAL, "Failed to get state for "+legacyPkgIdsKey)
+	}
+
+	var package_holder PKG_Holder
+	if valAsbytes != nil {
+		err = json.Unmarshal(valAsbytes, &package_holder)
+		if err != nil {
+			fmt.Println("Could not unmarshal pkgid array object", err)
+			return nil, newError(E_DECODE, "Could not unmarshal "+legacyPkgIdsKey)
+		}
+	}
+
+	migrated := []string{}
+	var packages []PackageInfo
+	for _, pkgId := range package_holder.PkgIds {
+		packageinfo, err := moveLegacyPackage(stub, pkgId, nil)
+		if err != nil {
+			return nil, err
+		}
+		if packageinfo != nil {
+			migrated = append(migrated, pkgId)
+			packages = append(packages, *packageinfo)
+		}
+	}
+
+	if valAsbytes != nil {
+		err = stub.DelState(legacyPkgIdsKey)
+		if err != nil {
+			return nil, newError(E_INTERNAL, "Failed to delete "+legacyPkgIdsKey)
+		}
+	}
+
+	err = emitMigratedEvents(stub, packages)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(PKG_Holder{PkgIds: migrated})
+}
+
+//==============================================================================================================================
+//	moveLegacyPackage - moves the package stored under the bare key pkgId to its composite key, upgrading it to the
+//				current schema version. A start shape package is given tempRange, it can not be moved without one.
+//				Returns the moved package, nil if nothing is stored under pkgId.
+//==============================================================================================================================
+func moveLegacyPackage(stub shim.ChaincodeStubInterface, pkgId string, tempRange *legacyTempRange) (*PackageInfo, error) {
+	pkginfoasbytes, err := stub.GetState(pkgId)
+	if err != nil {
+		return nil, newError(E_INTERNAL, "Failed to get state for "+pkgId)
+	}
+	if pkginfoasbytes == nil {
+		return nil, nil
+	}
+
+	exists, err := packageExists(stub, pkgId)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, newError(E_DUPLICATE, "Package "+pkgId+" already exists, can not move the legacy record stored under the same key")
+	}
+
+	var shape packageRecordShape
+	var packageinfo PackageInfo
+	err = json.Unmarshal(pkginfoasbytes, &shape)
+	if err == nil {
+		err = decodePackageInfo(pkgId, pkginfoasbytes, &packageinfo)
+	}
+	if err != nil {
+		fmt.Println("Could not unmarshal package info object", err)
+		return nil, newError(E_DECODE, "Could not unmarshal package info object "+pkgId)
+	}
+	packageinfo.PkgId = pkgId
+
+	if packageSchemaVersion(shape) == PKG_SCHEMA_V0 {
+		if tempRange == nil {
+			return nil, newError(E_ARGS, "Package "+pkgId+" has no temprature range, move it with migrate "+MIGRATE_LEGACY_KEYS+" and a range")
+		}
+		packageinfo.TempratureMin = tempRange.Min
+		packageinfo.TempratureMax = tempRange.Max
+	}
+
+	err = putPackageInfo(stub, &packageinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = stub.DelState(pkgId)
+	if err != nil {
+		return nil, newError(E_INTERNAL, "Failed to delete legacy key "+pkgId)
+	}
+	return &packageinfo, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestMigratepkgindex(t *testing.T) {
+	stub := newTestStub(t)
+
+	// packages stored under their bare id and listed in the legacy PKG_Holder, with whole degree tempratures
+	stub.State[legacyPkgIdsKey] = []byte(`{"packageids": ["L1", "L2", "GONE"]}`)
+	stub.State["L1"] = []byte(`{"packageid": "L1", "shipper": "SHP", "provider": "PRV", "Tempraturemin": 2, "Tempraturemax": 8, "pkgstatus": "In_Transit"}`)
+	stub.State["L2"] = []byte(`{"shipper": "SHP", "provider": "PRV", "Tempraturemin": -20, "Tempraturemax": -10, "pkgstatus": "Label_Generated"}`)
+
+	var holder PKG_Holder
+	if err := json.Unmarshal(mustInvoke(t, stub, "migratepkgindex"), &holder); err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(holder.PkgIds, []string{"L1", "L2"}) {
+		t.Fatalf("migrated %v", holder.PkgIds)
+	}
+	if got := eventPkgIds(t, stub, EVENT_PKG_MIGRATED); !equalStrings(got, holder.PkgIds) {
+		t.Fatalf("events for %v", got)
+	}
+	for _, key := range []string{legacyPkgIdsKey, "L1", "L2"} {
+		if _, ok := stub.State[key]; ok {
+			t.Fatalf("legacy key %s was not deleted", key)
+		}
+	}
+
+	if packageinfo := getPkg(t, stub, "L2"); packageinfo.PkgId != "L2" || packageinfo.TempratureMin != -200 || packageinfo.TempUnit != TEMP_UNIT_DECI_CELSIUS {
+		t.Fatalf("unexpected migrated package %+v", packageinfo)
+	}
+	if got := pkgIds(t, mustQuery(t, stub, "querybyrole_status", ROLE_PROVIDER, "PRV", STATUS_IN_TRANSIT)); !equalStrings(got, []string{"L1"}) {
+		t.Fatalf("migrated packages are not indexed: %v", got)
+	}
+
+	// a second run finds nothing left to migrate
+	if err := json.Unmarshal(mustInvoke(t, stub, "migratepkgindex"), &holder); err != nil || len(holder.PkgIds) != 0 {
+		t.Fatalf("second run migrated %v, %v", holder.PkgIds, err)
+	}
+	_, err := stub.MockInvoke("migratepkgindex", "now")
+	checkCode(t, err, E_ARGS)
+
+	// a start shape package has no range to keep, it is left for migrate legacyKeys
+	stub.State[legacyPkgIdsKey] = []byte(`{"packageids": ["L3"]}`)
+	stub.State["L3"] = []byte(`{"shipper": "SHP", "temprature": "4"}`)
+	_, err = stub.MockInvoke("migratepkgindex")
+	checkCode(t, err, E_ARGS)
+	if stub.State["L3"] == nil {
+		t.Fatal("start shape package was moved without a range")
+	}
+}
+
+func TestRebuildpkgindex(t *testing.T) {
+	stub := newTestStub(t, createP1)
+
+	// a package written without its index entries
+	key, _ := pkgKey("R1")
+	stub.State[key] = []byte(`{"packageid": "R1", "shipper": "SHP", "provider": "PRV", "Tempraturemin": 20, "Tempraturemax": 80, "tempunit": "0.1C", "pkgstatus": "Label_Generated"}`)
+	if got := pkgIds(t, mustQuery(t, stub, "querypkgbyshipper", "SHP")); !equalStrings(got, []string{"P1"}) {
+		t.Fatalf("querypkgbyshipper before rebuild = %v", got)
+	}
+
+	var holder PKG_Holder
+	if err := json.Unmarshal(mustInvoke(t, stub, "rebuildpkgindex"), &holder); err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(holder.PkgIds, []string{"1Z20170426", "P1", "R1"}) {
+		t.Fatalf("reindexed %v", holder.PkgIds)
+	}
+	if got := eventPkgIds(t, stub, EVENT_PKG_MIGRATED); !equalStrings(got, holder.PkgIds) {
+		t.Fatalf("events for %v", got)
+	}
+	if got := pkgIds(t, mustQuery(t, stub, "querypkgbyshipper", "SHP")); !equalStrings(got, []string{"P1", "R1"}) {
+		t.Fatalf("querypkgbyshipper after rebuild = %v", got)
+	}
+
+	// moving the package keeps exactly one status index entry
+	mustInvoke(t, stub, "acceptpkg", "R1", "PRV")
+	if got := pkgIds(t, mustQuery(t, stub, "querybypkgstatus", STATUS_LABEL_GENERATED)); !equalStrings(got, []string{"1Z20170426", "P1"}) {
+		t.Fatalf("querybypkgstatus = %v", got)
+	}
+}
+
+func TestCorruptPackage(t *testing.T) {
+	stub := newTestStub(t)
+	key, _ := pkgKey("BAD")
+	stub.State[key] = []byte(`{"packageid": `)
+
+	_, err := stub.MockQuery("querypkgbyid", "BAD")
+	checkCode(t, err, E_DECODE)
+	_, err = stub.MockQuery("queryallpkg")
+	checkCode(t, err, E_DECODE)
+}
+
+func TestListOrder(t *testing.T) {
+	// the v0.6 shim, like the mock stub, returns a range in random order; lists and pages follow the key order
+	var setup [][]string
+	want := []string{"1Z20170426"}
+	for i := 1; i <= 12; i++ {
+		pkgId := fmt.Sprintf("O%02d", i)
+		setup = append(setup, []string{"create", pkgId, "SHP", "INS", "CON", "2", "8", "vaccine", "PRV"})
+		want = append(want, pkgId)
+	}
+	stub := newTestStub(t, setup...)
+
+	listed := func(result []byte) []string {
+		var packages []PackageInfo
+		if err := json.Unmarshal(result, &packages); err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, packageinfo := range packages {
+			ids = append(ids, packageinfo.PkgId)
+		}
+		return ids
+	}
+
+	if got := listed(mustQuery(t, stub, "queryallpkg")); !equalStrings(got, want) {
+		t.Fatalf("queryallpkg = %v, want %v", got, want)
+	}
+	if got := listed(mustQuery(t, stub, "querypkgbyshipper", "SHP")); !equalStrings(got, want[1:]) {
+		t.Fatalf("querypkgbyshipper = %v, want %v", got, want[1:])
+	}
+
+	var paged []string
+	bookmark := ""
+	for pages := 0; ; pages++ {
+		var page ListPage
+		if err := json.Unmarshal(mustQuery(t, stub, "queryallpkg", "5", bookmark), &page); err != nil {
+			t.Fatal(err)
+		}
+		items, _ := json.Marshal(page.Items)
+		paged = append(paged, listed(items)...)
+		if !page.HasMore {
+			if pages != 2 {
+				t.Fatalf("%d pages, want 3", pages+1)
+			}
+			break
+		}
+		bookmark = page.Bookmark
+	}
+	if !equalStrings(paged, want) {
+		t.Fatalf("paged = %v, want %v", paged, want)
+	}
+}
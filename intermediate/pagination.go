@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	ListOptions - Optional trailing arguments accepted by every list query: a page size and the bookmark returned
+//				with the previous page. Without them a list query returns every match as a plain JSON array.
+//				Package list queries also accept LIST_INCLUDE_ARCHIVED ahead of them, see parsePkgListOptions.
+//==============================================================================================================================
+type ListOptions struct {
+	PageSize        int
+	Bookmark        string
+	Paged           bool
+	IncludeArchived bool
+}
+
+//==============================================================================================================================
+//	ListPage - Result of a list query called with a page size. Bookmark is opaque to clients and is passed back
+//				unchanged to fetch the next page while HasMore is true.
+//==============================================================================================================================
+type ListPage struct {
+	Items    []json.RawMessage `json:"items"`
+	Bookmark string            `json:"bookmark"`
+	HasMore  bool              `json:"hasMore"`
+}
+
+//==============================================================================================================================
+//	parseListOptions - parses the optional [PageSize [, Bookmark]] arguments that follow a list query's own arguments
+//==============================================================================================================================
+func parseListOptions(args []string) (ListOptions, error) {
+	var opts ListOptions
+
+	if len(args) > 2 {
+		return opts, newError(E_ARGS, "Incorrect number of arguments. Optional paging arguments are PageSize and Bookmark")
+	}
+
+	if len(args) > 0 {
+		pageSize, err := strconv.Atoi(args[0])
+		if err != nil || pageSize <= 0 {
+			return opts, newError(E_ARGS, "PageSize must be a positive numeric string")
+		}
+		opts.PageSize = pageSize
+		opts.Paged = true
+	}
+
+	if len(args) > 1 {
+		opts.Bookmark = args[1]
+	}
+
+	return opts, nil
+}
+
+//==============================================================================================================================
+//	encodeBookmark / decodeBookmark - a bookmark is the ledger key of the last item of a page
+//==============================================================================================================================
+func encodeBookmark(key string) string {
+	return base64.URLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeBookmark(bookmark string) (string, error) {
+	key, err := base64.URLEncoding.DecodeString(bookmark)
+	if err != nil {
+		return "", newError(E_ARGS, "Invalid bookmark passed")
+	}
+	return string(key), nil
+}
+
+//==============================================================================================================================
+//	scanRange - range scans [startKey, endKey) and collects the items returned by collect, which returns nil to skip
+//				a key. Honors the paging options: a page stops after PageSize items, and the next page resumes
+//				right after the key of its bookmark. The v0.6 shim returns a range unordered, so every page reads
+//				the rest of the range and pages over it in key order.
+//==============================================================================================================================
+func scanRange(stub shim.ChaincodeStubInterface, startKey string, endKey string, opts ListOptions, collect func(key string, value []byte) (json.RawMessage, error)) ([]json.RawMessage, string, bool, error) {
+	items := []json.RawMessage{}
+
+	if opts.Bookmark != "" {
+		lastKey, err := decodeBookmark(opts.Bookmark)
+		if err != nil {
+			return nil, "", false, err
+		}
+		if lastKey < startKey || lastKey >= endKey {
+			return nil, "", false, newError(E_ARGS, "Bookmark does not belong to this query")
+		}
+		// smallest key sorting after the bookmark
+		startKey = lastKey + "\x00"
+	}
+
+	entries, err := getStateRange(stub, startKey, endKey)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	var lastKey string
+	for _, entry := range entries {
+		item, err := collect(entry.key, entry.value)
+		if err != nil {
+			return nil, "", false, err
+		}
+		if item == nil {
+			continue
+		}
+
+		// one more match than fits on the page: report it as the start of the next page
+		if opts.Paged && len(items) == opts.PageSize {
+			return items, encodeBookmark(lastKey), true, nil
+		}
+
+		items = append(items, item)
+		lastKey = entry.key
+	}
+
+	bookmark := ""
+	if lastKey != "" {
+		bookmark = encodeBookmark(lastKey)
+	}
+	return items, bookmark, false, nil
+}
+
+//==============================================================================================================================
+//	scanSource - A key range scanned by scanRanges and the collect function applied to its keys
+//==============================================================================================================================
+type scanSource struct {
+	startKey string
+	endKey   string
+	collect  func(key string, value []byte) (json.RawMessage, error)
+}
+
+//==============================================================================================================================
+//	scanRanges - scanRange over several disjoint key ranges, one after the other, as if they were one. A page may
+//				span ranges; its bookmark is the key of its last item, so the next page resumes in the range
+//				holding that key and skips the ranges before it.
+//==============================================================================================================================
+func scanRanges(stub shim.ChaincodeStubInterface, sources []scanSource, opts ListOptions) ([]json.RawMessage, string, bool, error) {
+	if opts.Bookmark != "" {
+		lastKey, err := decodeBookmark(opts.Bookmark)
+		if err != nil {
+			return nil, "", false, err
+		}
+		for len(sources) > 0 && (lastKey < sources[0].startKey || lastKey >= sources[0].endKey) {
+			sources = sources[1:]
+		}
+		if len(sources) == 0 {
+			return nil, "", false, newError(E_ARGS, "Bookmark does not belong to this query")
+		}
+	}
+
+	items := []json.RawMessage{}
+	bookmark := ""
+	for i, source := range sources {
+		sourceOpts := ListOptions{Paged: opts.Paged}
+		if opts.Paged {
+			// once the page is full a page size of 0 only tells whether a match is left in the range
+			sourceOpts.PageSize = opts.PageSize - len(items)
+		}
+		if i == 0 {
+			sourceOpts.Bookmark = opts.Bookmark
+		}
+
+		found, sourceBookmark, hasMore, err := scanRange(stub, source.startKey, source.endKey, sourceOpts, source.collect)
+		if err != nil {
+			return nil, "", false, err
+		}
+
+		items = append(items, found...)
+		if len(found) > 0 {
+			bookmark = sourceBookmark
+		}
+		if hasMore {
+			return items, bookmark, true, nil
+		}
+	}
+
+	return items, bookmark, false, nil
+}
+
+//==============================================================================================================================
+//	listResult - serializes collected items as a ListPage when paging was requested, as a plain JSON array otherwise
+//==============================================================================================================================
+func listResult(opts ListOptions, items []json.RawMessage, bookmark string, hasMore bool) ([]byte, error) {
+	if !opts.Paged {
+		return json.Marshal(items)
+	}
+
+	return json.Marshal(ListPage{Items: items, Bookmark: bookmark, HasMore: hasMore})
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	Participant - A party registered on chain and the roles it holds. Id is the caller party as returned by
+//				getCallerParty. Revoked participants are kept with Active set to false.
+//==============================================================================================================================
+type Participant struct {
+	Id     string   `json:"id"`
+	Roles  []string `json:"roles"`
+	Active bool     `json:"active"`
+}
+
+const participantObjectType = "Participant"
+
+//==============================================================================================================================
+//	 Roles - The four package roles plus Admin, which manages the participant registry
+//==============================================================================================================================
+const (
+	ROLE_ADMIN     = "Admin"
+	ROLE_SHIPPER   = "Shipper"
+	ROLE_PROVIDER  = "Provider"
+	ROLE_INSURER   = "Insurer"
+	ROLE_CONSIGNEE = "Consignee"
+)
+
+var allRoles = []string{ROLE_ADMIN, ROLE_SHIPPER, ROLE_PROVIDER, ROLE_INSURER, ROLE_CONSIGNEE}
+
+var anyRole = allRoles
+
+//==============================================================================================================================
+//	 Permission matrix - The roles allowed to call each Invoke and Query function. The Invoke and Query dispatchers
+//					reject any function that is not listed here. Checks that the caller is the party named on the
+//					package are still made by the functions themselves.
+//==============================================================================================================================
+var functionPermissions = map[string][]string{
+	// Invoke functions
+	"create":               {ROLE_SHIPPER},
+	"createfromtemplate":   {ROLE_SHIPPER},
+	"puttemplate":          {ROLE_ADMIN},
+	"acceptpkg":            {ROLE_PROVIDER},
+	"deliverpkg":           {ROLE_PROVIDER},
+	"handoverpkg":          {ROLE_PROVIDER},
+	"confirmdelivery":      {ROLE_CONSIGNEE},
+	"rejectdelivery":       {ROLE_CONSIGNEE},
+	"updatetemp":           {ROLE_PROVIDER},
+	"updatetempbatch":      {ROLE_PROVIDER},
+	"setexcursionpolicy":   {ROLE_SHIPPER},
+	"setconditions":        {ROLE_SHIPPER},
+	"updatecondition":      {ROLE_PROVIDER},
+	"migratepkgindex":      {ROLE_ADMIN},
+	"rebuildpkgindex":      {ROLE_ADMIN},
+	"migrate":              {ROLE_ADMIN},
+	"fileclaim":            {ROLE_SHIPPER, ROLE_CONSIGNEE},
+	"approveclaim":         {ROLE_INSURER},
+	"rejectclaim":          {ROLE_INSURER},
+	"payclaim":             {ROLE_INSURER},
+	"registerparticipant":  {ROLE_ADMIN},
+	"assignrole":           {ROLE_ADMIN},
+	"revokerole":           {ROLE_ADMIN},
+	"revokeparticipant":    {ROLE_ADMIN},
+	"createshipment":       {ROLE_SHIPPER},
+	"addtoshipment":        {ROLE_SHIPPER},
+	"removefromshipment":   {ROLE_SHIPPER},
+	"sealshipment":         {ROLE_SHIPPER},
+	"unsealshipment":       {ROLE_SHIPPER},
+	"updateshipmentstatus": {ROLE_PROVIDER},
+	"updateshipmenttemp":   {ROLE_PROVIDER},
+	"cancelpkg":            {ROLE_SHIPPER},
+	"archivepkg":           {ROLE_ADMIN},
+
+	// Query functions
+	"querypkgbyid":          anyRole,
+	"queryallpkgids":        anyRole,
+	"queryallpkg":           anyRole,
+	"querypkgbyprovider":    anyRole,
+	"querypkgbyshipper":     anyRole,
+	"querybypkgstatus":      anyRole,
+	"querybyrole":           anyRole,
+	"querybyrole_status":    anyRole,
+	"querytemphistory":      anyRole,
+	"queryconditionhistory": anyRole,
+	"querypkgs":             anyRole,
+	"queryparticipant":      anyRole,
+	"queryclaims":           anyRole,
+	"queryshipment":         anyRole,
+	"querytemplate":         anyRole,
+	"querytemplates":        anyRole,
+	"queryconfig":           anyRole,
+}
+
+//==============================================================================================================================
+//	participantKey - ledger key of a participant
+//==============================================================================================================================
+func participantKey(id string) (string, error) {
+	return createCompositeKey(participantObjectType, []string{id})
+}
+
+//==============================================================================================================================
+//	getParticipant - reads a participant, returning nil if it was never registered
+//==============================================================================================================================
+func getParticipant(stub shim.ChaincodeStubInterface, id string) (*Participant, error) {
+	key, err := participantKey(id)
+	if err != nil {
+		return nil, err
+	}
+
+	valAsbytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, newError(E_INTERNAL, "Failed to get state for participant "+id)
+	}
+	if valAsbytes == nil {
+		return nil, nil
+	}
+
+	var participant Participant
+	err = json.Unmarshal(valAsbytes, &participant)
+	if err != nil {
+		fmt.Println("Could not unmarshal participant object", err)
+		return nil, newError(E_DECODE, "Could not unmarshal participant object "+id)
+	}
+	return &participant, nil
+}
+
+//==============================================================================================================================
+//	putParticipant - writes a participant to the ledger
+//==============================================================================================================================
+func putParticipant(stub shim.ChaincodeStubInterface, participant *Participant) error {
+	key, err := participantKey(participant.Id)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(participant)
+	if err != nil {
+		fmt.Println("Could not marshal participant object", err)
+		return newError(E_INTERNAL, "Could not marshal participant object "+participant.Id)
+	}
+
+	err = stub.PutState(key, bytes)
+	if err != nil {
+		return newError(E_INTERNAL, "Failed writing to blockchain for participant "+participant.Id)
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	hasRole - true if the participant is active and holds role
+//==============================================================================================================================
+func (p *Participant) hasRole(role string) bool {
+	return p.Active && containsString(p.Roles, role)
+}
+
+//==============================================================================================================================
+//	authorizeFunction - consults the permission matrix for the caller of function. Functions missing from the
+//				matrix are always rejected. In insecure mode there is no caller identity and only the matrix
+//				lookup is made.
+//==============================================================================================================================
+func authorizeFunction(stub shim.ChaincodeStubInterface, function string) error {
+	roles, ok := functionPermissions[function]
+	if !ok {
+		return newError(E_FORBIDDEN, "No permissions defined for function "+function)
+	}
+
+	mode, err := getAuthMode(stub)
+	if err != nil {
+		return err
+	}
+	if mode == AUTH_MODE_INSECURE {
+		return nil
+	}
+
+	caller, err := getCallerParty(stub)
+	if err != nil {
+		return err
+	}
+
+	participant, err := getParticipant(stub, caller)
+	if err != nil {
+		return err
+	}
+	if participant == nil || !participant.Active {
+		return newError(E_FORBIDDEN, "Caller "+caller+" is not a registered participant")
+	}
+
+	for _, role := range roles {
+		if participant.hasRole(role) {
+			return nil
+		}
+	}
+	return newError(E_FORBIDDEN, "Caller "+caller+" is not permitted to call "+function)
+}
+
+//==============================================================================================================================
+//	validateRoles - checks every role is a known role
+//==============================================================================================================================
+func validateRoles(roles []string) error {
+	for _, role := range roles {
+		if !containsString(allRoles, role) {
+			return newError(E_ARGS, "Incorrect Role has been passed "+role)
+		}
+	}
+	return nil
+}
+
+//=================================================================================================================================
+//	registerparticipant - register a party with one or more roles, or reactivate a revoked party with the given roles
+//=================================================================================================================================
+func (t *SimpleChaincode) registerparticipant(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running registerparticipant()")
+
+	if len(args) < 2 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting participant Id and at least one Role")
+	}
+
+	err := validateRoles(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	participant, err := getParticipant(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if participant != nil && participant.Active {
+		return nil, newError(E_DUPLICATE, "Participant already registered "+args[0])
+	}
+
+	participant = &Participant{Id: args[0], Active: true}
+	for _, role := range args[1:] {
+		if !containsString(participant.Roles, role) {
+			participant.Roles = append(participant.Roles, role)
+		}
+	}
+
+	return nil, putParticipant(stub, participant)
+}
+
+//=================================================================================================================================
+//	assignrole - add a role to a registered participant
+//=================================================================================================================================
+func (t *SimpleChaincode) assignrole(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running assignrole()")
+
+	if len(args) != 2 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting participant Id and Role")
+	}
+
+	err := validateRoles(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	participant, err := getParticipant(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if participant == nil || !participant.Active {
+		return nil, newError(E_NOT_FOUND, "Participant not registered "+args[0])
+	}
+
+	if !containsString(participant.Roles, args[1]) {
+		participant.Roles = append(participant.Roles, args[1])
+	}
+
+	return nil, putParticipant(stub, participant)
+}
+
+//=================================================================================================================================
+//	revokerole - remove a role from a registered participant
+//=================================================================================================================================
+func (t *SimpleChaincode) revokerole(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running revokerole()")
+
+	if len(args) != 2 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting participant Id and Role")
+	}
+
+	participant, err := getParticipant(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if participant == nil || !participant.Active {
+		return nil, newError(E_NOT_FOUND, "Participant not registered "+args[0])
+	}
+
+	roles := []string{}
+	for _, role := range participant.Roles {
+		if role != args[1] {
+			roles = append(roles, role)
+		}
+	}
+	participant.Roles = roles
+
+	return nil, putParticipant(stub, participant)
+}
+
+//=================================================================================================================================
+//	revokeparticipant - deactivate a participant, removing all of its permissions
+//=================================================================================================================================
+func (t *SimpleChaincode) revokeparticipant(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running revokeparticipant()")
+
+	if len(args) != 1 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting participant Id")
+	}
+
+	participant, err := getParticipant(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if participant == nil {
+		return nil, newError(E_NOT_FOUND, "Participant not registered "+args[0])
+	}
+
+	participant.Active = false
+	participant.Roles = []string{}
+
+	return nil, putParticipant(stub, participant)
+}
+
+//=================================================================================================================================
+//	queryparticipant - query function to read a participant and its roles
+//=================================================================================================================================
+func (t *SimpleChaincode) queryparticipant(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting participant Id")
+	}
+
+	participant, err := getParticipant(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if participant == nil {
+		return nil, newError(E_NOT_FOUND, "Participant not registered "+args[0])
+	}
+
+	return json.Marshal(participant)
+}
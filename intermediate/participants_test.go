@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+// newSecureStub deploys the chaincode in secure mode with ADMIN as the deployer and registers a participant for
+// each package party of createP1.
+func newSecureStub(t *testing.T) *mockstub.MockStub {
+	t.Helper()
+	stub := mockstub.NewMockStub("intermediate", new(SimpleChaincode))
+	stub.SetCaller("ADMIN")
+	mustInit(t, stub, "init", "SHIPPER", "INSURER", "CONSIGNEE", "PROVIDER", "2", "8", "sample")
+
+	for _, participant := range [][]string{{"SHP", ROLE_SHIPPER}, {"INS", ROLE_INSURER}, {"CON", ROLE_CONSIGNEE}, {"PRV", ROLE_PROVIDER}} {
+		mustInvoke(t, stub, "registerparticipant", participant...)
+	}
+	return stub
+}
+
+// callAs makes party the caller of the following transactions through the party certificate attribute.
+func callAs(stub *mockstub.MockStub, party string) {
+	stub.SetCaller(party + "-user")
+	stub.Attributes[callerPartyAttribute] = []byte(party)
+}
+
+func TestSecureMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		caller   string
+		function string
+		args     []string
+		code     string
+	}{
+		{"shipper creates", "SHP", "create", createP1[1:], ""},
+		{"provider can not create", "PRV", "create", createP1[1:], E_FORBIDDEN},
+		{"unregistered caller", "NOBODY", "create", createP1[1:], E_FORBIDDEN},
+		{"shipper creates for another shipper", "SHP2", "create", createP1[1:], E_FORBIDDEN},
+		{"shipper creates from template", "SHP", "createfromtemplate", []string{"P4", "SHP", "INS", "CON", "T1", "vaccine", "PRV"}, ""},
+		{"shipper creates from template for another shipper", "SHP2", "createfromtemplate", []string{"P4", "SHP", "INS", "CON", "T1", "vaccine", "PRV"}, E_FORBIDDEN},
+		{"provider accepts", "PRV", "acceptpkg", []string{"P1"}, ""},
+		{"party argument is ignored", "PRV", "acceptpkg", []string{"P1", "SHP"}, ""},
+		{"registered provider of another package", "PRV2", "acceptpkg", []string{"P1"}, E_FORBIDDEN},
+		{"consignee can not update temperature", "CON", "updatetemp", []string{"P1", "5"}, E_FORBIDDEN},
+		{"everyone can query", "INS", "querypkgbyid", []string{"P1"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newSecureStub(t)
+			mustInvoke(t, stub, "registerparticipant", "PRV2", ROLE_PROVIDER)
+			mustInvoke(t, stub, "registerparticipant", "SHP2", ROLE_SHIPPER)
+			mustInvoke(t, stub, putT1[0], putT1[1:]...)
+			if test.function != "create" {
+				callAs(stub, "SHP")
+				mustInvoke(t, stub, createP1[0], createP1[1:]...)
+			}
+
+			callAs(stub, test.caller)
+			var err error
+			if test.function == "querypkgbyid" {
+				_, err = stub.MockQuery(test.function, test.args...)
+			} else {
+				_, err = stub.MockInvoke(test.function, test.args...)
+			}
+			checkCode(t, err, test.code)
+		})
+	}
+}
+
+func TestSecureModeDelivery(t *testing.T) {
+	stub := newSecureStub(t)
+	callAs(stub, "SHP")
+	mustInvoke(t, stub, createP1[0], createP1[1:]...)
+	callAs(stub, "PRV")
+	mustInvoke(t, stub, "acceptpkg", "P1")
+	mustInvoke(t, stub, "deliverpkg", "P1")
+
+	// the consignee is identified by its certificate, no party argument is needed
+	callAs(stub, "CON")
+	mustInvoke(t, stub, "confirmdelivery", "P1")
+	if packageinfo := getPkg(t, stub, "P1"); packageinfo.PkgStatus != STATUS_PKG_DELIVERED || packageinfo.Deliveries[0].DecidedBy != "CON" {
+		t.Fatalf("unexpected package %+v", packageinfo)
+	}
+}
+
+func TestSecureModeClaims(t *testing.T) {
+	stub := newSecureStub(t)
+	callAs(stub, "SHP")
+	mustInvoke(t, stub, createP1[0], createP1[1:]...)
+	callAs(stub, "PRV")
+	mustInvoke(t, stub, "acceptpkg", "P1")
+	mustInvoke(t, stub, "updatetemp", "P1", "12")
+
+	// parties are identified by their certificate, no party argument is needed
+	callAs(stub, "INS")
+	mustInvoke(t, stub, "rejectclaim", "P1", "CLM000001")
+	callAs(stub, "CON")
+	mustInvoke(t, stub, "fileclaim", "P1")
+	callAs(stub, "INS")
+	mustInvoke(t, stub, "approveclaim", "P1", "CLM000002")
+
+	claims := getClaimList(t, stub, "P1")
+	if len(claims) != 2 || claims[0].ClaimStatus != CLAIM_REJECTED || claims[1].FiledBy != "CON" || claims[1].ClaimStatus != CLAIM_APPROVED {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+func TestSecureModeIdentifiesByCommonName(t *testing.T) {
+	stub := newSecureStub(t)
+	stub.SetCaller("SHP")
+	mustInvoke(t, stub, createP1[0], createP1[1:]...)
+
+	stub.Caller = []byte("not a certificate")
+	_, err := stub.MockInvoke(createP1[0], createP1[1:]...)
+	checkCode(t, err, E_FORBIDDEN)
+}
+
+func TestParticipantRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		function string
+		args     []string
+		code     string
+		want     *Participant
+	}{
+		{"register", "registerparticipant", []string{"NEW", ROLE_SHIPPER, ROLE_CONSIGNEE}, "", &Participant{Id: "NEW", Roles: []string{ROLE_SHIPPER, ROLE_CONSIGNEE}, Active: true}},
+		{"register twice", "registerparticipant", []string{"SHP", ROLE_SHIPPER}, E_DUPLICATE, nil},
+		{"register unknown role", "registerparticipant", []string{"NEW", "Owner"}, E_ARGS, nil},
+		{"register without role", "registerparticipant", []string{"NEW"}, E_ARGS, nil},
+		{"assign role", "assignrole", []string{"SHP", ROLE_CONSIGNEE}, "", &Participant{Id: "SHP", Roles: []string{ROLE_SHIPPER, ROLE_CONSIGNEE}, Active: true}},
+		{"assign role to unknown", "assignrole", []string{"NEW", ROLE_CONSIGNEE}, E_NOT_FOUND, nil},
+		{"revoke role", "revokerole", []string{"SHP", ROLE_SHIPPER}, "", &Participant{Id: "SHP", Roles: []string{}, Active: true}},
+		{"revoke role of unknown", "revokerole", []string{"NEW", ROLE_SHIPPER}, E_NOT_FOUND, nil},
+		{"revoke participant", "revokeparticipant", []string{"SHP"}, "", &Participant{Id: "SHP", Roles: []string{}, Active: false}},
+		{"revoke unknown participant", "revokeparticipant", []string{"NEW"}, E_NOT_FOUND, nil},
+		{"revoke without id", "revokeparticipant", nil, E_ARGS, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newSecureStub(t)
+			stub.SetCaller("ADMIN")
+			_, err := stub.MockInvoke(test.function, test.args...)
+			checkCode(t, err, test.code)
+			if test.want == nil {
+				return
+			}
+
+			var participant Participant
+			if err := json.Unmarshal(mustQuery(t, stub, "queryparticipant", test.want.Id), &participant); err != nil {
+				t.Fatal(err)
+			}
+			if participant.Active != test.want.Active || !equalStrings(participant.Roles, test.want.Roles) {
+				t.Fatalf("participant %+v, want %+v", participant, *test.want)
+			}
+		})
+	}
+
+	stub := newSecureStub(t)
+	callAs(stub, "SHP")
+	_, err := stub.MockInvoke("registerparticipant", "NEW", ROLE_ADMIN)
+	checkCode(t, err, E_FORBIDDEN)
+
+	stub.SetCaller("ADMIN")
+	mustInvoke(t, stub, "revokeparticipant", "SHP")
+	callAs(stub, "SHP")
+	_, err = stub.MockInvoke(createP1[0], createP1[1:]...)
+	checkCode(t, err, E_FORBIDDEN)
+
+	_, err = stub.MockQuery("queryparticipant", "NEW")
+	checkCode(t, err, E_FORBIDDEN)
+	callAs(stub, "CON")
+	_, err = stub.MockQuery("queryparticipant", "NEW")
+	checkCode(t, err, E_NOT_FOUND)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	PkgSelector - A JSON selector over the PackageInfo fields evaluated by querypkgs. A selector node is either a
+//				combination of other nodes (and / or) or a condition on one field, named by its JSON name:
+//					{"and": [{"field": "shipper", "eq": "UPS"},
+//					         {"or": [{"field": "pkgstatus", "in": ["In_Transit", "Pkg_Damaged"]},
+//					                 {"field": "Tempraturemax", "lte": 8}]}]}
+//				Every operator given on a field condition must hold. Range operators only apply to numeric fields.
+//==============================================================================================================================
+type PkgSelector struct {
+	And   []PkgSelector `json:"and,omitempty"`
+	Or    []PkgSelector `json:"or,omitempty"`
+	Field string        `json:"field,omitempty"`
+	Eq    interface{}   `json:"eq,omitempty"`
+	In    []interface{} `json:"in,omitempty"`
+	Gt    *float64      `json:"gt,omitempty"`
+	Gte   *float64      `json:"gte,omitempty"`
+	Lt    *float64      `json:"lt,omitempty"`
+	Lte   *float64      `json:"lte,omitempty"`
+}
+
+//==============================================================================================================================
+//	pkgSelectorFields - index of the selectable PackageInfo fields by JSON name. Only string and integer fields
+//				can be selected on.
+//==============================================================================================================================
+var pkgSelectorFields = func() map[string]int {
+	fields := map[string]int{}
+	pkgType := reflect.TypeOf(PackageInfo{})
+	for i := 0; i < pkgType.NumField(); i++ {
+		field := pkgType.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.String, reflect.Int, reflect.Int32, reflect.Int64:
+			fields[name] = i
+		}
+	}
+	return fields
+}()
+
+//==============================================================================================================================
+//	validate - checks the selector is well formed before any package is evaluated
+//==============================================================================================================================
+func (sel *PkgSelector) validate() error {
+	kinds := 0
+	if sel.And != nil {
+		kinds++
+	}
+	if sel.Or != nil {
+		kinds++
+	}
+	if sel.Field != "" {
+		kinds++
+	}
+	if kinds != 1 {
+		return newError(E_ARGS, "Selector node must have exactly one of and, or, field")
+	}
+
+	for i := range sel.And {
+		err := sel.And[i].validate()
+		if err != nil {
+			return err
+		}
+	}
+	for i := range sel.Or {
+		err := sel.Or[i].validate()
+		if err != nil {
+			return err
+		}
+	}
+	if sel.Field == "" {
+		return nil
+	}
+
+	index, ok := pkgSelectorFields[sel.Field]
+	if !ok {
+		return newError(E_ARGS, "Unknown selector field "+sel.Field)
+	}
+
+	hasRange := sel.Gt != nil || sel.Gte != nil || sel.Lt != nil || sel.Lte != nil
+	if sel.Eq == nil && sel.In == nil && !hasRange {
+		return newError(E_ARGS, "Selector field "+sel.Field+" needs one of eq, in, gt, gte, lt, lte")
+	}
+	if hasRange && reflect.TypeOf(PackageInfo{}).Field(index).Type.Kind() == reflect.String {
+		return newError(E_ARGS, "Range operators can not be used on text field "+sel.Field)
+	}
+
+	return nil
+}
+
+//==============================================================================================================================
+//	matches - evaluates the selector against a package
+//==============================================================================================================================
+func (sel *PkgSelector) matches(packageinfo PackageInfo) bool {
+	if sel.And != nil {
+		for i := range sel.And {
+			if !sel.And[i].matches(packageinfo) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if sel.Or != nil {
+		for i := range sel.Or {
+			if sel.Or[i].matches(packageinfo) {
+				return true
+			}
+		}
+		return false
+	}
+
+	value := pkgSelectorValue(packageinfo, pkgSelectorFields[sel.Field])
+
+	if sel.Eq != nil && sel.Eq != value {
+		return false
+	}
+
+	if sel.In != nil {
+		found := false
+		for _, candidate := range sel.In {
+			if candidate == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	number, isNumber := value.(float64)
+	if sel.Gt != nil && !(isNumber && number > *sel.Gt) {
+		return false
+	}
+	if sel.Gte != nil && !(isNumber && number >= *sel.Gte) {
+		return false
+	}
+	if sel.Lt != nil && !(isNumber && number < *sel.Lt) {
+		return false
+	}
+	if sel.Lte != nil && !(isNumber && number <= *sel.Lte) {
+		return false
+	}
+
+	return true
+}
+
+//==============================================================================================================================
+//	pkgSelectorValue - value of a package field in the form encoding/json decodes selector operands into, so
+//				that both can be compared directly: text as string, numbers as float64 and tempratures as
+//				float64 degrees Celsius
+//==============================================================================================================================
+func pkgSelectorValue(packageinfo PackageInfo, index int) interface{} {
+	field := reflect.ValueOf(packageinfo).Field(index)
+	if field.Kind() == reflect.String {
+		return field.String()
+	}
+	if temp, ok := field.Interface().(Temperature); ok {
+		return temp.Degrees()
+	}
+	return float64(field.Int())
+}
+
+//=================================================================================================================================
+//	querypkgs - query function to read the packages matching a JSON selector, see PkgSelector
+//=================================================================================================================================
+func (t *SimpleChaincode) querypkgs(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	if len(args) < 1 || len(args) > 4 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Need to pass a JSON selector and optional includeArchived, PageSize and Bookmark")
+	}
+
+	var selector PkgSelector
+	err := json.Unmarshal([]byte(args[0]), &selector)
+	if err != nil {
+		return nil, wrapError(E_ARGS, "Could not unmarshal selector", err)
+	}
+
+	err = selector.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := parsePkgListOptions(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPackages(stub, opts, selector.matches)
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestQuerypkgs(t *testing.T) {
+	stub := newTestStub(t, createP1,
+		[]string{"create", "P2", "SHP", "INS", "CON", "-20", "-15", "ice cream", "PRV2"},
+		[]string{"create", "P3", "SHP2", "INS", "CON", "15", "25", "chocolate", "PRV"},
+		[]string{"acceptpkg", "P1", "PRV"},
+		[]string{"acceptpkg", "P3", "PRV"},
+	)
+
+	tests := []struct {
+		selector string
+		want     []string
+		code     string
+	}{
+		{`{"field": "shipper", "eq": "SHP"}`, []string{"P1", "P2"}, ""},
+		{`{"field": "pkgstatus", "in": ["In_Transit", "Pkg_Damaged"]}`, []string{"P1", "P3"}, ""},
+		{`{"field": "Tempraturemax", "lte": 8}`, []string{"1Z20170426", "P1", "P2"}, ""},
+		{`{"field": "Tempraturemin", "gt": -20, "lt": 15}`, []string{"P1"}, ""},
+		{`{"and": [{"field": "shipper", "eq": "SHP"}, {"field": "provider", "eq": "PRV"}]}`, []string{"P1"}, ""},
+		{`{"or": [{"field": "provider", "eq": "PRV2"}, {"field": "packagedes", "eq": "chocolate"}]}`, []string{"P2", "P3"}, ""},
+		{`{"field": "shipper", "eq": "NOBODY"}`, []string{}, ""},
+		{`{"field": "owner", "eq": "SHP"}`, nil, E_ARGS},
+		{`{"field": "shipper"}`, nil, E_ARGS},
+		{`{"field": "shipper", "gt": 1}`, nil, E_ARGS},
+		{`{"and": [], "field": "shipper", "eq": "SHP"}`, nil, E_ARGS},
+		{`shipper = SHP`, nil, E_ARGS},
+	}
+	for _, test := range tests {
+		result, err := stub.MockQuery("querypkgs", test.selector)
+		checkCode(t, err, test.code)
+		if test.code != "" {
+			continue
+		}
+		if got := pkgIds(t, result); !equalStrings(got, test.want) {
+			t.Errorf("querypkgs %s = %v, want %v", test.selector, got, test.want)
+		}
+	}
+
+	_, err := stub.MockQuery("querypkgs")
+	checkCode(t, err, E_ARGS)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/scenario"
+)
+
+// TestScenarios replays every script in testdata/scenarios against a freshly deployed chaincode.
+func TestScenarios(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "scenarios", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no scenarios in testdata/scenarios")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			script, err := scenario.Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report := scenario.Run(new(SimpleChaincode), script); !report.OK() {
+				t.Error(report)
+			}
+		})
+	}
+}
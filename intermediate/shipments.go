@@ -0,0 +1,473 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	Shipment - A pallet or container grouping packages of one Shipper that move together. Packages can only be added
+//				or removed while the shipment is unsealed. Status changes and temprature readings applied to the
+//				shipment cascade to every package it contains. Shipments are stored under Shipment~ShipmentId.
+//==============================================================================================================================
+type Shipment struct {
+	ShipmentId string   `json:"shipmentid"`
+	Shipper    string   `json:"shipper"`
+	PkgIds     []string `json:"packageids"`
+	Sealed     bool     `json:"sealed"`
+	CreatedAt  int64    `json:"createdat"`
+	SealedAt   int64    `json:"sealedat,omitempty"`
+}
+
+//==============================================================================================================================
+//	ShipmentPackage - A package of a shipment as listed by queryshipment
+//==============================================================================================================================
+type ShipmentPackage struct {
+	PkgId     string `json:"packageid"`
+	PkgStatus string `json:"pkgstatus"`
+}
+
+const shipmentObjectType = "Shipment"
+
+//==============================================================================================================================
+//	shipmentKey - ledger key of a shipment
+//==============================================================================================================================
+func shipmentKey(shipmentId string) (string, error) {
+	return createCompositeKey(shipmentObjectType, []string{shipmentId})
+}
+
+//==============================================================================================================================
+//	getShipment - reads a shipment, failing if it does not exist
+//==============================================================================================================================
+func getShipment(stub shim.ChaincodeStubInterface, shipmentId string) (Shipment, error) {
+	var shipment Shipment
+
+	key, err := shipmentKey(shipmentId)
+	if err != nil {
+		return shipment, err
+	}
+
+	valAsbytes, err := stub.GetState(key)
+	if err != nil {
+		return shipment, newError(E_INTERNAL, "Failed to get state for shipment "+shipmentId)
+	}
+	if valAsbytes == nil {
+		return shipment, newError(E_NOT_FOUND, "Invalid ShipmentId Passed "+shipmentId)
+	}
+
+	err = json.Unmarshal(valAsbytes, &shipment)
+	if err != nil {
+		fmt.Println("Could not unmarshal shipment object", err)
+		return shipment, newError(E_DECODE, "Could not unmarshal shipment object "+shipmentId)
+	}
+	return shipment, nil
+}
+
+//==============================================================================================================================
+//	putShipment - writes a shipment to the ledger
+//==============================================================================================================================
+func putShipment(stub shim.ChaincodeStubInterface, shipment *Shipment) error {
+	key, err := shipmentKey(shipment.ShipmentId)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(shipment)
+	if err != nil {
+		fmt.Println("Could not marshal shipment object", err)
+		return newError(E_INTERNAL, "Could not marshal shipment object "+shipment.ShipmentId)
+	}
+
+	err = stub.PutState(key, bytes)
+	if err != nil {
+		return newError(E_INTERNAL, "Failed writing to blockchain for shipment "+shipment.ShipmentId)
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	checkNotSealed - fails with E_ILLEGAL_STATE while the package belongs to a sealed shipment, whose packages only
+//				move and take readings together through updateshipmentstatus and updateshipmenttemp
+//==============================================================================================================================
+func checkNotSealed(stub shim.ChaincodeStubInterface, packageinfo *PackageInfo) error {
+	if packageinfo.ShipmentId == "" {
+		return nil
+	}
+
+	shipment, err := getShipment(stub, packageinfo.ShipmentId)
+	if err != nil {
+		return err
+	}
+	if shipment.Sealed {
+		return newError(E_ILLEGAL_STATE, "Package "+packageinfo.PkgId+" is in sealed shipment "+shipment.ShipmentId+", it moves with the shipment")
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	getOwnedShipment - reads a shipment and checks the caller is its Shipper. The caller is taken from
+//				args[argIndex] in insecure mode.
+//==============================================================================================================================
+func getOwnedShipment(stub shim.ChaincodeStubInterface, args []string, argIndex int) (Shipment, error) {
+	shipment, err := getShipment(stub, args[0])
+	if err != nil {
+		return shipment, err
+	}
+
+	err = authorizeParty(stub, args, argIndex, shipment.Shipper)
+	if err != nil {
+		return shipment, wrapError(E_FORBIDDEN, "Wrong Shipper - Not authorized to change this shipment", err)
+	}
+	return shipment, nil
+}
+
+//=================================================================================================================================
+//	createshipment - create an empty, unsealed shipment owned by the calling Shipper. Expects ShipmentId and, in
+//				insecure mode, the Shipper.
+//=================================================================================================================================
+func (t *SimpleChaincode) createshipment(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running createshipment()")
+
+	if len(args) != 1 && len(args) != 2 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting ShipmentId and, in insecure mode, Shipper")
+	}
+
+	shipper, err := getCallerFromArgs(stub, args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := shipmentKey(args[0])
+	if err != nil {
+		return nil, err
+	}
+	valAsbytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, newError(E_INTERNAL, "Failed to get state for shipment "+args[0])
+	}
+	if valAsbytes != nil {
+		return nil, newError(E_DUPLICATE, "Shipment already present on blockchain "+args[0])
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	shipment := Shipment{ShipmentId: args[0], Shipper: shipper, PkgIds: []string{}, CreatedAt: timestamp}
+	return nil, putShipment(stub, &shipment)
+}
+
+//=================================================================================================================================
+//	addtoshipment - add a package of the Shipper to an unsealed shipment. Expects ShipmentId, PkgId and, in insecure
+//				mode, the Shipper.
+//=================================================================================================================================
+func (t *SimpleChaincode) addtoshipment(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running addtoshipment()")
+
+	if len(args) != 2 && len(args) != 3 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting ShipmentId, PkgId and, in insecure mode, Shipper")
+	}
+
+	shipment, err := getOwnedShipment(stub, args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if shipment.Sealed {
+		return nil, newError(E_ILLEGAL_STATE, "Shipment "+shipment.ShipmentId+" is sealed")
+	}
+
+	packageinfo, err := getPackageInfo(stub, args[1])
+	if err != nil {
+		return nil, err
+	}
+	if packageinfo.Shipper != shipment.Shipper {
+		return nil, newError(E_ILLEGAL_STATE, "Package "+packageinfo.PkgId+" does not belong to Shipper "+shipment.Shipper)
+	}
+	if packageinfo.ShipmentId != "" {
+		return nil, newError(E_ILLEGAL_STATE, "Package "+packageinfo.PkgId+" is already in shipment "+packageinfo.ShipmentId)
+	}
+	if isTerminalStatus(packageinfo.PkgStatus) {
+		return nil, newError(E_ILLEGAL_STATE, "Package "+packageinfo.PkgId+" is "+packageinfo.PkgStatus+" and can not be shipped")
+	}
+
+	packageinfo.ShipmentId = shipment.ShipmentId
+	shipment.PkgIds = append(shipment.PkgIds, packageinfo.PkgId)
+
+	err = putShipment(stub, &shipment)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putPackageInfo(stub, &packageinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitPkgEvents(stub, []PkgEvent{{Event: EVENT_PKG_UPDATED, PkgId: packageinfo.PkgId, OldStatus: packageinfo.PkgStatus, NewStatus: packageinfo.PkgStatus, Actor: eventActor(stub, shipment.Shipper), Timestamp: timestamp}})
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	removefromshipment - take a package out of an unsealed shipment. Expects ShipmentId, PkgId and, in insecure mode,
+//				the Shipper.
+//=================================================================================================================================
+func (t *SimpleChaincode) removefromshipment(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running removefromshipment()")
+
+	if len(args) != 2 && len(args) != 3 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting ShipmentId, PkgId and, in insecure mode, Shipper")
+	}
+
+	shipment, err := getOwnedShipment(stub, args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if shipment.Sealed {
+		return nil, newError(E_ILLEGAL_STATE, "Shipment "+shipment.ShipmentId+" is sealed")
+	}
+	if !containsString(shipment.PkgIds, args[1]) {
+		return nil, newError(E_ILLEGAL_STATE, "Package "+args[1]+" is not in shipment "+shipment.ShipmentId)
+	}
+
+	packageinfo, err := getPackageInfo(stub, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	pkgIds := []string{}
+	for _, pkgId := range shipment.PkgIds {
+		if pkgId != packageinfo.PkgId {
+			pkgIds = append(pkgIds, pkgId)
+		}
+	}
+	shipment.PkgIds = pkgIds
+	packageinfo.ShipmentId = ""
+
+	err = putShipment(stub, &shipment)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putPackageInfo(stub, &packageinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitPkgEvents(stub, []PkgEvent{{Event: EVENT_PKG_UPDATED, PkgId: packageinfo.PkgId, OldStatus: packageinfo.PkgStatus, NewStatus: packageinfo.PkgStatus, Actor: eventActor(stub, shipment.Shipper), Timestamp: timestamp}})
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	sealshipment - seal a shipment so that its packages move together. Expects ShipmentId and, in insecure mode, the
+//				Shipper.
+//=================================================================================================================================
+func (t *SimpleChaincode) sealshipment(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running sealshipment()")
+
+	if len(args) != 1 && len(args) != 2 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting ShipmentId and, in insecure mode, Shipper")
+	}
+
+	shipment, err := getOwnedShipment(stub, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if shipment.Sealed {
+		return nil, newError(E_ILLEGAL_STATE, "Shipment "+shipment.ShipmentId+" is already sealed")
+	}
+	if len(shipment.PkgIds) == 0 {
+		return nil, newError(E_ILLEGAL_STATE, "Shipment "+shipment.ShipmentId+" has no packages")
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	shipment.Sealed = true
+	shipment.SealedAt = timestamp
+	return nil, putShipment(stub, &shipment)
+}
+
+//=================================================================================================================================
+//	unsealshipment - unseal a shipment so that packages can be added or removed. Expects ShipmentId and, in insecure
+//				mode, the Shipper.
+//=================================================================================================================================
+func (t *SimpleChaincode) unsealshipment(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running unsealshipment()")
+
+	if len(args) != 1 && len(args) != 2 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting ShipmentId and, in insecure mode, Shipper")
+	}
+
+	shipment, err := getOwnedShipment(stub, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if !shipment.Sealed {
+		return nil, newError(E_ILLEGAL_STATE, "Shipment "+shipment.ShipmentId+" is not sealed")
+	}
+
+	shipment.Sealed = false
+	shipment.SealedAt = 0
+	return nil, putShipment(stub, &shipment)
+}
+
+//=================================================================================================================================
+//	updateshipmentstatus - move every package of a sealed shipment to In_Transit (accept) or Delivery_Pending
+//				(deliver). The caller must be the current custodian of every package, and the shipment only
+//				moves if every package can. Expects ShipmentId, Status and, in insecure mode, the Provider.
+//=================================================================================================================================
+func (t *SimpleChaincode) updateshipmentstatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running updateshipmentstatus()")
+
+	if len(args) != 2 && len(args) != 3 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting ShipmentId, Status and, in insecure mode, Provider")
+	}
+
+	status := args[1]
+	if status != STATUS_IN_TRANSIT && status != STATUS_DELIVERY_PENDING {
+		return nil, newError(E_ARGS, "Shipment status can only be changed to "+STATUS_IN_TRANSIT+" or "+STATUS_DELIVERY_PENDING)
+	}
+
+	shipment, err := getShipment(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if !shipment.Sealed {
+		return nil, newError(E_ILLEGAL_STATE, "Shipment "+shipment.ShipmentId+" must be sealed before it moves")
+	}
+
+	caller, err := getCallerFromArgs(stub, args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	event := EVENT_PKG_ACCEPTED
+	if status == STATUS_DELIVERY_PENDING {
+		event = EVENT_PKG_DELIVERED
+	}
+
+	var events []PkgEvent
+	for _, pkgId := range shipment.PkgIds {
+		packageinfo, err := getPackageInfo(stub, pkgId)
+		if err != nil {
+			return nil, err
+		}
+
+		err = checkPkgTransition(&packageinfo, status)
+		if err != nil {
+			return nil, wrapError(errorCode(err), "Package "+pkgId, err)
+		}
+
+		custodian := currentCustodian(&packageinfo)
+		if caller != custodian {
+			return nil, newError(E_FORBIDDEN, "Caller "+caller+" is not the current custodian of package "+pkgId)
+		}
+
+		if status == STATUS_DELIVERY_PENDING {
+			packageinfo.Deliveries = append(packageinfo.Deliveries, DeliveryAttempt{DeliveredBy: custodian, SubmittedAt: timestamp})
+		}
+		events = append(events, PkgEvent{Event: event, PkgId: pkgId, OldStatus: packageinfo.PkgStatus, NewStatus: status, Actor: caller, Timestamp: timestamp})
+		packageinfo.PkgStatus = status
+
+		err = putPackageInfo(stub, &packageinfo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, emitPkgEvents(stub, events)
+}
+
+//=================================================================================================================================
+//	updateshipmenttemp - apply one temprature reading to every package of a shipment, with the same rule as
+//				updatetemp. Returns a result per package as updatetempbatch does. Expects ShipmentId, temprature,
+//				Provider (only checked in insecure mode) and optional sensor id.
+//=================================================================================================================================
+func (t *SimpleChaincode) updateshipmenttemp(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running updateshipmenttemp()")
+
+	if len(args) < 2 || len(args) > 4 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting ShipmentId, temprature, Provider (in insecure mode) and optional sensor id")
+	}
+
+	shipment, err := getShipment(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = parseTemperature(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := getCallerFromArgs(stub, args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	sensorId := ""
+	if len(args) == 4 {
+		sensorId = args[3]
+	}
+
+	items := []TempBatchItem{}
+	for _, pkgId := range shipment.PkgIds {
+		items = append(items, TempBatchItem{PkgId: pkgId, Reading: TempValue(args[1]), SensorId: sensorId})
+	}
+
+	return applyTempBatch(stub, caller, items)
+}
+
+//=================================================================================================================================
+//	queryshipment - query function to read a shipment and the status of each of its packages
+//=================================================================================================================================
+func (t *SimpleChaincode) queryshipment(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting ShipmentId")
+	}
+
+	shipment, err := getShipment(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	packages := []ShipmentPackage{}
+	for _, pkgId := range shipment.PkgIds {
+		packageinfo, err := getPackageInfo(stub, pkgId)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, ShipmentPackage{PkgId: pkgId, PkgStatus: packageinfo.PkgStatus})
+	}
+
+	return json.Marshal(struct {
+		Shipment
+		Packages []ShipmentPackage `json:"packages"`
+	}{shipment, packages})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+var (
+	createP2  = []string{"create", "P2", "SHP", "INS", "CON", "2", "8", "vaccine", "PRV"}
+	openS1    = [][]string{createP1, createP2, {"createshipment", "S1", "SHP"}, {"addtoshipment", "S1", "P1", "SHP"}, {"addtoshipment", "S1", "P2", "SHP"}}
+	sealedS1  = then(openS1, []string{"sealshipment", "S1", "SHP"})
+	movingS1  = then(sealedS1, []string{"updateshipmentstatus", "S1", STATUS_IN_TRANSIT, "PRV"})
+	shipOther = []string{"create", "P3", "SHP2", "INS", "CON", "2", "8", "vaccine", "PRV"}
+)
+
+// shipmentResult is the result of queryshipment
+type shipmentResult struct {
+	Shipment
+	Packages []ShipmentPackage `json:"packages"`
+}
+
+func getShipmentResult(t *testing.T, stub *mockstub.MockStub, shipmentId string) shipmentResult {
+	t.Helper()
+	var result shipmentResult
+	if err := json.Unmarshal(mustQuery(t, stub, "queryshipment", shipmentId), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// shipmentStatuses checks the status of every package in shipment S1
+func shipmentStatuses(status string) func(t *testing.T, stub *mockstub.MockStub) {
+	return func(t *testing.T, stub *mockstub.MockStub) {
+		result := getShipmentResult(t, stub, "S1")
+		if len(result.Packages) != 2 {
+			t.Fatalf("unexpected shipment %+v", result)
+		}
+		for _, pkg := range result.Packages {
+			if pkg.PkgStatus != status {
+				t.Fatalf("package %s is %s, want %s", pkg.PkgId, pkg.PkgStatus, status)
+			}
+		}
+	}
+}
+
+func TestShipments(t *testing.T) {
+	runInvokeTests(t, []invokeTest{
+		{name: "create", function: "createshipment", args: []string{"S1", "SHP"},
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if result := getShipmentResult(t, stub, "S1"); result.Shipper != "SHP" || result.Sealed || len(result.Packages) != 0 {
+					t.Fatalf("unexpected shipment %+v", result)
+				}
+			}},
+		{name: "create twice", setup: [][]string{{"createshipment", "S1", "SHP"}}, function: "createshipment", args: []string{"S1", "SHP"}, code: E_DUPLICATE},
+		{name: "create without id", function: "createshipment", code: E_ARGS},
+		{name: "add", setup: openS1[:3], function: "addtoshipment", args: []string{"S1", "P1", "SHP"},
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if packageinfo := getPkg(t, stub, "P1"); packageinfo.ShipmentId != "S1" {
+					t.Fatalf("P1 is in shipment %q", packageinfo.ShipmentId)
+				}
+				if got := eventPkgIds(t, stub, EVENT_PKG_UPDATED); !equalStrings(got, []string{"P1"}) {
+					t.Fatalf("event for %v", got)
+				}
+			}},
+		{name: "add to unknown shipment", setup: [][]string{createP1}, function: "addtoshipment", args: []string{"S9", "P1", "SHP"}, code: E_NOT_FOUND},
+		{name: "add by other shipper", setup: openS1[:3], function: "addtoshipment", args: []string{"S1", "P1", "SHP2"}, code: E_FORBIDDEN},
+		{name: "add package of other shipper", setup: then(openS1[:3], shipOther), function: "addtoshipment", args: []string{"S1", "P3", "SHP"}, code: E_ILLEGAL_STATE},
+		{name: "add twice", setup: openS1, function: "addtoshipment", args: []string{"S1", "P1", "SHP"}, code: E_ILLEGAL_STATE},
+		{name: "add damaged package", setup: then(openS1[:3], []string{"updatetemp", "P1", "20", "PRV"}), function: "addtoshipment", args: []string{"S1", "P1", "SHP"}, code: E_ILLEGAL_STATE},
+		{name: "add to sealed", setup: then(sealedS1, []string{"create", "P4", "SHP", "INS", "CON", "2", "8", "vaccine", "PRV"}), function: "addtoshipment", args: []string{"S1", "P4", "SHP"}, code: E_ILLEGAL_STATE},
+		{name: "remove", setup: openS1, function: "removefromshipment", args: []string{"S1", "P1", "SHP"},
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if result := getShipmentResult(t, stub, "S1"); len(result.PkgIds) != 1 || result.PkgIds[0] != "P2" || getPkg(t, stub, "P1").ShipmentId != "" {
+					t.Fatalf("P1 was not removed from %+v", result)
+				}
+				if got := eventPkgIds(t, stub, EVENT_PKG_UPDATED); !equalStrings(got, []string{"P1"}) {
+					t.Fatalf("event for %v", got)
+				}
+			}},
+		{name: "remove package not in shipment", setup: openS1[:3], function: "removefromshipment", args: []string{"S1", "P1", "SHP"}, code: E_ILLEGAL_STATE},
+		{name: "remove from sealed", setup: sealedS1, function: "removefromshipment", args: []string{"S1", "P1", "SHP"}, code: E_ILLEGAL_STATE},
+		{name: "seal", setup: openS1, function: "sealshipment", args: []string{"S1", "SHP"},
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if result := getShipmentResult(t, stub, "S1"); !result.Sealed || result.SealedAt == 0 {
+					t.Fatalf("shipment not sealed %+v", result)
+				}
+			}},
+		{name: "seal empty", setup: openS1[:3], function: "sealshipment", args: []string{"S1", "SHP"}, code: E_ILLEGAL_STATE},
+		{name: "seal twice", setup: sealedS1, function: "sealshipment", args: []string{"S1", "SHP"}, code: E_ILLEGAL_STATE},
+		{name: "unseal", setup: sealedS1, function: "unsealshipment", args: []string{"S1", "SHP"},
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if getShipmentResult(t, stub, "S1").Sealed {
+					t.Fatal("shipment is still sealed")
+				}
+			}},
+		{name: "unseal open", setup: openS1, function: "unsealshipment", args: []string{"S1", "SHP"}, code: E_ILLEGAL_STATE},
+	})
+}
+
+func TestUpdateshipmentstatus(t *testing.T) {
+	runInvokeTests(t, []invokeTest{
+		{name: "in transit", setup: sealedS1, function: "updateshipmentstatus", args: []string{"S1", STATUS_IN_TRANSIT, "PRV"}, check: shipmentStatuses(STATUS_IN_TRANSIT)},
+		{name: "delivery pending", setup: movingS1, function: "updateshipmentstatus", args: []string{"S1", STATUS_DELIVERY_PENDING, "PRV"}, check: shipmentStatuses(STATUS_DELIVERY_PENDING)},
+		{name: "open shipment", setup: openS1, function: "updateshipmentstatus", args: []string{"S1", STATUS_IN_TRANSIT, "PRV"}, code: E_ILLEGAL_STATE},
+		{name: "delivered", setup: movingS1, function: "updateshipmentstatus", args: []string{"S1", STATUS_PKG_DELIVERED, "PRV"}, code: E_ARGS},
+		{name: "wrong provider", setup: sealedS1, function: "updateshipmentstatus", args: []string{"S1", STATUS_IN_TRANSIT, "PRV2"}, code: E_FORBIDDEN, check: shipmentStatuses(STATUS_LABEL_GENERATED)},
+		{name: "skipping a status", setup: sealedS1, function: "updateshipmentstatus", args: []string{"S1", STATUS_DELIVERY_PENDING, "PRV"}, code: E_ILLEGAL_STATE, check: shipmentStatuses(STATUS_LABEL_GENERATED)},
+		{name: "one package can not move", setup: then(openS1[:4], []string{"acceptpkg", "P2", "PRV"}, []string{"addtoshipment", "S1", "P2", "SHP"}, []string{"sealshipment", "S1", "SHP"}), function: "updateshipmentstatus", args: []string{"S1", STATUS_IN_TRANSIT, "PRV"}, code: E_ILLEGAL_STATE, status: STATUS_LABEL_GENERATED},
+		{name: "missing status", setup: sealedS1, function: "updateshipmentstatus", args: []string{"S1"}, code: E_ARGS},
+	})
+}
+
+func TestUpdateshipmenttemp(t *testing.T) {
+	runInvokeTests(t, []invokeTest{
+		{name: "in range", setup: movingS1, function: "updateshipmenttemp", args: []string{"S1", "5", "PRV", "S1-logger"}, check: shipmentStatuses(STATUS_IN_TRANSIT)},
+		{name: "out of range", setup: movingS1, function: "updateshipmenttemp", args: []string{"S1", "9", "PRV"}, check: shipmentStatuses(STATUS_PKG_DAMAGED)},
+		{name: "unknown shipment", setup: movingS1, function: "updateshipmenttemp", args: []string{"S9", "5", "PRV"}, code: E_NOT_FOUND},
+		{name: "missing reading", setup: movingS1, function: "updateshipmenttemp", args: []string{"S1"}, code: E_ARGS},
+		{name: "not the custodian", setup: movingS1, function: "updateshipmenttemp", args: []string{"S1", "9", "PRV2"}, check: shipmentStatuses(STATUS_IN_TRANSIT)},
+	})
+}
+
+func TestSealedShipmentPackages(t *testing.T) {
+	unsealed := then(openS1, []string{"sealshipment", "S1", "SHP"}, []string{"unsealshipment", "S1", "SHP"})
+	runInvokeTests(t, []invokeTest{
+		{name: "accept", setup: sealedS1, function: "acceptpkg", args: []string{"P1", "PRV"}, code: E_ILLEGAL_STATE, status: STATUS_LABEL_GENERATED},
+		{name: "deliver", setup: movingS1, function: "deliverpkg", args: []string{"P1", "PRV"}, code: E_ILLEGAL_STATE, status: STATUS_IN_TRANSIT},
+		{name: "hand over", setup: movingS1, function: "handoverpkg", args: []string{"P1", "PRV2", "PRV"}, code: E_ILLEGAL_STATE, status: STATUS_IN_TRANSIT},
+		{name: "temprature", setup: movingS1, function: "updatetemp", args: []string{"P1", "20", "PRV"}, code: E_ILLEGAL_STATE, status: STATUS_IN_TRANSIT},
+		{name: "batch temprature", setup: movingS1, function: "updatetempbatch", args: []string{`[{"pkgid": "P2", "reading": 5}, {"pkgid": "P1", "reading": 20}]`, "PRV"}, code: E_ILLEGAL_STATE, status: STATUS_IN_TRANSIT},
+		{name: "condition", setup: then([][]string{createP1, conditionsP1}, movingS1[1:]...), function: "updatecondition", args: []string{"P1", CHANNEL_SHOCK, "9", "PRV"}, code: E_ILLEGAL_STATE, status: STATUS_IN_TRANSIT},
+		{name: "temprature channel", setup: movingS1, function: "updatecondition", args: []string{"P1", CHANNEL_TEMPERATURE, "20", "PRV"}, code: E_ILLEGAL_STATE, status: STATUS_IN_TRANSIT},
+		{name: "accept in open shipment", setup: openS1, function: "acceptpkg", args: []string{"P1", "PRV"}, status: STATUS_IN_TRANSIT},
+		{name: "accept in unsealed shipment", setup: unsealed, function: "acceptpkg", args: []string{"P1", "PRV"}, status: STATUS_IN_TRANSIT},
+	})
+}
+
+func TestQueryshipment(t *testing.T) {
+	stub := newTestStub(t, movingS1...)
+	result := getShipmentResult(t, stub, "S1")
+	if result.ShipmentId != "S1" || !result.Sealed || len(result.Packages) != 2 || result.Packages[0].PkgId != "P1" {
+		t.Fatalf("unexpected shipment %+v", result)
+	}
+
+	_, err := stub.MockQuery("queryshipment", "S9")
+	checkCode(t, err, E_NOT_FOUND)
+	_, err = stub.MockQuery("queryshipment")
+	checkCode(t, err, E_ARGS)
+}
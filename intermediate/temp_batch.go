@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	TempBatchItem - One reading in an updatetempbatch request, as buffered by an IoT gateway. The reading is a number
+//				in degrees Celsius or a string such as "40.1F".
+//==============================================================================================================================
+type TempBatchItem struct {
+	PkgId     string    `json:"pkgid"`
+	Reading   TempValue `json:"reading"`
+	Timestamp int64     `json:"ts"`
+	SensorId  string    `json:"sensor"`
+}
+
+//==============================================================================================================================
+//	TempBatchResult - Outcome of one TempBatchItem, with the reading in tenths of a degree Celsius. Error is set
+//				instead of Seq and PkgStatus when the reading could not be applied.
+//==============================================================================================================================
+type TempBatchResult struct {
+	PkgId     string      `json:"pkgid"`
+	Reading   Temperature `json:"reading"`
+	Seq       int         `json:"seq,omitempty"`
+	PkgStatus string      `json:"pkgstatus,omitempty"`
+	Code      string      `json:"code,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+//=================================================================================================================================
+//	updatetempbatch - apply a JSON array of readings across many packages in one transaction. Every reading goes through
+//				the same rule as updatetemp; a reading that can not be applied, e.g. for a package the caller does not
+//				hold, is reported in the result summary without failing the rest of the batch. A reading for a package
+//				of a sealed shipment fails the whole batch, such readings go through updateshipmenttemp. Expects the
+//				JSON array and, in insecure mode, the Provider.
+//=================================================================================================================================
+func (t *SimpleChaincode) updatetempbatch(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running updatetempbatch()")
+
+	if len(args) != 1 && len(args) != 2 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting JSON array of {pkgid, reading, ts, sensor} and, in insecure mode, Provider")
+	}
+
+	var items []TempBatchItem
+	err := json.Unmarshal([]byte(args[0]), &items)
+	if err != nil {
+		return nil, wrapError(E_ARGS, "Could not unmarshal temprature batch", err)
+	}
+
+	caller, err := getCallerFromArgs(stub, args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		packageinfo, err := getPackageInfo(stub, item.PkgId)
+		if err != nil {
+			// reported for the item by applyTempBatch
+			continue
+		}
+		err = checkNotSealed(stub, &packageinfo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return applyTempBatch(stub, caller, items)
+}
+
+//=================================================================================================================================
+//	applyTempBatch - record each reading of items on behalf of caller, which must be the current custodian of the
+//				package, and return the per reading summary. Items without a timestamp get the transaction timestamp.
+//				Every reading applied is emitted as a PkgEvent.
+//=================================================================================================================================
+func applyTempBatch(stub shim.ChaincodeStubInterface, caller string, items []TempBatchItem) ([]byte, error) {
+	txTimestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	// packages are read once and written back once, in the order they first appear in the batch
+	packages := map[string]*PackageInfo{}
+	var updated []string
+	var events []PkgEvent
+
+	results := make([]TempBatchResult, 0, len(items))
+	for _, item := range items {
+		result := TempBatchResult{PkgId: item.PkgId}
+
+		reading, err := parseTemperature(string(item.Reading))
+		if err != nil {
+			result.Code, result.Error = errorCode(err), errorMessage(err)
+			results = append(results, result)
+			continue
+		}
+		result.Reading = reading
+
+		packageinfo, ok := packages[item.PkgId]
+		if !ok {
+			loaded, err := getPackageInfo(stub, item.PkgId)
+			if err != nil {
+				result.Code, result.Error = errorCode(err), errorMessage(err)
+				results = append(results, result)
+				continue
+			}
+			packageinfo = &loaded
+			packages[item.PkgId] = packageinfo
+		}
+
+		if custodian := currentCustodian(packageinfo); caller != custodian {
+			result.Code, result.Error = E_FORBIDDEN, "Caller "+caller+" is not the current custodian of package "+item.PkgId
+			results = append(results, result)
+			continue
+		}
+
+		timestamp := item.Timestamp
+		if timestamp == 0 {
+			timestamp = txTimestamp
+		}
+
+		oldStatus := packageinfo.PkgStatus
+		tempreading, err := recordTempReading(stub, packageinfo, reading, item.SensorId, timestamp)
+		if err != nil {
+			result.Code, result.Error = errorCode(err), errorMessage(err)
+			results = append(results, result)
+			continue
+		}
+
+		if !containsString(updated, item.PkgId) {
+			updated = append(updated, item.PkgId)
+		}
+
+		result.Seq = tempreading.Seq
+		result.PkgStatus = tempreading.PkgStatus
+		results = append(results, result)
+
+		events = append(events, PkgEvent{Event: readingEventName(EVENT_PKG_TEMP_READING, tempreading.PkgStatus), PkgId: item.PkgId, OldStatus: oldStatus, NewStatus: tempreading.PkgStatus, Actor: caller, Reading: &tempreading.Reading, Timestamp: timestamp})
+	}
+
+	for _, pkgId := range updated {
+		err = putPackageInfo(stub, packages[pkgId])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = emitPkgEvents(stub, events)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(results)
+}
+
+//==============================================================================================================================
+//	containsString - true if value is one of values
+//==============================================================================================================================
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUpdatetempbatch(t *testing.T) {
+	stub := newTestStub(t, createP1, createP2, []string{"acceptpkg", "P1", "PRV"})
+
+	batch := `[{"pkgid": "P1", "reading": 4.5, "sensor": "G1"},
+		{"pkgid": "P2", "reading": "50F", "ts": 1493164000},
+		{"pkgid": "P1", "reading": 8.1},
+		{"pkgid": "P1", "reading": 5},
+		{"pkgid": "P9", "reading": 5},
+		{"pkgid": "P2", "reading": "warm"}]`
+	var results []TempBatchResult
+	if err := json.Unmarshal(mustInvoke(t, stub, "updatetempbatch", batch, "PRV"), &results); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []TempBatchResult{
+		{PkgId: "P1", Reading: 45, Seq: 1, PkgStatus: STATUS_IN_TRANSIT},
+		{PkgId: "P2", Reading: 100, Seq: 1, PkgStatus: STATUS_PKG_DAMAGED},
+		{PkgId: "P1", Reading: 81, Seq: 2, PkgStatus: STATUS_PKG_DAMAGED},
+		{PkgId: "P1", Reading: 50, Code: E_ILLEGAL_STATE},
+		{PkgId: "P9", Reading: 50, Code: E_NOT_FOUND},
+		{PkgId: "P2", Code: E_ARGS},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	for i := range want {
+		got := results[i]
+		got.Error = ""
+		if got != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, results[i], want[i])
+		}
+		if (want[i].Code != "") != (results[i].Error != "") {
+			t.Errorf("result %d has code %q and error %q", i, results[i].Code, results[i].Error)
+		}
+	}
+
+	if getPkg(t, stub, "P1").PkgStatus != STATUS_PKG_DAMAGED || getPkg(t, stub, "P2").PkgStatus != STATUS_PKG_DAMAGED {
+		t.Fatal("batch readings were not written back to the packages")
+	}
+
+	event, _ := stub.LastEvent()
+	var events []PkgEvent
+	if event.Name != EVENT_PKG_BATCH || json.Unmarshal(event.Payload, &events) != nil || len(events) != 3 {
+		t.Fatalf("unexpected event %s %s", event.Name, event.Payload)
+	}
+	if events[1].Event != EVENT_PKG_DAMAGED || events[1].Timestamp != 1493164000 || events[2].Package == nil || events[2].Package.PkgStatus != STATUS_PKG_DAMAGED {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
+
+func TestUpdatetempbatchFailedReading(t *testing.T) {
+	stub := newTestStub(t, createP1, []string{"acceptpkg", "P1", "PRV"})
+
+	// a claim already in progress makes the damaging reading fail when it opens its claim
+	key, _ := claimKey("P1", "CLM000001")
+	stub.State[key], _ = json.Marshal(Claim{ClaimId: "CLM000001", PkgId: "P1", ClaimStatus: CLAIM_OPEN})
+
+	batch := `[{"pkgid": "P1", "reading": 5}, {"pkgid": "P1", "reading": 20}, {"pkgid": "P1", "reading": 6}]`
+	var results []TempBatchResult
+	if err := json.Unmarshal(mustInvoke(t, stub, "updatetempbatch", batch, "PRV"), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[1].Code != E_ILLEGAL_STATE || results[2].Seq != 2 || results[2].PkgStatus != STATUS_IN_TRANSIT {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	// the failed reading left neither its status nor its sequence number on the package
+	if packageinfo := getPkg(t, stub, "P1"); packageinfo.PkgStatus != STATUS_IN_TRANSIT || packageinfo.ReadingSeq != 2 || packageinfo.DamageChannel != "" {
+		t.Fatalf("unexpected package %+v", packageinfo)
+	}
+	var history []TempReading
+	if err := json.Unmarshal(mustQuery(t, stub, "querytemphistory", "P1"), &history); err != nil || len(history) != 2 || history[1].Reading != 60 {
+		t.Fatalf("unexpected history %+v, %v", history, err)
+	}
+}
+
+func TestUpdatetempbatchCustodian(t *testing.T) {
+	stub := newTestStub(t, createP1, createP2, []string{"acceptpkg", "P1", "PRV"}, []string{"acceptpkg", "P2", "PRV"}, []string{"handoverpkg", "P2", "PRV2", "PRV"})
+
+	batch := `[{"pkgid": "P1", "reading": 20}, {"pkgid": "P2", "reading": 5}]`
+	var results []TempBatchResult
+	if err := json.Unmarshal(mustInvoke(t, stub, "updatetempbatch", batch, "PRV2"), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Code != E_FORBIDDEN || results[1].Seq != 1 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if packageinfo := getPkg(t, stub, "P1"); packageinfo.PkgStatus != STATUS_IN_TRANSIT || packageinfo.ReadingSeq != 0 {
+		t.Fatalf("reading of another carrier applied %+v", packageinfo)
+	}
+	if name, event := lastEvent(t, stub); name != EVENT_PKG_TEMP_READING || event.PkgId != "P2" || event.Actor != "PRV2" {
+		t.Fatalf("unexpected event %s %+v", name, event)
+	}
+}
+
+func TestUpdatetempbatchArguments(t *testing.T) {
+	stub := newTestStub(t, createP1)
+	_, err := stub.MockInvoke("updatetempbatch", `{"pkgid": "P1", "reading": 5}`)
+	checkCode(t, err, E_ARGS)
+	_, err = stub.MockInvoke("updatetempbatch")
+	checkCode(t, err, E_ARGS)
+	_, err = stub.MockInvoke("updatetempbatch", `[{"pkgid": "P1", "reading": 5}]`)
+	checkCode(t, err, E_ARGS)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	TempReading - A single temperature reading received for a package. Readings are stored under the composite key
+//				TempReading~PkgId~Seq so that the readings of one package can be range scanned in the order they
+//				were received.
+//==============================================================================================================================
+type TempReading struct {
+	PkgId     string      `json:"packageid"`
+	Seq       int         `json:"seq"`
+	Reading   Temperature `json:"reading"`
+	Unit      string      `json:"unit"`
+	SensorId  string      `json:"sensorid"`
+	Timestamp int64       `json:"timestamp"`
+	PkgStatus string      `json:"pkgstatus"`
+}
+
+const tempReadingObjectType = "TempReading"
+
+//==============================================================================================================================
+//	tempReadingKey - ledger key of the seq'th reading of a package. The sequence is zero padded so that the lexical
+//				order of the keys matches the order the readings were received in.
+//==============================================================================================================================
+func tempReadingKey(pkgId string, seq int) (string, error) {
+	return createCompositeKey(tempReadingObjectType, []string{pkgId, fmt.Sprintf("%010d", seq)})
+}
+
+//==============================================================================================================================
+//	getTxTimestamp - seconds since the epoch of the current transaction
+//==============================================================================================================================
+func getTxTimestamp(stub shim.ChaincodeStubInterface) (int64, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return 0, newError(E_INTERNAL, "Failed to get transaction timestamp")
+	}
+	if ts == nil {
+		return 0, nil
+	}
+	return ts.Seconds, nil
+}
+
+//==============================================================================================================================
+//	recordTempReading - applies a reading to the package, moving it to Pkg_Damaged and opening a claim when the
+//				reading damages it under the package excursion policy, and persists the reading with the resulting
+//				status. The reading is applied to a copy of the package, copied back only once nothing can fail, so
+//				that a reading that is not applied leaves the package as it was. The caller is responsible for
+//				writing the updated package back to the ledger.
+//==============================================================================================================================
+func recordTempReading(stub shim.ChaincodeStubInterface, original *PackageInfo, reading Temperature, sensorId string, timestamp int64) (TempReading, error) {
+	var tempreading TempReading
+
+	// readings are only accepted while the package is still moving through the lifecycle
+	if isTerminalStatus(original.PkgStatus) {
+		return tempreading, &IllegalTransitionError{From: original.PkgStatus, To: original.PkgStatus}
+	}
+
+	updated := *original
+	if original.Excursion != nil {
+		excursion := *original.Excursion
+		updated.Excursion = &excursion
+	}
+	packageinfo := &updated
+
+	damaged, reason, err := evaluateReading(packageinfo, reading, timestamp)
+	if err != nil {
+		return tempreading, err
+	}
+	if damaged {
+		err = checkTransition(packageinfo.PkgStatus, STATUS_PKG_DAMAGED)
+		if err != nil {
+			return tempreading, err
+		}
+		packageinfo.PkgStatus = STATUS_PKG_DAMAGED
+		packageinfo.DamageChannel = CHANNEL_TEMPERATURE
+	}
+
+	packageinfo.ReadingSeq++
+
+	tempreading.PkgId = packageinfo.PkgId
+	tempreading.Seq = packageinfo.ReadingSeq
+	tempreading.Reading = reading
+	tempreading.Unit = TEMP_UNIT_DECI_CELSIUS
+	tempreading.SensorId = sensorId
+	tempreading.Timestamp = timestamp
+	tempreading.PkgStatus = packageinfo.PkgStatus
+
+	// a reading that damages the package opens an insurance claim referencing it, the claim is opened first as it
+	// is the step that refuses readings
+	if damaged {
+		_, err = openClaim(stub, packageinfo, "system", reason, tempreading.Seq)
+		if err != nil {
+			return tempreading, err
+		}
+	}
+
+	key, err := tempReadingKey(tempreading.PkgId, tempreading.Seq)
+	if err != nil {
+		return tempreading, err
+	}
+
+	bytes, err := json.Marshal(&tempreading)
+	if err != nil {
+		fmt.Println("Could not marshal temprature reading object", err)
+		return tempreading, newError(E_INTERNAL, "Could not marshal temprature reading object")
+	}
+
+	err = stub.PutState(key, bytes)
+	if err != nil {
+		return tempreading, newError(E_INTERNAL, "Failed writing to blockchain for temprature reading "+key)
+	}
+
+	*original = updated
+	return tempreading, nil
+}
+
+//=================================================================================================================================
+//	querytemphistory - query function to read every temprature reading of a package in the order they were received,
+//				by Seq
+//=================================================================================================================================
+func (t *SimpleChaincode) querytemphistory(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	if len(args) < 1 || len(args) > 3 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId to query and optional PageSize and Bookmark")
+	}
+
+	opts, err := parseListOptions(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	startKey, endKey, err := compositeKeyRange(tempReadingObjectType, []string{args[0]})
+	if err != nil {
+		return nil, err
+	}
+
+	// the keys end in the zero padded Seq, so the readings come back in Seq order
+	items, bookmark, hasMore, err := scanRange(stub, startKey, endKey, opts, func(key string, valAsbytes []byte) (json.RawMessage, error) {
+		var tempreading TempReading
+		err := json.Unmarshal(valAsbytes, &tempreading)
+		if err != nil {
+			fmt.Println("Could not unmarshal temprature reading object", err)
+			return nil, newError(E_DECODE, "Could not unmarshal temprature reading object")
+		}
+		upgradeReadingTemps(&tempreading)
+		return json.Marshal(tempreading)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return listResult(opts, items, bookmark, hasMore)
+}
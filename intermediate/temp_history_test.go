@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestQuerytemphistory(t *testing.T) {
+	stub := newTestStub(t, createP1, []string{"acceptpkg", "P1", "PRV"},
+		[]string{"updatetemp", "P1", "4", "PRV", "S1"},
+		[]string{"updatetemp", "P1", "5.5", "PRV"},
+		[]string{"updatetemp", "P1", "50F", "PRV", "S2"},
+	)
+
+	// a reading stored in whole degrees before readings had a unit
+	key, _ := tempReadingKey("P1", 4)
+	stub.State[key] = []byte(`{"packageid": "P1", "seq": 4, "reading": 7, "sensorid": "old", "pkgstatus": "In_Transit"}`)
+
+	var readings []TempReading
+	if err := json.Unmarshal(mustQuery(t, stub, "querytemphistory", "P1"), &readings); err != nil {
+		t.Fatal(err)
+	}
+	want := []Temperature{40, 55, 100, 70}
+	if len(readings) != len(want) {
+		t.Fatalf("unexpected readings %+v", readings)
+	}
+	for i, reading := range readings {
+		if reading.Seq != i+1 || reading.Reading != want[i] || reading.Unit != TEMP_UNIT_DECI_CELSIUS {
+			t.Errorf("reading %d = %+v, want %s", i+1, reading, want[i])
+		}
+	}
+	if readings[2].PkgStatus != STATUS_PKG_DAMAGED || readings[2].SensorId != "S2" {
+		t.Fatalf("unexpected damaging reading %+v", readings[2])
+	}
+
+	var page ListPage
+	if err := json.Unmarshal(mustQuery(t, stub, "querytemphistory", "P1", "3"), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 3 || !page.HasMore {
+		t.Fatalf("unexpected page %+v", page)
+	}
+
+	_, err := stub.MockInvoke("updatetemp", "P1", "5", "PRV")
+	checkCode(t, err, E_ILLEGAL_STATE)
+	_, err = stub.MockQuery("querytemphistory")
+	checkCode(t, err, E_ARGS)
+}
+
+func TestQuerytemphistoryOrder(t *testing.T) {
+	stub := newTestStub(t, createP1, []string{"acceptpkg", "P1", "PRV"})
+	for i := 0; i < 12; i++ {
+		mustInvoke(t, stub, "updatetemp", "P1", "5", "PRV", "S1")
+	}
+
+	// pages of 5 return the readings by Seq, past the ninth where the number of digits changes
+	var seqs []int
+	bookmark := ""
+	for {
+		var page ListPage
+		if err := json.Unmarshal(mustQuery(t, stub, "querytemphistory", "P1", "5", bookmark), &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range page.Items {
+			var reading TempReading
+			if err := json.Unmarshal(item, &reading); err != nil {
+				t.Fatal(err)
+			}
+			seqs = append(seqs, reading.Seq)
+		}
+		if !page.HasMore {
+			break
+		}
+		bookmark = page.Bookmark
+	}
+
+	if len(seqs) != 12 {
+		t.Fatalf("unexpected readings %v", seqs)
+	}
+	for i, seq := range seqs {
+		if seq != i+1 {
+			t.Fatalf("readings out of order %v", seqs)
+		}
+	}
+}
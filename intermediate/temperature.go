@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//==============================================================================================================================
+//	Temperature - A temprature in tenths of a degree Celsius, the canonical unit every temprature is stored and compared
+//				in. Records written with this unit carry TEMP_UNIT_DECI_CELSIUS; records without a unit were written
+//				with whole degrees Celsius and are converted when they are read.
+//==============================================================================================================================
+type Temperature int
+
+const TEMP_UNIT_DECI_CELSIUS = "0.1C"
+
+//==============================================================================================================================
+//	tempFromDegrees - converts whole degrees Celsius of a legacy record
+//==============================================================================================================================
+func tempFromDegrees(degrees Temperature) Temperature {
+	return degrees * 10
+}
+
+//==============================================================================================================================
+//	parseTemperature - parses a temprature with at most one decimal and an optional unit, C (the default) or F,
+//				e.g. 4, 4.5, 4.5C, -20C or 40.1F. Fahrenheit is converted to Celsius rounding to the nearest tenth.
+//==============================================================================================================================
+func parseTemperature(value string) (Temperature, error) {
+	invalid := newError(E_ARGS, "Invalid temprature "+value+", expecting a value such as 4.5, 4.5C or 40.1F")
+
+	text := strings.TrimSpace(value)
+	fahrenheit := false
+	if strings.HasSuffix(text, "F") || strings.HasSuffix(text, "f") {
+		fahrenheit = true
+		text = text[:len(text)-1]
+	} else if strings.HasSuffix(text, "C") || strings.HasSuffix(text, "c") {
+		text = text[:len(text)-1]
+	}
+	text = strings.TrimSuffix(text, "°")
+
+	tenths, err := parseTenths(text)
+	if err != nil {
+		return 0, invalid
+	}
+
+	if fahrenheit {
+		return fahrenheitToCelsius(tenths), nil
+	}
+	return Temperature(tenths), nil
+}
+
+//==============================================================================================================================
+//	parseTenths - parses a number with at most one decimal place, e.g. 4 or -4.5, into tenths
+//==============================================================================================================================
+func parseTenths(text string) (int, error) {
+	invalid := newError(E_ARGS, "Invalid value "+text+", expecting a number with at most one decimal place such as 4 or 4.5")
+
+	negative := strings.HasPrefix(text, "-")
+	if negative {
+		text = text[1:]
+	}
+
+	whole, fraction := text, "0"
+	if i := strings.Index(text, "."); i >= 0 {
+		whole, fraction = text[:i], text[i+1:]
+	}
+	if whole == "" || len(whole) > 6 || len(fraction) != 1 || !isDigits(whole) || !isDigits(fraction) {
+		return 0, invalid
+	}
+
+	units, err := strconv.Atoi(whole)
+	if err != nil {
+		return 0, invalid
+	}
+	tenths := units*10 + int(fraction[0]-'0')
+	if negative {
+		tenths = -tenths
+	}
+	return tenths, nil
+}
+
+//==============================================================================================================================
+//	formatTenths - formats tenths as a decimal, e.g. -4.5
+//==============================================================================================================================
+func formatTenths(tenths int) string {
+	sign := ""
+	if tenths < 0 {
+		sign = "-"
+		tenths = -tenths
+	}
+	return fmt.Sprintf("%s%d.%d", sign, tenths/10, tenths%10)
+}
+
+//==============================================================================================================================
+//	fahrenheitToCelsius - converts tenths of a degree Fahrenheit, rounding half away from zero
+//==============================================================================================================================
+func fahrenheitToCelsius(tenths int) Temperature {
+	scaled := (tenths - 320) * 5
+	if scaled < 0 {
+		return Temperature(-((-scaled + 4) / 9))
+	}
+	return Temperature((scaled + 4) / 9)
+}
+
+//==============================================================================================================================
+//	isDigits - true if text is made of ASCII digits only
+//==============================================================================================================================
+func isDigits(text string) bool {
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//==============================================================================================================================
+//	String - the temprature in degrees Celsius, e.g. 4.5C
+//==============================================================================================================================
+func (t Temperature) String() string {
+	return formatTenths(int(t)) + "C"
+}
+
+//==============================================================================================================================
+//	Degrees - the temprature in degrees Celsius
+//==============================================================================================================================
+func (t Temperature) Degrees() float64 {
+	return float64(t) / 10
+}
+
+//==============================================================================================================================
+//	TempValue - A temprature as given in JSON input, either a number in degrees Celsius or a string accepted by
+//				parseTemperature
+//==============================================================================================================================
+type TempValue string
+
+func (v *TempValue) UnmarshalJSON(data []byte) error {
+	var text string
+	if json.Unmarshal(data, &text) == nil {
+		*v = TempValue(text)
+		return nil
+	}
+
+	var number json.Number
+	err := json.Unmarshal(data, &number)
+	if err != nil {
+		return err
+	}
+	*v = TempValue(number.String())
+	return nil
+}
+
+//==============================================================================================================================
+//	upgradePackageTemps - converts the tempratures of a package record written before TempUnit existed
+//==============================================================================================================================
+func upgradePackageTemps(packageinfo *PackageInfo) {
+	if packageinfo.TempUnit != "" {
+		return
+	}
+	packageinfo.TempratureMin = tempFromDegrees(packageinfo.TempratureMin)
+	packageinfo.TempratureMax = tempFromDegrees(packageinfo.TempratureMax)
+	upgradePolicyTemps(packageinfo.ExcursionPolicy)
+	packageinfo.TempUnit = TEMP_UNIT_DECI_CELSIUS
+}
+
+//==============================================================================================================================
+//	upgradeTemplateTemps - converts the tempratures of a template record written before TempUnit existed
+//==============================================================================================================================
+func upgradeTemplateTemps(template *ProductTemplate) {
+	if template.TempUnit != "" {
+		return
+	}
+	template.TempratureMin = tempFromDegrees(template.TempratureMin)
+	template.TempratureMax = tempFromDegrees(template.TempratureMax)
+	upgradePolicyTemps(template.ExcursionPolicy)
+	template.TempUnit = TEMP_UNIT_DECI_CELSIUS
+}
+
+//==============================================================================================================================
+//	upgradePolicyTemps - converts the hard limits of an excursion policy stored in a legacy record
+//==============================================================================================================================
+func upgradePolicyTemps(policy *ExcursionPolicy) {
+	if policy == nil {
+		return
+	}
+	policy.HardMin = tempFromDegrees(policy.HardMin)
+	policy.HardMax = tempFromDegrees(policy.HardMax)
+}
+
+//==============================================================================================================================
+//	upgradeReadingTemps - converts a temprature reading written before Unit existed
+//==============================================================================================================================
+func upgradeReadingTemps(tempreading *TempReading) {
+	if tempreading.Unit != "" {
+		return
+	}
+	tempreading.Reading = tempFromDegrees(tempreading.Reading)
+	tempreading.Unit = TEMP_UNIT_DECI_CELSIUS
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseTemperature(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Temperature
+		ok    bool
+	}{
+		{"4", 40, true},
+		{"4.5", 45, true},
+		{"4.5C", 45, true},
+		{"-20C", -200, true},
+		{"-0.5", -5, true},
+		{" 8c ", 80, true},
+		{"40.1F", 45, true},
+		{"32F", 0, true},
+		{"-40F", -400, true},
+		{"33.1F", 6, true},
+		{"4.55", 0, false},
+		{"4.", 0, false},
+		{".5", 0, false},
+		{"", 0, false},
+		{"F", 0, false},
+		{"4K", 0, false},
+		{"--4", 0, false},
+		{"1e2", 0, false},
+	}
+	for _, test := range tests {
+		got, err := parseTemperature(test.value)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("parseTemperature(%q) = %d, %v", test.value, got, err)
+		}
+		if err != nil && errorCode(err) != E_ARGS {
+			t.Errorf("parseTemperature(%q) has code %s", test.value, errorCode(err))
+		}
+	}
+}
+
+func TestTemperatureString(t *testing.T) {
+	for value, want := range map[Temperature]string{45: "4.5C", -5: "-0.5C", 0: "0.0C", -200: "-20.0C"} {
+		if got := value.String(); got != want {
+			t.Errorf("Temperature(%d).String() = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestTempValue(t *testing.T) {
+	var items []TempBatchItem
+	if err := json.Unmarshal([]byte(`[{"reading": 4.5}, {"reading": "40.1F"}, {"reading": -3}]`), &items); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []TempValue{"4.5", "40.1F", "-3"} {
+		if items[i].Reading != want {
+			t.Errorf("reading %d = %q, want %q", i, items[i].Reading, want)
+		}
+	}
+	if err := json.Unmarshal([]byte(`[{"reading": true}]`), &items); err == nil {
+		t.Error("expected a boolean reading to fail")
+	}
+}
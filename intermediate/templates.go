@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	ProductTemplate - A product type in the on-chain catalog with the temprature range, excursion policy and handling
+//				notes packages of that type are created with. Every puttemplate writes a new version; the latest
+//				version is stored under Template~TemplateId and every version under
+//				TemplateVersion~TemplateId~Version. Packages keep the values of the version they were created from.
+//==============================================================================================================================
+type ProductTemplate struct {
+	TemplateId      string           `json:"templateid"`
+	Version         int              `json:"version"`
+	TempratureMin   Temperature      `json:"Tempraturemin"`
+	TempratureMax   Temperature      `json:"Tempraturemax"`
+	TempUnit        string           `json:"tempunit"`
+	ExcursionPolicy *ExcursionPolicy `json:"excursionpolicy,omitempty"`
+	HandlingNotes   string           `json:"handlingnotes"`
+	UpdatedAt       int64            `json:"updatedat"`
+}
+
+const templateObjectType = "Template"
+const templateVersionObjectType = "TemplateVersion"
+
+//==============================================================================================================================
+//	templateKey - ledger key of the latest version of a template
+//==============================================================================================================================
+func templateKey(templateId string) (string, error) {
+	return createCompositeKey(templateObjectType, []string{templateId})
+}
+
+//==============================================================================================================================
+//	templateVersionKey - ledger key of one version of a template. The version is zero padded so that the versions of
+//				a template are range scanned in order.
+//==============================================================================================================================
+func templateVersionKey(templateId string, version int) (string, error) {
+	return createCompositeKey(templateVersionObjectType, []string{templateId, fmt.Sprintf("%06d", version)})
+}
+
+//==============================================================================================================================
+//	getTemplate - reads the latest version of a template, or the given version when version is not 0. Returns nil if
+//				the template or version does not exist.
+//==============================================================================================================================
+func getTemplate(stub shim.ChaincodeStubInterface, templateId string, version int) (*ProductTemplate, error) {
+	var key string
+	var err error
+
+	if version == 0 {
+		key, err = templateKey(templateId)
+	} else {
+		key, err = templateVersionKey(templateId, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	valAsbytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, newError(E_INTERNAL, "Failed to get state for template "+templateId)
+	}
+	if valAsbytes == nil {
+		return nil, nil
+	}
+
+	var template ProductTemplate
+	err = json.Unmarshal(valAsbytes, &template)
+	if err != nil {
+		fmt.Println("Could not unmarshal template object", err)
+		return nil, newError(E_DECODE, "Could not unmarshal template object "+templateId)
+	}
+	upgradeTemplateTemps(&template)
+	return &template, nil
+}
+
+//==============================================================================================================================
+//	putTemplate - writes a template version both as the latest version and under its version key
+//==============================================================================================================================
+func putTemplate(stub shim.ChaincodeStubInterface, template *ProductTemplate) error {
+	template.TempUnit = TEMP_UNIT_DECI_CELSIUS
+
+	bytes, err := json.Marshal(template)
+	if err != nil {
+		fmt.Println("Could not marshal template object", err)
+		return newError(E_INTERNAL, "Could not marshal template object "+template.TemplateId)
+	}
+
+	latestKey, err := templateKey(template.TemplateId)
+	if err != nil {
+		return err
+	}
+	versionKey, err := templateVersionKey(template.TemplateId, template.Version)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range []string{latestKey, versionKey} {
+		err = stub.PutState(key, bytes)
+		if err != nil {
+			return newError(E_INTERNAL, "Failed writing to blockchain for template "+template.TemplateId)
+		}
+	}
+	return nil
+}
+
+//=================================================================================================================================
+//	puttemplate - add a product template to the catalog, or a new version of an existing one. Expects TemplateId,
+//				TempratureMin, TempratureMax, HandlingNotes and optional MaxCumulativeSecs, MaxSingleSecs, HardMin,
+//				HardMax for the excursion policy. Returns the template written.
+//=================================================================================================================================
+func (t *SimpleChaincode) puttemplate(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running puttemplate()")
+
+	if len(args) != 4 && len(args) != 8 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting TemplateId, TempratureMin, TempratureMax, HandlingNotes and optional MaxCumulativeSecs, MaxSingleSecs, HardMin, HardMax")
+	}
+
+	if args[0] == "" {
+		return nil, newError(E_ARGS, "TemplateId must not be empty")
+	}
+
+	var template ProductTemplate
+	var err error
+
+	template.TemplateId = args[0]
+	template.TempratureMin, err = parseTemperature(args[1])
+	if err != nil {
+		return nil, newError(E_ARGS, "TempratureMin must be a temprature such as 4.5, 4.5C or 40.1F")
+	}
+	template.TempratureMax, err = parseTemperature(args[2])
+	if err != nil {
+		return nil, newError(E_ARGS, "TempratureMax must be a temprature such as 4.5, 4.5C or 40.1F")
+	}
+	if template.TempratureMin > template.TempratureMax {
+		return nil, newError(E_ARGS, "TempratureMin must not be greater than TempratureMax")
+	}
+	template.HandlingNotes = args[3]
+
+	if len(args) == 8 {
+		policy, err := parseExcursionPolicy(args[4:8], template.TempratureMin, template.TempratureMax)
+		if err != nil {
+			return nil, err
+		}
+		template.ExcursionPolicy = &policy
+	}
+
+	latest, err := getTemplate(stub, template.TemplateId, 0)
+	if err != nil {
+		return nil, err
+	}
+	template.Version = 1
+	if latest != nil {
+		template.Version = latest.Version + 1
+	}
+
+	template.UpdatedAt, err = getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putTemplate(stub, &template)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(template)
+}
+
+//=================================================================================================================================
+//	createfromtemplate - create a new package with the temprature range and excursion policy of a product template.
+//				Expects PkgId, Shipper, Insurer, Consignee, TemplateId, PackageDes, Provider and an optional
+//				template version, the latest version is used if it is not given.
+//=================================================================================================================================
+func (t *SimpleChaincode) createfromtemplate(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running createfromtemplate()")
+
+	if len(args) != 7 && len(args) != 8 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting PkgId, Shipper, Insurer, Consignee, TemplateId, PackageDes, Provider and optional TemplateVersion")
+	}
+
+	// in secure mode a Shipper can only create packages in its own name
+	err := authorizeParty(stub, args, 1, args[1])
+	if err != nil {
+		return nil, wrapError(E_FORBIDDEN, "Wrong Shipper - Can not create a package for another Shipper", err)
+	}
+
+	version := 0
+	if len(args) == 8 {
+		version, err = strconv.Atoi(args[7])
+		if err != nil || version < 1 {
+			return nil, newError(E_ARGS, "TemplateVersion must be a positive numeric string")
+		}
+	}
+
+	template, err := getTemplate(stub, args[4], version)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		message := "Invalid TemplateId Passed " + args[4]
+		if version != 0 {
+			message += " version " + args[7]
+		}
+		return nil, newError(E_NOT_FOUND, message)
+	}
+
+	exists, err := packageExists(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, newError(E_DUPLICATE, "Package already present on blockchain "+args[0])
+	}
+
+	var packageinfo PackageInfo
+
+	packageinfo.PkgId = args[0]
+	packageinfo.Shipper = args[1]
+	packageinfo.Insurer = args[2]
+	packageinfo.Consignee = args[3]
+	packageinfo.TempratureMin = template.TempratureMin
+	packageinfo.TempratureMax = template.TempratureMax
+	packageinfo.ExcursionPolicy = template.ExcursionPolicy
+	packageinfo.PackageDes = args[5]
+	packageinfo.Provider = args[6]
+	packageinfo.PkgStatus = STATUS_LABEL_GENERATED
+	packageinfo.TemplateId = template.TemplateId
+	packageinfo.TemplateVersion = template.Version
+
+	timestamp, err := getTxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putPackageInfo(stub, &packageinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, emitPkgEvents(stub, []PkgEvent{{Event: EVENT_PKG_CREATED, PkgId: packageinfo.PkgId, NewStatus: packageinfo.PkgStatus, Actor: eventActor(stub, packageinfo.Shipper), Timestamp: timestamp}})
+}
+
+//=================================================================================================================================
+//	querytemplate - query function to read the latest version of a template, or the given version
+//=================================================================================================================================
+func (t *SimpleChaincode) querytemplate(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	if len(args) != 1 && len(args) != 2 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting TemplateId and optional Version")
+	}
+
+	version := 0
+	if len(args) == 2 {
+		var err error
+		version, err = strconv.Atoi(args[1])
+		if err != nil || version < 1 {
+			return nil, newError(E_ARGS, "Version must be a positive numeric string")
+		}
+	}
+
+	template, err := getTemplate(stub, args[0], version)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, newError(E_NOT_FOUND, "Invalid TemplateId Passed "+args[0])
+	}
+	return json.Marshal(template)
+}
+
+//=================================================================================================================================
+//	querytemplates - query function to list the latest version of every template in the catalog, with optional
+//				PageSize and Bookmark
+//=================================================================================================================================
+func (t *SimpleChaincode) querytemplates(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	if len(args) > 2 {
+		return nil, newError(E_ARGS, "Incorrect number of arguments. Expecting optional PageSize and Bookmark")
+	}
+
+	opts, err := parseListOptions(args)
+	if err != nil {
+		return nil, err
+	}
+
+	startKey, endKey, err := compositeKeyRange(templateObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	items, bookmark, hasMore, err := scanRange(stub, startKey, endKey, opts, func(key string, valAsbytes []byte) (json.RawMessage, error) {
+		var template ProductTemplate
+		err := json.Unmarshal(valAsbytes, &template)
+		if err != nil {
+			fmt.Println("Could not unmarshal template object", err)
+			return nil, newError(E_DECODE, "Could not unmarshal template object")
+		}
+		upgradeTemplateTemps(&template)
+		return json.Marshal(template)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return listResult(opts, items, bookmark, hasMore)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+var (
+	putT1      = []string{"puttemplate", "T1", "2", "8", "keep cold"}
+	putT1Again = []string{"puttemplate", "T1", "-20", "-15", "keep frozen", "600", "300", "-25", "-10"}
+)
+
+func getTemplateResult(t *testing.T, stub *mockstub.MockStub, args ...string) ProductTemplate {
+	t.Helper()
+	var template ProductTemplate
+	if err := json.Unmarshal(mustQuery(t, stub, "querytemplate", args...), &template); err != nil {
+		t.Fatal(err)
+	}
+	return template
+}
+
+func TestPuttemplate(t *testing.T) {
+	runInvokeTests(t, []invokeTest{
+		{name: "first version", function: putT1[0], args: putT1[1:],
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if template := getTemplateResult(t, stub, "T1"); template.Version != 1 || template.TempratureMin != 20 || template.HandlingNotes != "keep cold" || template.ExcursionPolicy != nil {
+					t.Fatalf("unexpected template %+v", template)
+				}
+			}},
+		{name: "new version with policy", setup: [][]string{putT1}, function: putT1Again[0], args: putT1Again[1:],
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if template := getTemplateResult(t, stub, "T1"); template.Version != 2 || template.ExcursionPolicy == nil || template.ExcursionPolicy.HardMin != -250 {
+					t.Fatalf("unexpected template %+v", template)
+				}
+				if template := getTemplateResult(t, stub, "T1", "1"); template.Version != 1 || template.TempratureMax != 80 {
+					t.Fatalf("version 1 changed to %+v", template)
+				}
+			}},
+		{name: "empty id", function: "puttemplate", args: []string{"", "2", "8", "notes"}, code: E_ARGS},
+		{name: "min above max", function: "puttemplate", args: []string{"T1", "8", "2", "notes"}, code: E_ARGS},
+		{name: "bad temperature", function: "puttemplate", args: []string{"T1", "2", "hot", "notes"}, code: E_ARGS},
+		{name: "hard limits inside range", function: "puttemplate", args: []string{"T1", "2", "8", "notes", "600", "300", "3", "10"}, code: E_ARGS},
+		{name: "partial policy", function: "puttemplate", args: []string{"T1", "2", "8", "notes", "600"}, code: E_ARGS},
+	})
+}
+
+func TestCreatefromtemplate(t *testing.T) {
+	catalog := [][]string{putT1, putT1Again}
+	runInvokeTests(t, []invokeTest{
+		{name: "latest version", setup: catalog, function: "createfromtemplate", args: []string{"P1", "SHP", "INS", "CON", "T1", "fish", "PRV"}, status: STATUS_LABEL_GENERATED,
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				packageinfo := getPkg(t, stub, "P1")
+				if packageinfo.TemplateVersion != 2 || packageinfo.TempratureMax != -150 || packageinfo.ExcursionPolicy == nil {
+					t.Fatalf("unexpected package %+v", packageinfo)
+				}
+				if name, _ := lastEvent(t, stub); name != EVENT_PKG_CREATED {
+					t.Fatalf("unexpected event %s", name)
+				}
+			}},
+		{name: "pinned version", setup: catalog, function: "createfromtemplate", args: []string{"P1", "SHP", "INS", "CON", "T1", "vaccine", "PRV", "1"},
+			check: func(t *testing.T, stub *mockstub.MockStub) {
+				if packageinfo := getPkg(t, stub, "P1"); packageinfo.TemplateVersion != 1 || packageinfo.TempratureMax != 80 {
+					t.Fatalf("unexpected package %+v", packageinfo)
+				}
+			}},
+		{name: "unknown template", function: "createfromtemplate", args: []string{"P1", "SHP", "INS", "CON", "T9", "vaccine", "PRV"}, code: E_NOT_FOUND},
+		{name: "unknown version", setup: catalog, function: "createfromtemplate", args: []string{"P1", "SHP", "INS", "CON", "T1", "vaccine", "PRV", "3"}, code: E_NOT_FOUND},
+		{name: "bad version", setup: catalog, function: "createfromtemplate", args: []string{"P1", "SHP", "INS", "CON", "T1", "vaccine", "PRV", "latest"}, code: E_ARGS},
+		{name: "duplicate package", setup: then(catalog, createP1), function: "createfromtemplate", args: []string{"P1", "SHP", "INS", "CON", "T1", "vaccine", "PRV"}, code: E_DUPLICATE},
+	})
+}
+
+func TestQuerytemplates(t *testing.T) {
+	stub := newTestStub(t, putT1, putT1Again, []string{"puttemplate", "T2", "15", "25", "room temperature"})
+
+	var templates []ProductTemplate
+	if err := json.Unmarshal(mustQuery(t, stub, "querytemplates"), &templates); err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 2 || templates[0].TemplateId != "T1" || templates[0].Version != 2 || templates[1].TemplateId != "T2" {
+		t.Fatalf("unexpected templates %+v", templates)
+	}
+
+	_, err := stub.MockQuery("querytemplate", "T9")
+	checkCode(t, err, E_NOT_FOUND)
+	_, err = stub.MockQuery("querytemplate", "T1", "0")
+	checkCode(t, err, E_ARGS)
+	_, err = stub.MockQuery("querytemplates", "1", "", "x")
+	checkCode(t, err, E_ARGS)
+}
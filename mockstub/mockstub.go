@@ -0,0 +1,475 @@
+// Package mockstub is an in-memory implementation of shim.ChaincodeStubInterface for the Fabric v0.6 shim, so
+// chaincode in this repository can be exercised from go test without a peer.
+//
+// Every MockInit and MockInvoke runs as a transaction: writes are buffered and only committed to State when the
+// chaincode returns without an error, as a peer would. MockQuery runs read only. Each transaction gets its own
+// transaction id and a timestamp taken from Time, which then advances by Step. The caller of a transaction is the
+// certificate in Caller together with the certificate attributes in Attributes.
+//
+// Composite keys need no support from the stub: chaincode builds them as plain "\x00"-separated strings.
+// RangeQueryState behaves as the v0.6 peer does: the end key is included and the keys come back in no particular
+// order, shuffled on every call, so chaincode that relies on key order fails its tests as it would on a peer.
+package mockstub
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	mrand "math/rand"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/crypto/attr"
+)
+
+// DefaultTime is the timestamp of the first transaction of a new MockStub.
+var DefaultTime = time.Date(2017, time.April, 26, 0, 0, 0, 0, time.UTC)
+
+// Event is a chaincode event set by a committed transaction.
+type Event struct {
+	TxID    string
+	Name    string
+	Payload []byte
+}
+
+// MockStub runs a chaincode against an in-memory world state.
+type MockStub struct {
+	// Name is the name other mock chaincodes use to reach this one through InvokeChaincode and QueryChaincode.
+	Name string
+
+	// State is the committed world state.
+	State map[string][]byte
+
+	// Events holds the event of every committed transaction that set one, in commit order.
+	Events []Event
+
+	// Time is the timestamp of the next transaction, Step is added to it after every MockInit and MockInvoke.
+	Time time.Time
+	Step time.Duration
+
+	// Caller is the certificate returned by GetCallerCertificate, Attributes the attributes read from it.
+	Caller     []byte
+	Attributes map[string][]byte
+
+	// Metadata is returned by GetCallerMetadata.
+	Metadata []byte
+
+	cc         shim.Chaincode
+	chaincodes map[string]*MockStub
+	txSeq      int
+	tx         *transaction
+}
+
+// transaction is the state of the transaction being run by a MockStub.
+type transaction struct {
+	id        string
+	args      []string
+	timestamp time.Time
+	readOnly  bool
+	writes    map[string][]byte
+	deletes   map[string]bool
+	event     *Event
+}
+
+// NewMockStub returns a MockStub named name that runs cc.
+func NewMockStub(name string, cc shim.Chaincode) *MockStub {
+	return &MockStub{
+		Name:       name,
+		State:      map[string][]byte{},
+		Time:       DefaultTime,
+		Step:       time.Second,
+		Attributes: map[string][]byte{},
+		cc:         cc,
+		chaincodes: map[string]*MockStub{},
+	}
+}
+
+// MockInit deploys the chaincode by calling its Init function in a new transaction.
+func (stub *MockStub) MockInit(function string, args ...string) ([]byte, error) {
+	return stub.run(false, function, args, stub.cc.Init)
+}
+
+// MockInvoke calls the chaincode's Invoke function in a new transaction.
+func (stub *MockStub) MockInvoke(function string, args ...string) ([]byte, error) {
+	return stub.run(false, function, args, stub.cc.Invoke)
+}
+
+// MockQuery calls the chaincode's Query function. Queries see the committed state and may not write to it.
+func (stub *MockStub) MockQuery(function string, args ...string) ([]byte, error) {
+	return stub.run(true, function, args, stub.cc.Query)
+}
+
+// run calls fn in a new transaction and commits its writes and event if it succeeds.
+func (stub *MockStub) run(readOnly bool, function string, args []string, fn func(shim.ChaincodeStubInterface, string, []string) ([]byte, error)) ([]byte, error) {
+	if stub.tx != nil {
+		return nil, errors.New("mockstub: " + stub.Name + " is already running transaction " + stub.tx.id)
+	}
+
+	stub.txSeq++
+	stub.tx = &transaction{
+		id:        fmt.Sprintf("%s-tx%d", stub.Name, stub.txSeq),
+		args:      append([]string{function}, args...),
+		timestamp: stub.Time,
+		readOnly:  readOnly,
+		writes:    map[string][]byte{},
+		deletes:   map[string]bool{},
+	}
+	defer func() { stub.tx = nil }()
+	if !readOnly {
+		stub.Time = stub.Time.Add(stub.Step)
+	}
+
+	result, err := fn(stub, function, args)
+	if err != nil {
+		return result, err
+	}
+
+	for key := range stub.tx.deletes {
+		delete(stub.State, key)
+	}
+	for key, value := range stub.tx.writes {
+		stub.State[key] = value
+	}
+	if stub.tx.event != nil {
+		stub.Events = append(stub.Events, *stub.tx.event)
+	}
+	return result, nil
+}
+
+// SetCaller makes commonName the caller of the following transactions and clears Attributes.
+func (stub *MockStub) SetCaller(commonName string) {
+	stub.Caller = Certificate(commonName)
+	stub.Attributes = map[string][]byte{}
+}
+
+// LastEvent returns the event of the most recent committed transaction that set one.
+func (stub *MockStub) LastEvent() (Event, bool) {
+	if len(stub.Events) == 0 {
+		return Event{}, false
+	}
+	return stub.Events[len(stub.Events)-1], true
+}
+
+// RegisterChaincode makes other reachable from this stub's chaincode through InvokeChaincode and QueryChaincode
+// under other.Name.
+func (stub *MockStub) RegisterChaincode(other *MockStub) {
+	stub.chaincodes[other.Name] = other
+}
+
+// Certificate returns a self-signed DER encoded certificate whose subject common name is commonName.
+func Certificate(commonName string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    DefaultTime,
+		NotAfter:     DefaultTime.AddDate(100, 0, 0),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	return certificate
+}
+
+// current returns the running transaction, or an error if the chaincode is called outside of one.
+func (stub *MockStub) current() (*transaction, error) {
+	if stub.tx == nil {
+		return nil, errors.New("mockstub: no transaction is running")
+	}
+	return stub.tx, nil
+}
+
+//------------------------------------------------------------------------------------------------------------------------------
+// shim.ChaincodeStubInterface
+//------------------------------------------------------------------------------------------------------------------------------
+
+func (stub *MockStub) GetArgs() [][]byte {
+	if stub.tx == nil {
+		return nil
+	}
+	args := make([][]byte, len(stub.tx.args))
+	for i, arg := range stub.tx.args {
+		args[i] = []byte(arg)
+	}
+	return args
+}
+
+func (stub *MockStub) GetStringArgs() []string {
+	if stub.tx == nil {
+		return nil
+	}
+	return append([]string(nil), stub.tx.args...)
+}
+
+func (stub *MockStub) GetTxID() string {
+	if stub.tx == nil {
+		return ""
+	}
+	return stub.tx.id
+}
+
+// InvokeChaincode invokes a chaincode registered with RegisterChaincode, args[0] is the function to call.
+func (stub *MockStub) InvokeChaincode(chaincodeName string, args [][]byte) ([]byte, error) {
+	other, function, rest, err := stub.target(chaincodeName, args)
+	if err != nil {
+		return nil, err
+	}
+	return other.MockInvoke(function, rest...)
+}
+
+// QueryChaincode queries a chaincode registered with RegisterChaincode, args[0] is the function to call.
+func (stub *MockStub) QueryChaincode(chaincodeName string, args [][]byte) ([]byte, error) {
+	other, function, rest, err := stub.target(chaincodeName, args)
+	if err != nil {
+		return nil, err
+	}
+	return other.MockQuery(function, rest...)
+}
+
+func (stub *MockStub) target(chaincodeName string, args [][]byte) (*MockStub, string, []string, error) {
+	other, ok := stub.chaincodes[chaincodeName]
+	if !ok {
+		return nil, "", nil, errors.New("mockstub: unknown chaincode " + chaincodeName)
+	}
+	if len(args) == 0 {
+		return nil, "", nil, errors.New("mockstub: no function passed to " + chaincodeName)
+	}
+	rest := make([]string, len(args)-1)
+	for i, arg := range args[1:] {
+		rest[i] = string(arg)
+	}
+	return other, string(args[0]), rest, nil
+}
+
+// GetState returns the value of key as written by the running transaction or, if it did not write it, as committed.
+func (stub *MockStub) GetState(key string) ([]byte, error) {
+	tx, err := stub.current()
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := tx.writes[key]; ok {
+		return value, nil
+	}
+	if tx.deletes[key] {
+		return nil, nil
+	}
+	return stub.State[key], nil
+}
+
+func (stub *MockStub) PutState(key string, value []byte) error {
+	tx, err := stub.writable(key)
+	if err != nil {
+		return err
+	}
+	delete(tx.deletes, key)
+	tx.writes[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (stub *MockStub) DelState(key string) error {
+	tx, err := stub.writable(key)
+	if err != nil {
+		return err
+	}
+	delete(tx.writes, key)
+	tx.deletes[key] = true
+	return nil
+}
+
+func (stub *MockStub) writable(key string) (*transaction, error) {
+	tx, err := stub.current()
+	if err != nil {
+		return nil, err
+	}
+	if tx.readOnly {
+		return nil, errors.New("mockstub: queries can not write " + key)
+	}
+	if key == "" {
+		return nil, errors.New("mockstub: key must not be empty")
+	}
+	return tx, nil
+}
+
+// RangeQueryState returns the keys in [startKey, endKey] in random order, an empty endKey has no upper bound. Writes
+// of the running transaction are visible to the scan.
+func (stub *MockStub) RangeQueryState(startKey, endKey string) (shim.StateRangeQueryIteratorInterface, error) {
+	tx, err := stub.current()
+	if err != nil {
+		return nil, err
+	}
+
+	inRange := func(key string) bool {
+		return key >= startKey && (endKey == "" || key <= endKey)
+	}
+
+	values := map[string][]byte{}
+	for key, value := range stub.State {
+		if inRange(key) && !tx.deletes[key] {
+			values[key] = value
+		}
+	}
+	for key, value := range tx.writes {
+		if inRange(key) {
+			values[key] = value
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	mrand.Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+	return &rangeIterator{keys: keys, values: values}, nil
+}
+
+var errTables = errors.New("mockstub: tables are not supported")
+
+func (stub *MockStub) CreateTable(name string, columnDefinitions []*shim.ColumnDefinition) error {
+	return errTables
+}
+
+func (stub *MockStub) GetTable(tableName string) (*shim.Table, error) {
+	return nil, errTables
+}
+
+func (stub *MockStub) DeleteTable(tableName string) error {
+	return errTables
+}
+
+func (stub *MockStub) InsertRow(tableName string, row shim.Row) (bool, error) {
+	return false, errTables
+}
+
+func (stub *MockStub) ReplaceRow(tableName string, row shim.Row) (bool, error) {
+	return false, errTables
+}
+
+func (stub *MockStub) GetRow(tableName string, key []shim.Column) (shim.Row, error) {
+	return shim.Row{}, errTables
+}
+
+func (stub *MockStub) GetRows(tableName string, key []shim.Column) (<-chan shim.Row, error) {
+	return nil, errTables
+}
+
+func (stub *MockStub) DeleteRow(tableName string, key []shim.Column) error {
+	return errTables
+}
+
+func (stub *MockStub) ReadCertAttribute(attributeName string) ([]byte, error) {
+	value, ok := stub.Attributes[attributeName]
+	if !ok {
+		return nil, errors.New("mockstub: caller certificate has no attribute " + attributeName)
+	}
+	return value, nil
+}
+
+func (stub *MockStub) VerifyAttribute(attributeName string, attributeValue []byte) (bool, error) {
+	value, err := stub.ReadCertAttribute(attributeName)
+	if err != nil {
+		return false, err
+	}
+	return string(value) == string(attributeValue), nil
+}
+
+func (stub *MockStub) VerifyAttributes(attrs ...*attr.Attribute) (bool, error) {
+	for _, attribute := range attrs {
+		ok, err := stub.VerifyAttribute(attribute.Name, attribute.Value)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// VerifySignature checks an ECDSA SHA-256 signature of message made with the key of certificate.
+func (stub *MockStub) VerifySignature(certificate, signature, message []byte) (bool, error) {
+	cert, err := x509.ParseCertificate(certificate)
+	if err != nil {
+		return false, err
+	}
+	return cert.CheckSignature(x509.ECDSAWithSHA256, message, signature) == nil, nil
+}
+
+func (stub *MockStub) GetCallerCertificate() ([]byte, error) {
+	return stub.Caller, nil
+}
+
+func (stub *MockStub) GetCallerMetadata() ([]byte, error) {
+	return stub.Metadata, nil
+}
+
+// GetBinding returns the transaction id, a mock transaction has no real binding.
+func (stub *MockStub) GetBinding() ([]byte, error) {
+	return []byte(stub.GetTxID()), nil
+}
+
+// GetPayload returns the function and arguments of the running transaction separated by newlines.
+func (stub *MockStub) GetPayload() ([]byte, error) {
+	var payload []byte
+	for i, arg := range stub.GetStringArgs() {
+		if i > 0 {
+			payload = append(payload, '\n')
+		}
+		payload = append(payload, arg...)
+	}
+	return payload, nil
+}
+
+func (stub *MockStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	tx, err := stub.current()
+	if err != nil {
+		return nil, err
+	}
+	return &timestamp.Timestamp{Seconds: tx.timestamp.Unix(), Nanos: int32(tx.timestamp.Nanosecond())}, nil
+}
+
+// SetEvent sets the event of the running transaction. As on a v0.6 peer a transaction has at most one event, a
+// second call replaces the first.
+func (stub *MockStub) SetEvent(name string, payload []byte) error {
+	tx, err := stub.writable(name)
+	if err != nil {
+		return err
+	}
+	tx.event = &Event{TxID: tx.id, Name: name, Payload: append([]byte(nil), payload...)}
+	return nil
+}
+
+// rangeIterator iterates over a snapshot of the keys taken by RangeQueryState.
+type rangeIterator struct {
+	keys   []string
+	values map[string][]byte
+	next   int
+	closed bool
+}
+
+func (it *rangeIterator) HasNext() bool {
+	return !it.closed && it.next < len(it.keys)
+}
+
+func (it *rangeIterator) Next() (string, []byte, error) {
+	if !it.HasNext() {
+		return "", nil, errors.New("mockstub: range query iterator is exhausted")
+	}
+	key := it.keys[it.next]
+	it.next++
+	return key, it.values[key], nil
+}
+
+func (it *rangeIterator) Close() error {
+	it.closed = true
+	return nil
+}
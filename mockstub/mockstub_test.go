@@ -0,0 +1,241 @@
+package mockstub
+
+import (
+	"crypto/x509"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// kvChaincode is a minimal chaincode that exposes the stub API as functions.
+type kvChaincode struct{}
+
+func (t *kvChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	return nil, stub.PutState("init", []byte(function))
+}
+
+func (t *kvChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	switch function {
+	case "put":
+		if err := stub.PutState(args[0], []byte(args[1])); err != nil {
+			return nil, err
+		}
+		if err := stub.SetEvent("put", []byte(args[0])); err != nil {
+			return nil, err
+		}
+		if len(args) > 2 {
+			return nil, errors.New(args[2])
+		}
+		return nil, nil
+	case "del":
+		return nil, stub.DelState(args[0])
+	case "event":
+		stub.SetEvent(args[0], []byte("first"))
+		return nil, stub.SetEvent(args[0], []byte(args[1]))
+	case "putscan":
+		stub.PutState(args[0], []byte("new"))
+		stub.DelState(args[1])
+		return t.Query(stub, "scan", []string{"", ""})
+	}
+	return t.Query(stub, function, args)
+}
+
+func (t *kvChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	switch function {
+	case "get":
+		return stub.GetState(args[0])
+	case "put":
+		return nil, stub.PutState(args[0], []byte(args[1]))
+	case "ts":
+		ts, err := stub.GetTxTimestamp()
+		if err != nil {
+			return nil, err
+		}
+		return []byte(time.Unix(ts.Seconds, 0).UTC().Format(time.RFC3339)), nil
+	case "txid":
+		return []byte(stub.GetTxID()), nil
+	case "args":
+		return []byte(strings.Join(stub.GetStringArgs(), ",")), nil
+	case "caller":
+		certificate, err := stub.GetCallerCertificate()
+		if err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(certificate)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(cert.Subject.CommonName), nil
+	case "attr":
+		return stub.ReadCertAttribute(args[0])
+	case "scan":
+		iter, err := stub.RangeQueryState(args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		defer iter.Close()
+		var keys []string
+		for iter.HasNext() {
+			key, value, err := iter.Next()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key+"="+string(value))
+		}
+		sort.Strings(keys)
+		return []byte(strings.Join(keys, ",")), nil
+	case "forward":
+		return stub.QueryChaincode(args[0], [][]byte{[]byte(args[1]), []byte(args[2])})
+	}
+	return nil, errors.New("unknown function " + function)
+}
+
+func newKVStub() *MockStub {
+	return NewMockStub("kv", new(kvChaincode))
+}
+
+func TestTransactionsCommitOnlyOnSuccess(t *testing.T) {
+	stub := newKVStub()
+	if _, err := stub.MockInit("deploy"); err != nil {
+		t.Fatal(err)
+	}
+	if string(stub.State["init"]) != "deploy" {
+		t.Fatalf("Init write not committed: %q", stub.State["init"])
+	}
+
+	if _, err := stub.MockInvoke("put", "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.MockInvoke("put", "b", "2", "boom"); err == nil {
+		t.Fatal("expected the failing invoke to return its error")
+	}
+	if _, ok := stub.State["b"]; ok {
+		t.Fatal("write of a failed transaction was committed")
+	}
+	if len(stub.Events) != 1 || string(stub.Events[0].Payload) != "a" {
+		t.Fatalf("expected only the event of the committed transaction, got %+v", stub.Events)
+	}
+
+	if _, err := stub.MockInvoke("del", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stub.State["a"]; ok {
+		t.Fatal("deleted key is still in the state")
+	}
+}
+
+func TestQueriesAreReadOnly(t *testing.T) {
+	stub := newKVStub()
+	if _, err := stub.MockQuery("put", "a", "1"); err == nil {
+		t.Fatal("expected a write from a query to fail")
+	}
+	before := stub.Time
+	stub.MockQuery("get", "a")
+	if !stub.Time.Equal(before) {
+		t.Fatal("a query advanced the clock")
+	}
+}
+
+func TestRangeQuerySeesOwnWrites(t *testing.T) {
+	stub := newKVStub()
+	for _, kv := range [][]string{{"a", "1"}, {"b", "2"}, {"c", "3"}} {
+		stub.MockInvoke("put", kv[0], kv[1])
+	}
+
+	tests := []struct {
+		function string
+		args     []string
+		want     string
+	}{
+		{"scan", []string{"a", "c"}, "a=1,b=2,c=3"},
+		{"scan", []string{"a", "b"}, "a=1,b=2"},
+		{"scan", []string{"b", ""}, "b=2,c=3"},
+		{"scan", []string{"x", "z"}, ""},
+		{"putscan", []string{"ab", "b"}, "a=1,ab=new,c=3"},
+	}
+	for _, test := range tests {
+		got, err := stub.MockInvoke(test.function, test.args...)
+		if err != nil {
+			t.Fatalf("%s %v: %v", test.function, test.args, err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s %v = %q, want %q", test.function, test.args, got, test.want)
+		}
+	}
+}
+
+func TestTransactionContext(t *testing.T) {
+	stub := newKVStub()
+	stub.Step = time.Hour
+
+	first, _ := stub.MockInvoke("ts")
+	second, _ := stub.MockInvoke("ts")
+	if string(first) != "2017-04-26T00:00:00Z" || string(second) != "2017-04-26T01:00:00Z" {
+		t.Fatalf("unexpected timestamps %s, %s", first, second)
+	}
+
+	id1, _ := stub.MockInvoke("txid")
+	id2, _ := stub.MockInvoke("txid")
+	if len(id1) == 0 || string(id1) == string(id2) {
+		t.Fatalf("expected distinct transaction ids, got %s and %s", id1, id2)
+	}
+
+	args, _ := stub.MockInvoke("args", "x", "y")
+	if string(args) != "args,x,y" {
+		t.Fatalf("GetStringArgs = %s", args)
+	}
+
+	if _, err := stub.GetState("a"); err == nil {
+		t.Fatal("expected GetState outside of a transaction to fail")
+	}
+}
+
+func TestCallerIdentity(t *testing.T) {
+	stub := newKVStub()
+	if _, err := stub.MockQuery("caller"); err == nil {
+		t.Fatal("expected a missing caller certificate to fail")
+	}
+
+	stub.SetCaller("alice")
+	stub.Attributes["party"] = []byte("ACME")
+	if got, _ := stub.MockQuery("caller"); string(got) != "alice" {
+		t.Fatalf("caller = %s", got)
+	}
+	if got, _ := stub.MockQuery("attr", "party"); string(got) != "ACME" {
+		t.Fatalf("party attribute = %s", got)
+	}
+	if _, err := stub.MockQuery("attr", "role"); err == nil {
+		t.Fatal("expected a missing attribute to fail")
+	}
+}
+
+func TestOneEventPerTransaction(t *testing.T) {
+	stub := newKVStub()
+	stub.MockInvoke("event", "E", "second")
+	event, ok := stub.LastEvent()
+	if !ok || len(stub.Events) != 1 || event.Name != "E" || string(event.Payload) != "second" {
+		t.Fatalf("expected the last SetEvent to win, got %+v", stub.Events)
+	}
+	if event.TxID == "" {
+		t.Fatal("event has no transaction id")
+	}
+}
+
+func TestChaincodeToChaincode(t *testing.T) {
+	stub := newKVStub()
+	other := NewMockStub("other", new(kvChaincode))
+	other.State["k"] = []byte("v")
+	stub.RegisterChaincode(other)
+
+	got, err := stub.MockQuery("forward", "other", "get", "k")
+	if err != nil || string(got) != "v" {
+		t.Fatalf("QueryChaincode = %s, %v", got, err)
+	}
+	if _, err := stub.MockQuery("forward", "missing", "get", "k"); err == nil {
+		t.Fatal("expected an unknown chaincode to fail")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+// packageIds decodes a query result - a package, a package list or the package holder - into its package ids.
+func packageIds(t *testing.T, function string, result []byte) string {
+	t.Helper()
+	var ids []string
+	switch function {
+	case "querypkgbyid":
+		var packageinfo PackageInfo
+		if err := json.Unmarshal(result, &packageinfo); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, packageinfo.PkgId+"="+packageinfo.PkgStatus)
+	case "queryallpkgids":
+		var holder PKG_Holder
+		if err := json.Unmarshal(result, &holder); err != nil {
+			t.Fatal(err)
+		}
+		ids = holder.PkgIds
+	default:
+		var packages []PackageInfo
+		if err := json.Unmarshal(result, &packages); err != nil {
+			t.Fatal(err)
+		}
+		for _, packageinfo := range packages {
+			ids = append(ids, packageinfo.PkgId)
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+// TestChaincode runs the package lifecycle as one sequence, every step sees the state left by the previous ones.
+func TestChaincode(t *testing.T) {
+	stub := mockstub.NewMockStub("mycode", new(SimpleChaincode))
+	if _, err := stub.MockInit("init", "SHIPPER", "INSURER", "CONSIGNEE", "PROVIDER", "-20"); err == nil {
+		t.Fatal("expected init with missing arguments to fail")
+	}
+	if _, err := stub.MockInit("init", "SHIPPER", "INSURER", "CONSIGNEE", "PROVIDER", "-20", "-10", "sample"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		query    bool
+		function string
+		args     []string
+		want     string
+		fails    bool
+	}{
+		{"init package", true, "querypkgbyid", []string{"1Z20170426"}, "1Z20170426=Label_Generated", false},
+		{"create", false, "create", []string{"P1", "SHP", "INS", "CON", "2", "8", "vaccine", "PRV"}, "", false},
+		{"create second", false, "create", []string{"P2", "SHP2", "INS", "CON", "2", "8", "insulin", "PRV2"}, "", false},
+		{"create duplicate", false, "create", []string{"P1", "SHP", "INS", "CON", "2", "8", "vaccine", "PRV"}, "", true},
+		{"create with text temperature", false, "create", []string{"P3", "SHP", "INS", "CON", "cold", "8", "vaccine", "PRV"}, "", true},
+		{"create with missing argument", false, "create", []string{"P3", "SHP", "INS", "CON", "2", "8", "vaccine"}, "", true},
+		{"created package", true, "querypkgbyid", []string{"P1"}, "P1=Label_Generated", false},
+		{"missing package", true, "querypkgbyid", []string{"P9"}, "", true},
+		{"all package ids", true, "queryallpkgids", nil, "1Z20170426,P1,P2", false},
+		{"all packages", true, "queryallpkg", nil, "1Z20170426,P1,P2", false},
+		{"by provider", true, "querypkgbyprovider", []string{"PRV2"}, "P2", false},
+		{"by shipper", true, "querypkgbyshipper", []string{"SHP"}, "P1", false},
+		{"by role", true, "querybyrole", []string{"Consignee", "CON"}, "P1,P2", false},
+		{"by unknown role", true, "querybyrole", []string{"Owner", "CON"}, "", true},
+		{"accept by wrong provider", false, "acceptpkg", []string{"P1", "PRV2"}, "", true},
+		{"accept", false, "acceptpkg", []string{"P1", "PRV"}, "", false},
+		{"accept missing package", false, "acceptpkg", []string{"P9", "PRV"}, "", true},
+		{"accepted package", true, "querypkgbyid", []string{"P1"}, "P1=In_Transit", false},
+		{"by status", true, "querybypkgstatus", []string{"In_Transit"}, "P1", false},
+		{"by role and status", true, "querybyrole_status", []string{"Shipper", "SHP", "In_Transit"}, "P1", false},
+		{"by role and unknown status", true, "querybyrole_status", []string{"Shipper", "SHP", "Lost"}, "", true},
+		{"temperature in range", false, "updatetemp", []string{"P1", "5"}, "", false},
+		{"temperature as text", false, "updatetemp", []string{"P1", "warm"}, "", true},
+		{"deliver by wrong provider", false, "deliverpkg", []string{"P1", "PRV2"}, "", true},
+		{"deliver", false, "deliverpkg", []string{"P1", "PRV"}, "", false},
+		{"deliver twice", false, "deliverpkg", []string{"P1", "PRV"}, "", true},
+		{"delivered package", true, "querypkgbyid", []string{"P1"}, "P1=Pkg_Delivered", false},
+		{"temperature out of range", false, "updatetemp", []string{"P2", "12"}, "", false},
+		{"damaged package", true, "querypkgbyid", []string{"P2"}, "P2=Pkg_Damaged", false},
+		{"temperature of damaged package", false, "updatetemp", []string{"P2", "5"}, "", true},
+		{"accept damaged package", false, "acceptpkg", []string{"P2", "PRV2"}, "", true},
+		{"deliver damaged package", false, "deliverpkg", []string{"P2", "PRV2"}, "", true},
+		{"by status damaged", true, "querybypkgstatus", []string{"Pkg_Damaged"}, "P2", false},
+		{"unknown invoke", false, "cancelpkg", []string{"P1"}, "", true},
+		{"unknown query", true, "create", []string{"P3", "SHP", "INS", "CON", "2", "8", "vaccine", "PRV"}, "", true},
+	}
+	for _, test := range tests {
+		var got []byte
+		var err error
+		if test.query {
+			got, err = stub.MockQuery(test.function, test.args...)
+		} else {
+			got, err = stub.MockInvoke(test.function, test.args...)
+		}
+		if (err != nil) != test.fails {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+		if test.want == "" {
+			continue
+		}
+		if ids := packageIds(t, test.function, got); ids != test.want {
+			t.Fatalf("%s: got %s, want %s", test.name, ids, test.want)
+		}
+	}
+}
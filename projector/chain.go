@@ -0,0 +1,142 @@
+package projector
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//==============================================================================================================================
+//	ChainSource - An EventSource reading the chaincode events of one chaincode block by block from the REST API of a
+//				peer (by default on port 7050). Unlike the event hub it can start from any block, which lets a
+//				projector catch up on the transactions committed while it was down.
+//==============================================================================================================================
+type ChainSource struct {
+	restURL     string
+	chaincodeId string
+	poll        time.Duration
+	client      *http.Client
+
+	next    uint64
+	height  uint64
+	pending []ChaincodeEvent
+	closed  chan struct{}
+}
+
+//==============================================================================================================================
+//	chainInfo, chainBlock, chainEvent - the parts of the GET /chain and GET /chain/blocks/{n} responses read by ChainSource. The
+//				chaincode events of a block are listed in its nonHashData, one per transaction.
+//==============================================================================================================================
+type chainInfo struct {
+	Height uint64 `json:"height"`
+}
+
+type chainBlock struct {
+	NonHashData struct {
+		ChaincodeEvents []chainEvent `json:"chaincodeEvents"`
+	} `json:"nonHashData"`
+}
+
+type chainEvent struct {
+	ChaincodeID string `json:"chaincodeID"`
+	TxID        string `json:"txID"`
+	EventName   string `json:"eventName"`
+	Payload     []byte `json:"payload"`
+}
+
+//==============================================================================================================================
+//	NewChainSource - reads the events of chaincodeId from the peer REST API at restURL, e.g. http://localhost:7050,
+//				starting with block from. Once the head of the chain is reached Next polls for new blocks every
+//				poll, or returns io.EOF if poll is 0.
+//==============================================================================================================================
+func NewChainSource(restURL string, chaincodeId string, from uint64, poll time.Duration) *ChainSource {
+	return &ChainSource{
+		restURL:     strings.TrimSuffix(restURL, "/"),
+		chaincodeId: chaincodeId,
+		poll:        poll,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		next:        from,
+		closed:      make(chan struct{}),
+	}
+}
+
+func (s *ChainSource) Next() (ChaincodeEvent, error) {
+	for len(s.pending) == 0 {
+		select {
+		case <-s.closed:
+			return ChaincodeEvent{}, io.EOF
+		default:
+		}
+
+		if s.next >= s.height {
+			var info chainInfo
+			err := s.get("/chain", &info)
+			if err != nil {
+				return ChaincodeEvent{}, err
+			}
+			s.height = info.Height
+		}
+
+		if s.next >= s.height {
+			if s.poll == 0 {
+				return ChaincodeEvent{}, io.EOF
+			}
+			select {
+			case <-s.closed:
+				return ChaincodeEvent{}, io.EOF
+			case <-time.After(s.poll):
+			}
+			continue
+		}
+
+		var block chainBlock
+		err := s.get("/chain/blocks/"+strconv.FormatUint(s.next, 10), &block)
+		if err != nil {
+			return ChaincodeEvent{}, err
+		}
+		for _, ccevent := range block.NonHashData.ChaincodeEvents {
+			if ccevent.ChaincodeID != s.chaincodeId || ccevent.EventName == "" {
+				continue
+			}
+			s.pending = append(s.pending, ChaincodeEvent{TxID: ccevent.TxID, Block: s.next, EventName: ccevent.EventName, Payload: ccevent.Payload})
+		}
+		s.next++
+	}
+
+	event := s.pending[0]
+	s.pending = s.pending[1:]
+	return event, nil
+}
+
+func (s *ChainSource) Close() error {
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	get - decodes the JSON response to a GET of path on the REST API
+//==============================================================================================================================
+func (s *ChainSource) get(path string, v interface{}) error {
+	resp, err := s.client.Get(s.restURL + path)
+	if err != nil {
+		return errors.New("Error: Could not read " + path + " " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("Error: Could not read " + path + " " + resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return errors.New("Error: Could not unmarshal " + path + " " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package projector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// chainEventOf returns the event as listed in a block, emitted by chaincodeId
+func chainEventOf(chaincodeId string, event ChaincodeEvent) chainEvent {
+	return chainEvent{ChaincodeID: chaincodeId, TxID: event.TxID, EventName: event.EventName, Payload: event.Payload}
+}
+
+// chainServer serves GET /chain and GET /chain/blocks/{n} for blocks holding the chaincode events
+func chainServer(t *testing.T, events ...[]chainEvent) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chain", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"height": %d, "currentBlockHash": "x"}`, len(events))
+	})
+	mux.HandleFunc("/chain/blocks/", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		if _, err := fmt.Sscanf(r.URL.Path, "/chain/blocks/%d", &n); err != nil || n >= len(events) {
+			http.NotFound(w, r)
+			return
+		}
+		var block chainBlock
+		block.NonHashData.ChaincodeEvents = events[n]
+		json.NewEncoder(w).Encode(block)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestChainSource(t *testing.T) {
+	// the genesis block, a block with a transaction that set no event and one with an event of another chaincode
+	server := chainServer(t,
+		nil,
+		[]chainEvent{chainEventOf("pkgcc", pkgEvent("tx1", 0, "PkgCreated", p1)), {ChaincodeID: "pkgcc", TxID: "tx2"}},
+		nil,
+		[]chainEvent{chainEventOf("pkgcc", pkgEvent("tx3", 0, "PkgCreated", p2)), chainEventOf("othercc", pkgEvent("tx4", 0, "PkgCreated", p1))},
+	)
+
+	store := newTestStore(t)
+	p := &Projector{Source: NewChainSource(server.URL+"/", "pkgcc", 0, 0), Store: store}
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := providerIds(t, store, "PRV"); !equalStrings(got, []string{"P1", "P2"}) {
+		t.Fatalf("PackagesByProvider = %v", got)
+	}
+	if block, err := store.Checkpoint(); err != nil || block != 3 {
+		t.Fatalf("Checkpoint = %d, %v", block, err)
+	}
+
+	// resuming reads the checkpoint block again, its transactions are not applied twice
+	source := NewChainSource(server.URL, "pkgcc", 3, 0)
+	var txids []string
+	for {
+		event, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Block != 3 {
+			t.Fatalf("event %s of block %d", event.TxID, event.Block)
+		}
+		txids = append(txids, event.TxID)
+		if written, err := store.Apply(event); err != nil || written != 0 {
+			t.Fatalf("Apply(%s) = %d, %v", event.TxID, written, err)
+		}
+	}
+	if !equalStrings(txids, []string{"tx3"}) {
+		t.Fatalf("events %v", txids)
+	}
+
+	if _, err := NewChainSource(server.URL+"/missing", "pkgcc", 0, 0).Next(); err == nil {
+		t.Fatal("Next succeeded without a chain")
+	}
+
+	closed := NewChainSource(server.URL, "pkgcc", 0, 0)
+	closed.Close()
+	if _, err := closed.Next(); err != io.EOF {
+		t.Fatalf("Next after Close = %v", err)
+	}
+}
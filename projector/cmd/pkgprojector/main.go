@@ -0,0 +1,92 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/jainrahul1234/learn-chaincode/projector"
+)
+
+//=================================================================================================================================
+//	 Main - pkgprojector follows the package chaincode on a peer, or replays a file of recorded events, into a SQLite
+//			read model and serves the read model queries over HTTP. Given -rest it reads the blocks of the peer
+//			from the checkpoint of the read model on, so that a restart picks up the transactions committed
+//			while it was down; the event hub only delivers events from the moment it connects.
+//=================================================================================================================================
+func main() {
+	peer := flag.String("peer", "localhost:7053", "event hub address of the peer")
+	rest := flag.String("rest", "", "REST API URL of the peer, e.g. http://localhost:7050, to read blocks from instead of the event hub")
+	poll := flag.Duration("poll", 5*time.Second, "interval to poll the REST API for new blocks")
+	chaincodeId := flag.String("chaincode", "", "name of the deployed package chaincode")
+	replay := flag.String("replay", "", "replay recorded events from this file instead of following a peer")
+	dbPath := flag.String("db", "packages.db", "SQLite database of the read model")
+	listen := flag.String("listen", ":8080", "address to serve the read model queries on")
+	flag.Parse()
+
+	db, err := sql.Open("sqlite3", *dbPath)
+	if err != nil {
+		fmt.Println("Error opening read model:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	store, err := projector.NewStore(db)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var source projector.EventSource
+	if *replay != "" {
+		file, err := os.Open(*replay)
+		if err != nil {
+			fmt.Println("Error opening recorded events:", err)
+			os.Exit(1)
+		}
+		source = projector.NewFileSource(file)
+	} else if *chaincodeId == "" {
+		fmt.Println("Error: -chaincode is required to follow a peer")
+		os.Exit(1)
+	} else if *rest != "" {
+		checkpoint, err := store.Checkpoint()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("Resuming from block", checkpoint)
+		source = projector.NewChainSource(*rest, *chaincodeId, checkpoint, *poll)
+	} else {
+		source, err = projector.NewEventHubSource(*peer, *chaincodeId)
+		if err != nil {
+			fmt.Println("Error connecting to event hub:", err)
+			os.Exit(1)
+		}
+	}
+	defer source.Close()
+
+	go func() {
+		err := http.ListenAndServe(*listen, projector.NewHandler(store))
+		if err != nil {
+			fmt.Println("Error serving queries:", err)
+			os.Exit(1)
+		}
+	}()
+
+	p := &projector.Projector{Source: source, Store: store}
+	err = p.Run()
+	if err != nil {
+		fmt.Println("Error projecting events:", err)
+		os.Exit(1)
+	}
+
+	// a replayed file is exhausted, keep serving the read model
+	if *replay != "" {
+		select {}
+	}
+}
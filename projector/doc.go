@@ -0,0 +1,15 @@
+// Package projector keeps an off-chain SQL read model of the packages of the intermediate chaincode.
+//
+// A Projector follows the chaincode events of the package chaincode through an EventSource, an EventHubSource
+// connected to a peer or a MockSource / FileSource for tests and local replays, and writes the package snapshot
+// carried by every event into a Store. The Store answers the querypkgbyprovider and querybyrole_status queries
+// without reading the ledger; NewHandler serves them over HTTP. Cancelled and archived packages are deleted from
+// the read model, just as the chaincode list queries leave them out by default.
+//
+// Every chaincode transaction that writes a package emits an event carrying its snapshot. The Store records the
+// transactions it applied and the block of the last one, its checkpoint. A ChainSource reads the blocks of a peer
+// from the checkpoint on, so a restarted projector catches up on the transactions it missed while down, and
+// transactions read twice are applied once. The event hub only delivers events while connected. A read model
+// started after the deployment of the chaincode can be seeded from block 0, or by invoking rebuildpkgindex, which
+// announces every package.
+package projector
@@ -0,0 +1,94 @@
+package projector
+
+import (
+	"io"
+
+	"github.com/hyperledger/fabric/events/consumer"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+//==============================================================================================================================
+//	EventHubSource - An EventSource receiving the chaincode events of one chaincode from the event hub of a peer
+//				(by default on port 7053)
+//==============================================================================================================================
+type EventHubSource struct {
+	client  *consumer.EventsClient
+	adapter *eventHubAdapter
+}
+
+//==============================================================================================================================
+//	eventHubAdapter - consumer.EventAdapter registering for every event of the chaincode and handing them to Next
+//==============================================================================================================================
+type eventHubAdapter struct {
+	chaincodeId string
+	events      chan ChaincodeEvent
+	done        chan error
+}
+
+//==============================================================================================================================
+//	NewEventHubSource - connects to the event hub at peerAddress and registers for the events of chaincodeId
+//==============================================================================================================================
+func NewEventHubSource(peerAddress string, chaincodeId string) (*EventHubSource, error) {
+	adapter := &eventHubAdapter{
+		chaincodeId: chaincodeId,
+		events:      make(chan ChaincodeEvent, 100),
+		done:        make(chan error, 1),
+	}
+
+	client := consumer.NewEventsClient(peerAddress, adapter)
+	err := client.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	return &EventHubSource{client: client, adapter: adapter}, nil
+}
+
+func (s *EventHubSource) Next() (ChaincodeEvent, error) {
+	select {
+	case event := <-s.adapter.events:
+		return event, nil
+	case err := <-s.adapter.done:
+		// let every later call see the same end of stream
+		select {
+		case s.adapter.done <- err:
+		default:
+		}
+		return ChaincodeEvent{}, err
+	}
+}
+
+func (s *EventHubSource) Close() error {
+	return s.client.Stop()
+}
+
+func (a *eventHubAdapter) GetInterestedEvents() ([]*pb.Interest, error) {
+	return []*pb.Interest{{
+		EventType: pb.EventType_CHAINCODE,
+		RegInfo: &pb.Interest_ChaincodeRegInfo{
+			ChaincodeRegInfo: &pb.ChaincodeReg{ChaincodeID: a.chaincodeId, EventName: ""}}}}, nil
+}
+
+func (a *eventHubAdapter) Recv(msg *pb.Event) (bool, error) {
+	ccevent, ok := msg.Event.(*pb.Event_ChaincodeEvent)
+	if !ok || ccevent.ChaincodeEvent.ChaincodeID != a.chaincodeId {
+		return true, nil
+	}
+
+	a.events <- ChaincodeEvent{
+		TxID:      ccevent.ChaincodeEvent.TxID,
+		EventName: ccevent.ChaincodeEvent.EventName,
+		Payload:   ccevent.ChaincodeEvent.Payload,
+	}
+	return true, nil
+}
+
+func (a *eventHubAdapter) Disconnected(err error) {
+	if err == nil {
+		err = io.EOF
+	}
+	select {
+	case a.done <- err:
+	default:
+	}
+}
@@ -0,0 +1,54 @@
+package projector
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+//==============================================================================================================================
+//	NewHandler - serves the read model queries, named and returning the same packages as the chaincode queries:
+//					GET /querypkgbyprovider?provider=...
+//					GET /querybyrole_status?role=...&party=...&status=...
+//==============================================================================================================================
+func NewHandler(store *Store) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/querypkgbyprovider", func(w http.ResponseWriter, r *http.Request) {
+		provider := r.URL.Query().Get("provider")
+		if provider == "" {
+			http.Error(w, "Error: Expecting provider", http.StatusBadRequest)
+			return
+		}
+		docs, err := store.PackagesByProvider(provider)
+		writeDocs(w, docs, err)
+	})
+
+	mux.HandleFunc("/querybyrole_status", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		role, party, status := query.Get("role"), query.Get("party"), query.Get("status")
+		if role == "" || party == "" || status == "" {
+			http.Error(w, "Error: Expecting role, party and status", http.StatusBadRequest)
+			return
+		}
+		if _, ok := roleColumns[role]; !ok {
+			http.Error(w, "Error: Invalid role "+role, http.StatusBadRequest)
+			return
+		}
+		docs, err := store.PackagesByRoleStatus(role, party, status)
+		writeDocs(w, docs, err)
+	})
+
+	return mux
+}
+
+//==============================================================================================================================
+//	writeDocs - writes the documents as a JSON array, or the error
+//==============================================================================================================================
+func writeDocs(w http.ResponseWriter, docs []json.RawMessage, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(docs)
+}
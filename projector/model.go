@@ -0,0 +1,77 @@
+package projector
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+//==============================================================================================================================
+//	PkgEvent - The payload of the package chaincode events, see PkgEvent in the intermediate chaincode. Package is
+//				kept as the raw JSON written by the chaincode so that the read model returns the same documents
+//				as the chaincode queries.
+//==============================================================================================================================
+type PkgEvent struct {
+	Event     string          `json:"event"`
+	PkgId     string          `json:"packageid"`
+	OldStatus string          `json:"oldstatus"`
+	NewStatus string          `json:"newstatus"`
+	Actor     string          `json:"actor"`
+	Timestamp int64           `json:"timestamp"`
+	Package   json.RawMessage `json:"package"`
+}
+
+//==============================================================================================================================
+//	Package - The PackageInfo fields the read model is indexed on
+//==============================================================================================================================
+type Package struct {
+	PkgId     string `json:"packageid"`
+	Shipper   string `json:"shipper"`
+	Insurer   string `json:"insurer"`
+	Consignee string `json:"consignee"`
+	Provider  string `json:"provider"`
+	Custodian string `json:"custodian"`
+	PkgStatus string `json:"pkgstatus"`
+}
+
+//==============================================================================================================================
+//	currentProvider - the Provider the package is listed under: its custodian once it was handed over, as the
+//				chaincode indexes it
+//==============================================================================================================================
+func (p *Package) currentProvider() string {
+	if p.Custodian != "" {
+		return p.Custodian
+	}
+	return p.Provider
+}
+
+//==============================================================================================================================
+//	 Event names - A transaction emits a single PkgEvent under its own name or several under EVENT_PKG_BATCH.
+//					Cancelled and archived packages no longer show up in the chaincode list queries.
+//==============================================================================================================================
+const (
+	EVENT_PKG_BATCH     = "PkgEventBatch"
+	EVENT_PKG_CANCELLED = "PkgCancelled"
+	EVENT_PKG_ARCHIVED  = "PkgArchived"
+)
+
+//==============================================================================================================================
+//	decodePkgEvents - the package events carried by a chaincode event
+//==============================================================================================================================
+func decodePkgEvents(event ChaincodeEvent) ([]PkgEvent, error) {
+	var events []PkgEvent
+
+	if event.EventName == EVENT_PKG_BATCH {
+		err := json.Unmarshal(event.Payload, &events)
+		if err != nil {
+			return nil, errors.New("Error: Could not unmarshal package event batch of " + event.TxID + " " + err.Error())
+		}
+		return events, nil
+	}
+
+	var pkgevent PkgEvent
+	err := json.Unmarshal(event.Payload, &pkgevent)
+	if err != nil {
+		return nil, errors.New("Error: Could not unmarshal package event of " + event.TxID + " " + err.Error())
+	}
+	return append(events, pkgevent), nil
+}
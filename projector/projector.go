@@ -0,0 +1,36 @@
+package projector
+
+import (
+	"fmt"
+	"io"
+)
+
+//==============================================================================================================================
+//	Projector - Follows the events of the package chaincode and keeps the read model in Store up to date
+//==============================================================================================================================
+type Projector struct {
+	Source EventSource
+	Store  *Store
+}
+
+//==============================================================================================================================
+//	Run - applies every event of the source to the store until the source is exhausted or closed. An event that can
+//				not be applied stops the projector so that the read model never skips a transaction.
+//==============================================================================================================================
+func (p *Projector) Run() error {
+	for {
+		event, err := p.Source.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		written, err := p.Store.Apply(event)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Projected", written, "packages of", event.EventName, event.TxID)
+	}
+}
@@ -0,0 +1,33 @@
+package projector
+
+import (
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	store := newTestStore(t)
+	source := NewMockSource(pkgEvent("tx1", 1, "PkgCreated", p1))
+	source.Add(pkgEvent("tx2", 2, "PkgCreated", p2))
+
+	p := &Projector{Source: source, Store: store}
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := providerIds(t, store, "PRV"); !equalStrings(got, []string{"P1", "P2"}) {
+		t.Fatalf("PackagesByProvider = %v", got)
+	}
+
+	// an event that can not be applied stops the projector before the events after it
+	cancelled := pkgEvent("tx4", 4, EVENT_PKG_CANCELLED, p1)
+	source.Add(ChaincodeEvent{TxID: "tx3", Block: 3, EventName: "PkgAccepted", Payload: []byte(`not json`)})
+	source.Add(cancelled)
+	if err := p.Run(); err == nil {
+		t.Fatal("Run applied a malformed event")
+	}
+	if got := providerIds(t, store, "PRV"); !equalStrings(got, []string{"P1", "P2"}) {
+		t.Fatalf("PackagesByProvider after the malformed event = %v", got)
+	}
+	if block, err := store.Checkpoint(); err != nil || block != 2 {
+		t.Fatalf("Checkpoint = %d, %v", block, err)
+	}
+}
@@ -0,0 +1,107 @@
+package projector
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+//==============================================================================================================================
+//	ChaincodeEvent - A chaincode event as received from a peer: the transaction that emitted it, the event name and
+//				the payload set by the chaincode. Block is the number of the block holding the transaction, 0
+//				if the source does not know it.
+//==============================================================================================================================
+type ChaincodeEvent struct {
+	TxID      string          `json:"txid"`
+	Block     uint64          `json:"block,omitempty"`
+	EventName string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+//==============================================================================================================================
+//	EventSource - A stream of chaincode events in the order their transactions were committed. Next blocks until an
+//				event is available and returns io.EOF once the source is exhausted or closed.
+//==============================================================================================================================
+type EventSource interface {
+	Next() (ChaincodeEvent, error)
+	Close() error
+}
+
+//==============================================================================================================================
+//	MockSource - An in memory EventSource for tests. Events are returned in the order they were added.
+//==============================================================================================================================
+type MockSource struct {
+	events []ChaincodeEvent
+}
+
+func NewMockSource(events ...ChaincodeEvent) *MockSource {
+	return &MockSource{events: events}
+}
+
+func (s *MockSource) Add(event ChaincodeEvent) {
+	s.events = append(s.events, event)
+}
+
+func (s *MockSource) Next() (ChaincodeEvent, error) {
+	if len(s.events) == 0 {
+		return ChaincodeEvent{}, io.EOF
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+func (s *MockSource) Close() error {
+	s.events = nil
+	return nil
+}
+
+//==============================================================================================================================
+//	FileSource - An EventSource replaying events recorded as one JSON ChaincodeEvent per line, e.g.
+//					{"txid": "...", "event": "PkgCreated", "payload": {...}}
+//==============================================================================================================================
+type FileSource struct {
+	scanner *bufio.Scanner
+	closer  io.Closer
+}
+
+//==============================================================================================================================
+//	NewFileSource - replays the events read from r. r is closed by Close if it is an io.Closer.
+//==============================================================================================================================
+func NewFileSource(r io.Reader) *FileSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	source := &FileSource{scanner: scanner}
+	if closer, ok := r.(io.Closer); ok {
+		source.closer = closer
+	}
+	return source
+}
+
+func (s *FileSource) Next() (ChaincodeEvent, error) {
+	var event ChaincodeEvent
+	for s.scanner.Scan() {
+		line := s.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		err := json.Unmarshal(line, &event)
+		if err != nil {
+			return event, errors.New("Error: Could not unmarshal recorded event " + err.Error())
+		}
+		return event, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return event, err
+	}
+	return event, io.EOF
+}
+
+func (s *FileSource) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
@@ -0,0 +1,210 @@
+package projector
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+//==============================================================================================================================
+//	Store - The SQL read model of the packages. Every package is one row holding the columns the queries filter on
+//				and the package document as last written by the chaincode. The transactions already applied and
+//				the last block they came from are kept alongside, so that a restarted projector can resume from
+//				its checkpoint. The statements use the SQLite dialect.
+//==============================================================================================================================
+type Store struct {
+	db *sql.DB
+}
+
+var storeSchema = []string{
+	`CREATE TABLE IF NOT EXISTS packages (
+		pkgid     TEXT PRIMARY KEY,
+		shipper   TEXT NOT NULL,
+		insurer   TEXT NOT NULL,
+		consignee TEXT NOT NULL,
+		provider  TEXT NOT NULL,
+		pkgstatus TEXT NOT NULL,
+		doc       TEXT NOT NULL,
+		txid      TEXT NOT NULL,
+		updatedat INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS packages_shipper ON packages (shipper, pkgstatus, pkgid)`,
+	`CREATE INDEX IF NOT EXISTS packages_insurer ON packages (insurer, pkgstatus, pkgid)`,
+	`CREATE INDEX IF NOT EXISTS packages_consignee ON packages (consignee, pkgstatus, pkgid)`,
+	`CREATE INDEX IF NOT EXISTS packages_provider ON packages (provider, pkgstatus, pkgid)`,
+	`CREATE TABLE IF NOT EXISTS applied (
+		txid TEXT PRIMARY KEY
+	)`,
+	`CREATE TABLE IF NOT EXISTS checkpoint (
+		id    INTEGER PRIMARY KEY CHECK (id = 1),
+		block INTEGER NOT NULL
+	)`,
+}
+
+//==============================================================================================================================
+//	roleColumns - the column holding the party of each package role, as named by querybyrole_status
+//==============================================================================================================================
+var roleColumns = map[string]string{
+	"Shipper":   "shipper",
+	"Insurer":   "insurer",
+	"Consignee": "consignee",
+	"Provider":  "provider",
+}
+
+//==============================================================================================================================
+//	NewStore - creates the read model tables in db if they do not exist yet
+//==============================================================================================================================
+func NewStore(db *sql.DB) (*Store, error) {
+	for _, statement := range storeSchema {
+		_, err := db.Exec(statement)
+		if err != nil {
+			return nil, errors.New("Error: Could not create read model schema " + err.Error())
+		}
+	}
+	return &Store{db: db}, nil
+}
+
+//==============================================================================================================================
+//	Apply - projects the package snapshots carried by a chaincode event in one database transaction and returns the
+//				number of packages written or deleted. Events without a snapshot are skipped, cancelled and
+//				archived packages are deleted from the read model. An event of a transaction that was already
+//				applied is skipped, so events replayed from a checkpoint are only applied once.
+//==============================================================================================================================
+func (s *Store) Apply(event ChaincodeEvent) (int, error) {
+	pkgevents, err := decodePkgEvents(event)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	if event.TxID != "" {
+		var applied int
+		err = tx.QueryRow(`SELECT COUNT(*) FROM applied WHERE txid = ?`, event.TxID).Scan(&applied)
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.New("Error: Could not read applied transactions " + err.Error())
+		}
+		if applied > 0 {
+			tx.Rollback()
+			fmt.Println("Skipping already applied transaction", event.TxID)
+			return 0, nil
+		}
+
+		_, err = tx.Exec(`INSERT INTO applied (txid) VALUES (?)`, event.TxID)
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.New("Error: Could not record transaction " + event.TxID + " " + err.Error())
+		}
+	}
+
+	if event.Block > 0 {
+		_, err = tx.Exec(`INSERT INTO checkpoint (id, block) VALUES (1, ?)
+			ON CONFLICT (id) DO UPDATE SET block = MAX(block, excluded.block)`, event.Block)
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.New("Error: Could not record checkpoint " + err.Error())
+		}
+	}
+
+	written := 0
+	for _, pkgevent := range pkgevents {
+		if pkgevent.Event == EVENT_PKG_CANCELLED || pkgevent.Event == EVENT_PKG_ARCHIVED {
+			_, err = tx.Exec(`DELETE FROM packages WHERE pkgid = ?`, pkgevent.PkgId)
+			if err != nil {
+				tx.Rollback()
+				return 0, errors.New("Error: Could not delete package " + pkgevent.PkgId + " " + err.Error())
+			}
+			written++
+			continue
+		}
+
+		if len(pkgevent.Package) == 0 || string(pkgevent.Package) == "null" {
+			fmt.Println("Skipping event without package snapshot for", pkgevent.PkgId)
+			continue
+		}
+
+		var pkg Package
+		err = json.Unmarshal(pkgevent.Package, &pkg)
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.New("Error: Could not unmarshal package snapshot of " + pkgevent.PkgId + " " + err.Error())
+		}
+
+		_, err = tx.Exec(`INSERT OR REPLACE INTO packages (pkgid, shipper, insurer, consignee, provider, pkgstatus, doc, txid, updatedat)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			pkg.PkgId, pkg.Shipper, pkg.Insurer, pkg.Consignee, pkg.currentProvider(), pkg.PkgStatus, string(pkgevent.Package), event.TxID, pkgevent.Timestamp)
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.New("Error: Could not write package " + pkg.PkgId + " " + err.Error())
+		}
+		written++
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+//==============================================================================================================================
+//	Checkpoint - the highest block an applied event came from, 0 if no event carried its block. Events of that block
+//				may not all have been applied yet, so a projector resumes reading the chain at the checkpoint
+//				block itself.
+//==============================================================================================================================
+func (s *Store) Checkpoint() (uint64, error) {
+	var block uint64
+	err := s.db.QueryRow(`SELECT block FROM checkpoint WHERE id = 1`).Scan(&block)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.New("Error: Could not read checkpoint " + err.Error())
+	}
+	return block, nil
+}
+
+//==============================================================================================================================
+//	PackagesByProvider - the packages in the custody of a Provider, as querypkgbyprovider returns them
+//==============================================================================================================================
+func (s *Store) PackagesByProvider(provider string) ([]json.RawMessage, error) {
+	return s.queryDocs(`SELECT doc FROM packages WHERE provider = ? ORDER BY pkgid`, provider)
+}
+
+//==============================================================================================================================
+//	PackagesByRoleStatus - the packages in status where party holds role, as querybyrole_status returns them
+//==============================================================================================================================
+func (s *Store) PackagesByRoleStatus(role string, party string, status string) ([]json.RawMessage, error) {
+	column, ok := roleColumns[role]
+	if !ok {
+		return nil, errors.New("Error: Invalid role " + role)
+	}
+	return s.queryDocs(`SELECT doc FROM packages WHERE `+column+` = ? AND pkgstatus = ? ORDER BY pkgid`, party, status)
+}
+
+//==============================================================================================================================
+//	queryDocs - runs a query selecting the doc column and returns the documents
+//==============================================================================================================================
+func (s *Store) queryDocs(query string, args ...interface{}) ([]json.RawMessage, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []json.RawMessage{}
+	for rows.Next() {
+		var doc string
+		err = rows.Scan(&doc)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, json.RawMessage(doc))
+	}
+	return docs, rows.Err()
+}
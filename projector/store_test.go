@@ -0,0 +1,188 @@
+package projector
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestStore returns a Store on an in memory database, closed at the end of the test
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection opens its own in memory database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	store, err := NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// pkgEvent returns the chaincode event of a single package event carrying pkg as its snapshot
+func pkgEvent(txid string, block uint64, name string, pkg Package) ChaincodeEvent {
+	snapshot, _ := json.Marshal(pkg)
+	payload, _ := json.Marshal(PkgEvent{Event: name, PkgId: pkg.PkgId, NewStatus: pkg.PkgStatus, Package: snapshot})
+	return ChaincodeEvent{TxID: txid, Block: block, EventName: name, Payload: payload}
+}
+
+// providerIds returns the ids of the packages listed by PackagesByProvider
+func providerIds(t *testing.T, store *Store, provider string) []string {
+	t.Helper()
+	docs, err := store.PackagesByProvider(provider)
+	return docIds(t, docs, err)
+}
+
+// roleStatusIds returns the ids of the packages listed by PackagesByRoleStatus
+func roleStatusIds(t *testing.T, store *Store, role string, party string, status string) []string {
+	t.Helper()
+	docs, err := store.PackagesByRoleStatus(role, party, status)
+	return docIds(t, docs, err)
+}
+
+// docIds returns the package ids of the documents
+func docIds(t *testing.T, docs []json.RawMessage, err error) []string {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, doc := range docs {
+		var pkg Package
+		if err := json.Unmarshal(doc, &pkg); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, pkg.PkgId)
+	}
+	return ids
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	p1 = Package{PkgId: "P1", Shipper: "SHP", Insurer: "INS", Consignee: "CON", Provider: "PRV", PkgStatus: "Label_Generated"}
+	p2 = Package{PkgId: "P2", Shipper: "SHP", Insurer: "INS", Consignee: "CON", Provider: "PRV", PkgStatus: "Label_Generated"}
+)
+
+func TestApply(t *testing.T) {
+	store := newTestStore(t)
+
+	moving := p1
+	moving.PkgStatus = "In_Transit"
+	handedOver := moving
+	handedOver.Custodian = "PRV2"
+	for _, event := range []ChaincodeEvent{
+		pkgEvent("tx1", 0, "PkgCreated", p1),
+		pkgEvent("tx2", 0, "PkgCreated", p2),
+		pkgEvent("tx3", 0, "PkgAccepted", moving),
+	} {
+		if written, err := store.Apply(event); err != nil || written != 1 {
+			t.Fatalf("Apply(%s) = %d, %v", event.TxID, written, err)
+		}
+	}
+
+	if got := providerIds(t, store, "PRV"); !equalStrings(got, []string{"P1", "P2"}) {
+		t.Fatalf("PackagesByProvider = %v", got)
+	}
+	if got := roleStatusIds(t, store, "Shipper", "SHP", "In_Transit"); !equalStrings(got, []string{"P1"}) {
+		t.Fatalf("PackagesByRoleStatus = %v", got)
+	}
+	if _, err := store.PackagesByRoleStatus("Carrier", "SHP", "In_Transit"); err == nil {
+		t.Fatal("unknown role accepted")
+	}
+
+	// a handed over package is listed under its custodian
+	if _, err := store.Apply(pkgEvent("tx4", 0, "PkgHandedOver", handedOver)); err != nil {
+		t.Fatal(err)
+	}
+	if got := providerIds(t, store, "PRV2"); !equalStrings(got, []string{"P1"}) {
+		t.Fatalf("PackagesByProvider of the custodian = %v", got)
+	}
+	if got := roleStatusIds(t, store, "Provider", "PRV", "In_Transit"); len(got) != 0 {
+		t.Fatalf("PackagesByRoleStatus of the previous provider = %v", got)
+	}
+
+	// cancelled packages leave the read model
+	if written, err := store.Apply(pkgEvent("tx5", 0, EVENT_PKG_CANCELLED, p2)); err != nil || written != 1 {
+		t.Fatalf("Apply(cancel) = %d, %v", written, err)
+	}
+	if got := providerIds(t, store, "PRV"); len(got) != 0 {
+		t.Fatalf("PackagesByProvider after cancel = %v", got)
+	}
+}
+
+func TestApplyBatch(t *testing.T) {
+	store := newTestStore(t)
+
+	var events []PkgEvent
+	for _, pkg := range []Package{p1, p2} {
+		snapshot, _ := json.Marshal(pkg)
+		events = append(events, PkgEvent{Event: "PkgMigrated", PkgId: pkg.PkgId, Package: snapshot})
+	}
+	events = append(events, PkgEvent{Event: "PkgUpdated", PkgId: "P3"})
+	payload, _ := json.Marshal(events)
+
+	// the event without a snapshot is skipped
+	if written, err := store.Apply(ChaincodeEvent{TxID: "tx1", EventName: EVENT_PKG_BATCH, Payload: payload}); err != nil || written != 2 {
+		t.Fatalf("Apply = %d, %v", written, err)
+	}
+	if got := providerIds(t, store, "PRV"); !equalStrings(got, []string{"P1", "P2"}) {
+		t.Fatalf("PackagesByProvider = %v", got)
+	}
+
+	if _, err := store.Apply(ChaincodeEvent{TxID: "tx2", EventName: EVENT_PKG_BATCH, Payload: json.RawMessage(`{}`)}); err == nil {
+		t.Fatal("malformed batch applied")
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	store := newTestStore(t)
+
+	if block, err := store.Checkpoint(); err != nil || block != 0 {
+		t.Fatalf("Checkpoint of an empty store = %d, %v", block, err)
+	}
+
+	moving := p1
+	moving.PkgStatus = "In_Transit"
+	if _, err := store.Apply(pkgEvent("tx1", 4, "PkgCreated", p1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Apply(pkgEvent("tx2", 7, "PkgAccepted", moving)); err != nil {
+		t.Fatal(err)
+	}
+	// events without a block, as delivered by the event hub, leave the checkpoint alone
+	if _, err := store.Apply(pkgEvent("tx3", 0, "PkgCreated", p2)); err != nil {
+		t.Fatal(err)
+	}
+	if block, err := store.Checkpoint(); err != nil || block != 7 {
+		t.Fatalf("Checkpoint = %d, %v", block, err)
+	}
+
+	// replaying from the checkpoint does not apply a transaction twice
+	if written, err := store.Apply(pkgEvent("tx1", 4, "PkgCreated", p1)); err != nil || written != 0 {
+		t.Fatalf("Apply of a replayed transaction = %d, %v", written, err)
+	}
+	if got := roleStatusIds(t, store, "Provider", "PRV", "In_Transit"); !equalStrings(got, []string{"P1"}) {
+		t.Fatalf("replayed transaction overwrote P1: %v", got)
+	}
+	if block, err := store.Checkpoint(); err != nil || block != 7 {
+		t.Fatalf("Checkpoint after replay = %d, %v", block, err)
+	}
+}
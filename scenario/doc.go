@@ -0,0 +1,27 @@
+// Package scenario replays end-to-end business scenarios against a chaincode over a mockstub.MockStub and reports
+// where the results differ from the expected ones.
+//
+// A scenario is a JSON script of steps. Each step is the body of a deploy, invoke or query request to the REST API
+// of a peer, as in LearnChaincodeREST.postman_collection.json, together with the outcome the step should have:
+//
+//	{
+//	  "name": "damaged package can not be delivered",
+//	  "step": "1m",
+//	  "steps": [
+//	    {"method": "deploy", "params": {"ctorMsg": {"function": "init", "args": ["SHP", "INS", "CON", "PRV", "2", "8", "sample", "insecure"]}}},
+//	    {"method": "invoke", "params": {"ctorMsg": {"function": "updatetemp", "args": ["1Z20170426", "12", "PRV"]}},
+//	     "expect": {"event": {"name": "PkgDamaged"}}},
+//	    {"method": "invoke", "params": {"ctorMsg": {"function": "deliverpkg", "args": ["1Z20170426", "PRV"]}},
+//	     "expect": {"code": "E_ILLEGAL_STATE"}},
+//	    {"method": "query", "params": {"ctorMsg": {"function": "querypkgbyid", "args": ["1Z20170426"]}},
+//	     "expect": {"result": {"pkgstatus": "Pkg_Damaged"}}}
+//	  ]
+//	}
+//
+// The secureContext of a step becomes the common name of the caller certificate; the other JSON-RPC fields
+// (jsonrpc, id, type, chaincodeID) are accepted and ignored, so request bodies can be pasted as they are.
+//
+// A step without an error or code expectation must succeed. Expected results and event payloads are matched
+// field by field: objects only need the fields listed in the expectation, arrays must have the same elements in
+// the same order. A step that fails does not stop the scenario, every difference is collected in the Report.
+package scenario
@@ -0,0 +1,249 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+//==============================================================================================================================
+//	Report - The outcome of running a script: the number of steps run and a Failure for every step whose outcome
+//				differs from its expectation
+//==============================================================================================================================
+type Report struct {
+	Script   string
+	Steps    int
+	Failures []Failure
+}
+
+//==============================================================================================================================
+//	Failure - The differences between the expected and the actual outcome of a step, Step counts from 1
+//==============================================================================================================================
+type Failure struct {
+	Step  int
+	Name  string
+	Diffs []string
+}
+
+func (r *Report) OK() bool {
+	return len(r.Failures) == 0
+}
+
+func (r *Report) String() string {
+	if r.OK() {
+		return fmt.Sprintf("%s: %d steps passed", r.Script, r.Steps)
+	}
+
+	lines := []string{fmt.Sprintf("%s: %d of %d steps failed", r.Script, len(r.Failures), r.Steps)}
+	for _, failure := range r.Failures {
+		lines = append(lines, fmt.Sprintf("  step %d (%s):", failure.Step, failure.Name))
+		for _, diff := range failure.Diffs {
+			lines = append(lines, "    "+diff)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+//==============================================================================================================================
+//	Run - runs every step of script against cc on a new mock stub named after the script
+//==============================================================================================================================
+func Run(cc shim.Chaincode, script *Script) *Report {
+	stub := mockstub.NewMockStub(script.Name, cc)
+	if script.Start != "" {
+		stub.Time, _ = time.Parse(time.RFC3339, script.Start)
+	}
+	if script.Step != "" {
+		stub.Step, _ = time.ParseDuration(script.Step)
+	}
+
+	report := &Report{Script: script.Name}
+	for i, step := range script.Steps {
+		report.Steps++
+		diffs := runStep(stub, step)
+		if len(diffs) > 0 {
+			report.Failures = append(report.Failures, Failure{Step: i + 1, Name: step.name(), Diffs: diffs})
+		}
+	}
+	return report
+}
+
+func (step Step) name() string {
+	if step.Name != "" {
+		return step.Name
+	}
+	return strings.TrimSpace(step.Method + " " + step.Params.CtorMsg.Function + " " + strings.Join(step.Params.CtorMsg.Args, " "))
+}
+
+//==============================================================================================================================
+//	runStep - sends one step to the stub as the caller of its secureContext and compares the outcome
+//==============================================================================================================================
+func runStep(stub *mockstub.MockStub, step Step) []string {
+	if step.Params.SecureContext != "" {
+		stub.SetCaller(step.Params.SecureContext)
+	} else {
+		stub.Caller, stub.Attributes = nil, map[string][]byte{}
+	}
+
+	events := len(stub.Events)
+	function, args := step.Params.CtorMsg.Function, step.Params.CtorMsg.Args
+
+	var result []byte
+	var err error
+	switch step.Method {
+	case METHOD_DEPLOY:
+		result, err = stub.MockInit(function, args...)
+	case METHOD_INVOKE:
+		result, err = stub.MockInvoke(function, args...)
+	default:
+		result, err = stub.MockQuery(function, args...)
+	}
+
+	expect := step.Expect
+	if expect == nil {
+		expect = &Expect{}
+	}
+
+	var diffs []string
+	if expect.Error == "" && expect.Code == "" {
+		if err != nil {
+			return []string{"unexpected error: " + err.Error()}
+		}
+	} else {
+		if err == nil {
+			return []string{fmt.Sprintf("succeeded, want error %s", expectedError(expect))}
+		}
+		if expect.Error != "" && !strings.Contains(err.Error(), expect.Error) {
+			diffs = append(diffs, fmt.Sprintf("error: got %q, want it to contain %q", err.Error(), expect.Error))
+		}
+		if expect.Code != "" {
+			if code := errorCode(err); code != expect.Code {
+				diffs = append(diffs, fmt.Sprintf("code: got %q, want %q (%s)", code, expect.Code, err.Error()))
+			}
+		}
+	}
+
+	if len(expect.Result) > 0 {
+		diffs = append(diffs, matchJSON("result", expect.Result, result)...)
+	}
+
+	if expect.Event != nil {
+		if len(stub.Events) == events {
+			diffs = append(diffs, fmt.Sprintf("event: none, want %s", expect.Event.Name))
+		} else {
+			event := stub.Events[len(stub.Events)-1]
+			if event.Name != expect.Event.Name {
+				diffs = append(diffs, fmt.Sprintf("event: got %s, want %s", event.Name, expect.Event.Name))
+			}
+			if len(expect.Event.Payload) > 0 {
+				diffs = append(diffs, matchJSON("event.payload", expect.Event.Payload, event.Payload)...)
+			}
+		}
+	}
+
+	return diffs
+}
+
+func expectedError(expect *Expect) string {
+	if expect.Code != "" {
+		return expect.Code
+	}
+	return fmt.Sprintf("%q", expect.Error)
+}
+
+//==============================================================================================================================
+//	errorCode - the code of a JSON error {"code": ..., "message": ...}, empty for any other error
+//==============================================================================================================================
+func errorCode(err error) string {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if json.Unmarshal([]byte(err.Error()), &body) != nil {
+		return ""
+	}
+	return body.Code
+}
+
+//==============================================================================================================================
+//	matchJSON - compares an actual result to the expected JSON. A result that is not JSON is compared as a string.
+//==============================================================================================================================
+func matchJSON(path string, expected json.RawMessage, actual []byte) []string {
+	var want interface{}
+	err := json.Unmarshal(expected, &want)
+	if err != nil {
+		return []string{fmt.Sprintf("%s: invalid expectation: %v", path, err)}
+	}
+
+	var got interface{}
+	decoder := json.NewDecoder(bytes.NewReader(actual))
+	if decoder.Decode(&got) != nil || decoder.More() {
+		got = string(actual)
+	}
+
+	return match(path, want, got)
+}
+
+//==============================================================================================================================
+//	match - the differences between want and got. Objects match when every field of want matches, arrays when they
+//				have the same length and matching elements.
+//==============================================================================================================================
+func match(path string, want, got interface{}) []string {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		object, ok := got.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: got %s, want an object", path, format(got))}
+		}
+
+		keys := make([]string, 0, len(want))
+		for key := range want {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var diffs []string
+		for _, key := range keys {
+			value, ok := object[key]
+			if !ok {
+				diffs = append(diffs, fmt.Sprintf("%s.%s: missing, want %s", path, key, format(want[key])))
+				continue
+			}
+			diffs = append(diffs, match(path+"."+key, want[key], value)...)
+		}
+		return diffs
+
+	case []interface{}:
+		array, ok := got.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: got %s, want an array", path, format(got))}
+		}
+		if len(array) != len(want) {
+			return []string{fmt.Sprintf("%s: got %d elements %s, want %d elements %s", path, len(array), format(got), len(want), format(want))}
+		}
+
+		var diffs []string
+		for i := range want {
+			diffs = append(diffs, match(fmt.Sprintf("%s[%d]", path, i), want[i], array[i])...)
+		}
+		return diffs
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		return []string{fmt.Sprintf("%s: got %s, want %s", path, format(got), format(want))}
+	}
+	return nil
+}
+
+func format(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
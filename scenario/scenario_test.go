@@ -0,0 +1,102 @@
+package scenario
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// kvChaincode stores values under keys, "get" returns them and "fail" returns its argument as the error.
+type kvChaincode struct{}
+
+func (t *kvChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	return nil, stub.PutState("owner", []byte(`{"name": "`+args[0]+`", "tags": ["a", "b"]}`))
+}
+
+func (t *kvChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	switch function {
+	case "put":
+		if err := stub.PutState(args[0], []byte(args[1])); err != nil {
+			return nil, err
+		}
+		return nil, stub.SetEvent("Put", []byte(`{"key": "`+args[0]+`"}`))
+	case "fail":
+		return nil, errors.New(args[0])
+	}
+	return nil, errors.New("unknown function " + function)
+}
+
+func (t *kvChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	if function == "get" {
+		return stub.GetState(args[0])
+	}
+	return nil, errors.New("unknown function " + function)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		script string
+		err    string
+	}{
+		{`{"steps": [{"method": "deploy", "params": {"ctorMsg": {"function": "init", "args": ["x"]}}}]}`, ""},
+		{`{"steps": []}`, "no steps"},
+		{`{"steps": [{"method": "delete", "params": {"ctorMsg": {"function": "init"}}}]}`, "method"},
+		{`{"steps": [{"method": "query", "params": {"ctorMsg": {}}}]}`, "no function"},
+		{`{"step": "soon", "steps": [{"method": "query", "params": {"ctorMsg": {"function": "get"}}}]}`, "Invalid step"},
+		{`{"steps": [`, "unexpected end"},
+	}
+	for _, test := range tests {
+		_, err := Parse([]byte(test.script))
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("Parse(%s) = %v, want error containing %q", test.script, err, test.err)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	script, err := Parse([]byte(`{
+	  "name": "kv",
+	  "steps": [
+	    {"jsonrpc": "2.0", "method": "deploy", "params": {"type": 1, "ctorMsg": {"function": "init", "args": ["alice"]}, "secureContext": "admin"}, "id": 1},
+	    {"method": "query", "params": {"ctorMsg": {"function": "get", "args": ["owner"]}}, "expect": {"result": {"name": "alice"}}},
+	    {"method": "query", "params": {"ctorMsg": {"function": "get", "args": ["owner"]}}, "expect": {"result": {"name": "bob", "tags": ["a"], "age": 3}}},
+	    {"method": "invoke", "params": {"ctorMsg": {"function": "put", "args": ["k", "v"]}}, "expect": {"event": {"name": "Put", "payload": {"key": "k"}}}},
+	    {"method": "query", "params": {"ctorMsg": {"function": "get", "args": ["k"]}}, "expect": {"result": "v"}},
+	    {"name": "json error", "method": "invoke", "params": {"ctorMsg": {"function": "fail", "args": ["{\"code\": \"E_ARGS\", \"message\": \"bad\"}"]}}, "expect": {"code": "E_NOT_FOUND"}},
+	    {"method": "invoke", "params": {"ctorMsg": {"function": "fail", "args": ["boom"]}}, "expect": {"error": "boom"}},
+	    {"method": "invoke", "params": {"ctorMsg": {"function": "put", "args": ["k", "w"]}}, "expect": {"error": "boom"}},
+	    {"method": "query", "params": {"ctorMsg": {"function": "missing"}}}
+	  ]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := Run(new(kvChaincode), script)
+	want := []Failure{
+		{Step: 3, Name: "query get owner", Diffs: []string{
+			`result.age: missing, want 3`,
+			`result.name: got "alice", want "bob"`,
+			`result.tags: got 2 elements ["a","b"], want 1 elements ["a"]`,
+		}},
+		{Step: 6, Name: "json error", Diffs: []string{
+			`code: got "E_ARGS", want "E_NOT_FOUND" ({"code": "E_ARGS", "message": "bad"})`,
+		}},
+		{Step: 8, Name: "invoke put k w", Diffs: []string{`succeeded, want error "boom"`}},
+		{Step: 9, Name: "query missing", Diffs: []string{"unexpected error: unknown function missing"}},
+	}
+
+	if report.Steps != 9 || len(report.Failures) != len(want) {
+		t.Fatalf("unexpected report:\n%s", report)
+	}
+	for i, failure := range report.Failures {
+		if failure.Step != want[i].Step || failure.Name != want[i].Name || strings.Join(failure.Diffs, "\n") != strings.Join(want[i].Diffs, "\n") {
+			t.Errorf("failure %d = %+v, want %+v", i, failure, want[i])
+		}
+	}
+	if !strings.HasPrefix(report.String(), "kv: 4 of 9 steps failed") {
+		t.Errorf("unexpected report summary:\n%s", report)
+	}
+}
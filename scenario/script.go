@@ -0,0 +1,122 @@
+package scenario
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+const (
+	METHOD_DEPLOY = "deploy"
+	METHOD_INVOKE = "invoke"
+	METHOD_QUERY  = "query"
+)
+
+//==============================================================================================================================
+//	Script - A named scenario. Start and Step set the transaction clock of the stub: the timestamp of the first
+//				transaction (RFC 3339, mockstub.DefaultTime if empty) and how far it advances after every deploy
+//				and invoke (a time.ParseDuration string, e.g. "1m").
+//==============================================================================================================================
+type Script struct {
+	Name  string `json:"name"`
+	Start string `json:"start,omitempty"`
+	Step  string `json:"step,omitempty"`
+	Steps []Step `json:"steps"`
+}
+
+//==============================================================================================================================
+//	Step - One deploy, invoke or query request body and the outcome expected from it
+//==============================================================================================================================
+type Step struct {
+	Name   string  `json:"name,omitempty"`
+	Method string  `json:"method"`
+	Params Params  `json:"params"`
+	Expect *Expect `json:"expect,omitempty"`
+}
+
+//==============================================================================================================================
+//	Params - The params of a REST request body, only the ctorMsg and the secureContext are used
+//==============================================================================================================================
+type Params struct {
+	CtorMsg       CtorMsg `json:"ctorMsg"`
+	SecureContext string  `json:"secureContext,omitempty"`
+}
+
+type CtorMsg struct {
+	Function string   `json:"function"`
+	Args     []string `json:"args"`
+}
+
+//==============================================================================================================================
+//	Expect - The expected outcome of a step. Error is a text the error must contain and Code the code of a JSON
+//				error {"code": ..., "message": ...}; either one means the step must fail. Result is the expected
+//				result, a JSON value or, for a result that is not JSON, a string with the raw result.
+//==============================================================================================================================
+type Expect struct {
+	Error  string          `json:"error,omitempty"`
+	Code   string          `json:"code,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Event  *ExpectEvent    `json:"event,omitempty"`
+}
+
+//==============================================================================================================================
+//	ExpectEvent - The chaincode event the step must set. Payload is matched like a result when given.
+//==============================================================================================================================
+type ExpectEvent struct {
+	Name    string          `json:"name"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+//==============================================================================================================================
+//	Load - reads and validates the script in the file at path
+//==============================================================================================================================
+func Load(path string) (*Script, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	script, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return script, nil
+}
+
+//==============================================================================================================================
+//	Parse - decodes and validates a JSON script
+//==============================================================================================================================
+func Parse(data []byte) (*Script, error) {
+	var script Script
+	err := json.Unmarshal(data, &script)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(script.Steps) == 0 {
+		return nil, errors.New("Script has no steps")
+	}
+	if script.Start != "" {
+		if _, err := time.Parse(time.RFC3339, script.Start); err != nil {
+			return nil, fmt.Errorf("Invalid start %q: %v", script.Start, err)
+		}
+	}
+	if script.Step != "" {
+		if _, err := time.ParseDuration(script.Step); err != nil {
+			return nil, fmt.Errorf("Invalid step %q: %v", script.Step, err)
+		}
+	}
+
+	for i, step := range script.Steps {
+		if step.Method != METHOD_DEPLOY && step.Method != METHOD_INVOKE && step.Method != METHOD_QUERY {
+			return nil, fmt.Errorf("Step %d: method must be deploy, invoke or query, got %q", i+1, step.Method)
+		}
+		if step.Params.CtorMsg.Function == "" {
+			return nil, fmt.Errorf("Step %d: ctorMsg has no function", i+1)
+		}
+	}
+
+	return &script, nil
+}
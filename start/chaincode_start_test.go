@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jainrahul1234/learn-chaincode/mockstub"
+)
+
+func TestChaincode(t *testing.T) {
+	stub := mockstub.NewMockStub("start", new(SimpleChaincode))
+	if _, err := stub.MockInit("init", "UPS"); err == nil {
+		t.Fatal("expected init with one argument to fail")
+	}
+	if _, err := stub.MockInit("init", "UPS", "RAHUL", "2", "ANTIBIOTICS"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		query    bool
+		function string
+		args     []string
+		want     *PackageInfo
+		fails    bool
+	}{
+		{"read init package", true, "read", []string{"1Z20170426"}, &PackageInfo{"UPS", "RAHUL", "2", "ANTIBIOTICS"}, false},
+		{"create", false, "create", []string{"P1", "FEDEX", "ACME", "4", "VACCINE"}, nil, false},
+		{"read created package", true, "read", []string{"P1"}, &PackageInfo{"FEDEX", "ACME", "4", "VACCINE"}, false},
+		{"create with missing argument", false, "create", []string{"P2", "FEDEX", "ACME", "4"}, nil, true},
+		{"write", false, "write", []string{"raw", "1"}, nil, false},
+		{"read value that is not a package", true, "read", []string{"raw"}, nil, true},
+		{"write with one argument", false, "write", []string{"raw"}, nil, true},
+		{"init through invoke", false, "init", []string{"DHL", "RAHUL", "3", "INSULIN"}, nil, false},
+		{"read reset package", true, "read", []string{"1Z20170426"}, &PackageInfo{"DHL", "RAHUL", "3", "INSULIN"}, false},
+		{"read without key", true, "read", nil, nil, true},
+		{"read missing key", true, "read", []string{"missing"}, nil, true},
+		{"unknown invoke", false, "delete", []string{"P1"}, nil, true},
+		{"unknown query", true, "create", []string{"P3", "FEDEX", "ACME", "4", "VACCINE"}, nil, true},
+	}
+	for _, test := range tests {
+		var got []byte
+		var err error
+		if test.query {
+			got, err = stub.MockQuery(test.function, test.args...)
+		} else {
+			got, err = stub.MockInvoke(test.function, test.args...)
+		}
+		if (err != nil) != test.fails {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+		if test.want == nil {
+			continue
+		}
+
+		var packageinfo PackageInfo
+		if err := json.Unmarshal(got, &packageinfo); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if packageinfo != *test.want {
+			t.Fatalf("%s: got %+v, want %+v", test.name, packageinfo, *test.want)
+		}
+	}
+}